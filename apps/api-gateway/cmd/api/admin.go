@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/config"
+	"github.com/yourusername/cowatch/api-gateway/internal/database"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+const adminUsage = `usage: api admin <command>
+
+commands:
+  grant <username>    let a user call the /admin endpoints
+  revoke <username>   take admin rights away again
+  list                list the admins
+`
+
+// runAdmin implements the admin subcommand and returns the process exit code.
+// Admin rights can't be granted over the API, so this is how the first admin is made.
+func runAdmin(cfg *config.Config, args []string) int {
+	if len(args) == 0 || (args[0] != "list" && len(args) != 2) {
+		fmt.Fprint(os.Stderr, adminUsage)
+		return 2
+	}
+
+	db, err := database.Connect(cfg.Database.URL, dbPool(cfg), dbLogger(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "connect: %v\n", err)
+		return 1
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "grant", "revoke":
+		if err := setAdmin(ctx, db, args[1], args[0] == "grant"); err != nil {
+			fmt.Fprintf(os.Stderr, "admin %s: %v\n", args[0], err)
+			return 1
+		}
+		if args[0] == "grant" {
+			fmt.Printf("%s is now an admin\n", args[1])
+		} else {
+			fmt.Printf("%s is no longer an admin\n", args[1])
+		}
+	case "list":
+		var usernames []string
+		if err := db.WithContext(ctx).Model(&models.User{}).
+			Where("is_admin = ?", true).Order("username").
+			Pluck("username", &usernames).Error; err != nil {
+			fmt.Fprintf(os.Stderr, "admin list: %v\n", err)
+			return 1
+		}
+		for _, username := range usernames {
+			fmt.Println(username)
+		}
+	default:
+		fmt.Fprint(os.Stderr, adminUsage)
+		return 2
+	}
+	return 0
+}
+
+// setAdmin grants or revokes username's admin rights
+func setAdmin(ctx context.Context, db *gorm.DB, username string, isAdmin bool) error {
+	result := db.WithContext(ctx).Model(&models.User{}).
+		Where("username = ?", username).
+		Update("is_admin", isAdmin)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no user named %q", username)
+	}
+	return nil
+}
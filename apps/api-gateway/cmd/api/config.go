@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/config"
+)
+
+const configUsage = `usage: api config <command>
+
+commands:
+  print       print the effective configuration as YAML with secrets redacted
+  validate    check the configuration and exit
+`
+
+// runConfig implements the config subcommand and returns the process exit code
+func runConfig(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "print":
+		out, err := cfg.Redacted().YAML()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "print: %v\n", err)
+			return 1
+		}
+		os.Stdout.Write(out)
+		// Still show an invalid configuration, but fail so scripts notice
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+	case "validate":
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("configuration is valid")
+
+	default:
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+	return 0
+}
@@ -1,124 +1,414 @@
 package main
 
 import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 
 	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/auth"
 	"github.com/yourusername/cowatch/api-gateway/internal/config"
+	"github.com/yourusername/cowatch/api-gateway/internal/cors"
 	"github.com/yourusername/cowatch/api-gateway/internal/database"
 	"github.com/yourusername/cowatch/api-gateway/internal/handlers"
+	"github.com/yourusername/cowatch/api-gateway/internal/health"
+	"github.com/yourusername/cowatch/api-gateway/internal/jobs"
+	"github.com/yourusername/cowatch/api-gateway/internal/logging"
+	"github.com/yourusername/cowatch/api-gateway/internal/media"
+	"github.com/yourusername/cowatch/api-gateway/internal/metrics"
 	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/oidc"
+	"github.com/yourusername/cowatch/api-gateway/internal/storage"
+	"github.com/yourusername/cowatch/api-gateway/internal/tracing"
 	"github.com/yourusername/cowatch/api-gateway/internal/websocket"
 )
 
 func main() {
+	// "api config ..." inspects the configuration and exits
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfig(os.Args[2:]))
+	}
+
+	// SIGINT/SIGTERM start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Structured JSON logs; the standard log package is routed through the same handler
+	if err := logging.Setup(os.Stderr, logging.Options{Level: cfg.Log.Level, Format: cfg.Log.Format}); err != nil {
+		log.Fatal(err)
+	}
+
+	// "api migrate ..." manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg, os.Args[2:]))
+	}
+
+	// "api admin ..." grants or revokes admin rights and exits
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		os.Exit(runAdmin(cfg, os.Args[2:]))
+	}
+
+	// Traces are exported from here on; buffered spans are flushed on the way out
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		ServiceName: "cowatch-api-gateway",
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	// Connect to database
-	db, err := database.Connect(cfg.DatabaseURL)
+	db, err := database.Connect(cfg.Database.URL, dbPool(cfg), dbLogger(cfg))
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
+	}
+	if cfg.Database.MigrateOnStart {
+		if err := database.Migrate(context.Background(), db); err != nil {
+			fatal("Failed to migrate database", err)
+		}
 	}
 
-	// Create server
-	server := handlers.NewServer(db, cfg.JWTSecret)
+	// Time every query for /metrics
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		fatal("Failed to register database metrics", err)
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		fatal("Failed to register database tracing", err)
+	}
 
 	// Create and start WebSocket hub
 	wsHub := websocket.NewHub()
 	go wsHub.Run()
+	metrics.WatchHub(wsHub.Stats)
+
+	// Schedule background cleanup jobs
+	scheduler := newScheduler(cfg, db, wsHub)
+	go scheduler.Start(ctx, time.Minute)
+
+	// Without a configured secret every start signs with a fresh one (production refuses this)
+	randomized, err := cfg.RandomizeDefaultSecrets()
+	if err != nil {
+		fatal("Failed to generate a JWT secret", err)
+	}
+	if randomized {
+		slog.Warn("NO JWT SECRET CONFIGURED: signing with a random secret, so every restart signs all users out. " +
+			"Set JWT_SECRET or JWT_SECRETS.")
+	}
+
+	// Access tokens are signed and verified in one place for REST and WebSocket
+	var privateKeys map[string]crypto.Signer
+	if cfg.Auth.JWTKeysDir != "" {
+		if privateKeys, err = auth.LoadPrivateKeys(cfg.Auth.JWTKeysDir); err != nil {
+			fatal("Failed to load signing keys", err)
+		}
+	}
+	tokens, err := auth.NewTokenService(auth.Config{
+		Secrets:     cfg.Auth.JWTSecrets,
+		PrivateKeys: privateKeys,
+		ActiveKeyID: cfg.Auth.JWTActiveKeyID,
+		Issuer:      cfg.Auth.JWTIssuer,
+		Audience:    cfg.Auth.JWTAudience,
+		AccessTTL:   cfg.Auth.AccessTokenTTL,
+	})
+	if err != nil {
+		fatal("Invalid token configuration", err)
+	}
+
+	// Uploaded avatars go to local disk or an S3-compatible bucket
+	store, err := newStorage(cfg)
+	if err != nil {
+		fatal("Failed to set up storage", err)
+	}
+
+	// Create server
+	server := handlers.NewServer(db, tokens,
+		handlers.WithRefreshTTL(cfg.Auth.RefreshTokenTTL),
+		handlers.WithScheduler(scheduler),
+		handlers.WithPresence(wsHub),
+		handlers.WithStorage(store),
+		handlers.WithOIDC(newOIDCRegistry(cfg)),
+	)
+
+	// One origin allowlist covers CORS and WebSocket handshakes
+	origins, err := cors.New(cors.Options{
+		AllowedOrigins:   cfg.Server.CORS.AllowedOrigins,
+		AllowCredentials: cfg.Server.CORS.AllowCredentials,
+		MaxAge:           cfg.Server.CORS.MaxAge,
+	})
+	if err != nil {
+		fatal("Invalid CORS configuration", err)
+	}
 
 	// Create WebSocket HTTP handler
-	wsHandler := websocket.NewHTTPHandler(wsHub, db, cfg.JWTSecret)
+	wsHandler := websocket.NewHTTPHandler(wsHub, db, tokens)
+	wsHandler.AllowLegacyTokenAuth = cfg.WebSocket.LegacyTokenAuth
+	wsHandler.Origins = origins
+	wsHandler.Limits = websocket.Limits{
+		MaxMessageSize: cfg.WebSocket.MaxMessageSize,
+		SendBuffer:     cfg.WebSocket.SendBuffer,
+		WriteTimeout:   cfg.WebSocket.WriteTimeout,
+		PongTimeout:    cfg.WebSocket.PongTimeout,
+		EventRate:      cfg.WebSocket.EventRate,
+		EventBurst:     cfg.WebSocket.EventBurst,
+	}
+	wsHandler.Compression = websocket.Compression{
+		Enabled:   cfg.WebSocket.Compression,
+		Level:     cfg.WebSocket.CompressionLevel,
+		Threshold: cfg.WebSocket.CompressionThreshold,
+	}
+
+	// Readiness reports on the media service when one is configured
+	var mediaClient *media.Client
+	if cfg.MediaService.URL != "" {
+		mediaClient = media.NewClient(cfg.MediaService.URL, cfg.MediaService.Timeout)
+	}
+
+	// Readiness covers everything a room session needs
+	probes := newHealthChecker(db, wsHub, mediaClient)
 
 	// Create router
-	router := gin.Default()
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid TRUSTED_PROXIES", err)
+	}
+
+	// Request IDs and the server span come first so every later log line can carry them
+	router.Use(logging.RequestIDMiddleware(), tracing.Middleware(), logging.AccessLog(), logging.Recovery())
 
 	// CORS middleware
-	router.Use(corsMiddleware())
+	router.Use(origins.Middleware())
 
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	// Request latency for every route registered below
+	router.Use(metrics.HTTPMiddleware())
+
+	// Probes: /livez restarts a wedged process, /readyz takes the gateway out of rotation.
+	// /health is kept for existing liveness checks.
+	router.GET("/livez", probes.Live)
+	router.GET("/readyz", probes.Ready)
+	router.GET("/health", probes.Live)
+
+	// Public keys for services verifying gateway tokens
+	router.GET("/.well-known/jwks.json", server.GetJWKS)
+
+	// Serve locally stored uploads unless they're published elsewhere; object storage serves its own
+	if local, ok := store.(*storage.LocalStorage); ok && strings.HasPrefix(local.BaseURL, "/") {
+		router.Static(local.BaseURL, local.Dir)
+	}
 
 	// Create API group with /api/v1 prefix
 	apiGroup := router.Group("/api/v1")
 
-	// Routes that require authentication
-	authRequired := []string{
-		"/auth/me",
-		"/auth/logout",
-		"/rooms",          // POST only, but we apply to all and check in handler
-		"/users/me/recent-rooms",
+	// Each operation authenticates as its OpenAPI security section declares
+	routeAuth, err := middleware.RouteAuth("/api/v1", db, tokens)
+	if err != nil {
+		fatal("Invalid route auth policy", err)
 	}
-
-	// Register handlers with auth middleware for protected routes
 	api.RegisterHandlersWithOptions(apiGroup, server, api.GinServerOptions{
-		Middlewares: []api.MiddlewareFunc{
-			func(c *gin.Context) {
-				// Skip auth for public routes
-				path := c.Request.URL.Path
-				method := c.Request.Method
-
-				// Public routes (no auth required)
-				publicRoutes := map[string][]string{
-					"/api/v1/auth/register": {"POST"},
-					"/api/v1/auth/login":    {"POST"},
-					"/api/v1/rooms":         {"GET"},
-				}
-
-				// Check if route is public
-				if methods, ok := publicRoutes[path]; ok {
-					for _, m := range methods {
-						if m == method {
-							c.Next()
-							return
-						}
-					}
-				}
-
-				// Apply auth middleware for protected routes
-				for _, route := range authRequired {
-					fullPath := "/api/v1" + route
-					if path == fullPath || (len(path) > len(fullPath) && path[:len(fullPath)] == fullPath) {
-						middleware.AuthMiddleware(db, cfg.JWTSecret)(c)
-						return
-					}
-				}
-
-				// Default: require auth for any unmatched route
-				middleware.AuthMiddleware(db, cfg.JWTSecret)(c)
-			},
-		},
+		Middlewares: []api.MiddlewareFunc{api.MiddlewareFunc(routeAuth)},
 	})
 
 	// WebSocket endpoint for room connections
-	// URL: ws://localhost:8080/ws/rooms/{roomCode}?token=xxx
+	// URL: ws://localhost:8080/ws/rooms/{roomCode}?ticket=xxx (ticket from POST /rooms/{roomCode}/ws-ticket)
 	router.GET("/ws/rooms/:roomCode", wsHandler.HandleWebSocket)
 
 	// Start server
-	log.Printf("Starting server on port %s", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{Addr: ":" + cfg.Server.Port, Handler: router}
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("Starting server", "port", cfg.Server.Port, "environment", cfg.Environment)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	// Prometheus scrapes a separate port that isn't exposed with the API
+	var metricsSrv *http.Server
+	if cfg.Server.MetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsSrv = &http.Server{Addr: ":" + cfg.Server.MetricsPort, Handler: mux}
+		go func() {
+			slog.Info("Serving metrics", "port", cfg.Server.MetricsPort)
+			serveErr <- metricsSrv.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		fatal("Failed to start server", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Fail readiness first so load balancers stop sending new connections, then stop
+	// accepting, wait for in-flight requests and send WebSocket clients elsewhere
+	slog.Info("Shutting down", "drain_delay", cfg.Server.DrainDelay)
+	probes.Drain()
+	time.Sleep(cfg.Server.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Graceful shutdown did not finish", "error", err)
+	}
+	if metricsSrv != nil {
+		metricsSrv.Close()
 	}
+	wsHub.CloseAll("server shutting down")
+	slog.Info("Server stopped")
 }
 
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-		c.Header("Access-Control-Max-Age", "86400")
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+// newHealthChecker registers readiness checks for the database and the hub loop. The
+// media service, when configured, is reported without affecting readiness.
+func newHealthChecker(db *gorm.DB, hub *websocket.Hub, mediaClient *media.Client) *health.Checker {
+	probes := health.New()
+	probes.Add("database", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
 		}
+		return sqlDB.PingContext(ctx)
+	})
+	probes.Add("hub", hub.Ping)
+	if mediaClient != nil {
+		// Rooms keep working without media, so an outage there mustn't pull every gateway
+		probes.AddOptional("media_service", mediaClient.Ping)
+	}
+	return probes
+}
+
+// dbLogger logs failed and slow queries, or every query at the "info" level
+func dbLogger(cfg *config.Config) gormlogger.Interface {
+	level, err := logging.ParseGormLevel(cfg.Database.LogLevel)
+	if err != nil {
+		fatal("Invalid database log level", err)
+	}
+	return logging.NewGormLogger(level, cfg.Database.SlowQueryThreshold)
+}
+
+// dbPool sizes the database connection pool from cfg
+func dbPool(cfg *config.Config) database.PoolConfig {
+	return database.PoolConfig{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+	}
+}
+
+// newStorage builds the storage backend selected by cfg.Storage.Backend
+func newStorage(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.Storage.Backend {
+	case "local":
+		publicURL := cfg.Storage.PublicURL
+		if publicURL == "" {
+			publicURL = "/uploads"
+		}
+		return storage.NewLocalStorage(cfg.Storage.LocalDir, publicURL)
+	case "s3":
+		return &storage.S3Storage{
+			Endpoint:  cfg.Storage.S3.Endpoint,
+			Region:    cfg.Storage.S3.Region,
+			Bucket:    cfg.Storage.S3.Bucket,
+			AccessKey: cfg.Storage.S3.AccessKey,
+			SecretKey: cfg.Storage.S3.SecretKey,
+			PublicURL: cfg.Storage.PublicURL,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+}
+
+// newOIDCRegistry registers the identity providers from cfg
+func newOIDCRegistry(cfg *config.Config) *oidc.Registry {
+	providers := make([]oidc.ProviderConfig, 0, len(cfg.OIDCProviders))
+	for _, p := range cfg.OIDCProviders {
+		providers = append(providers, oidc.ProviderConfig{
+			Name:         p.Name,
+			DisplayName:  p.DisplayName,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+		})
+	}
+	return oidc.NewRegistry(providers)
+}
+
+// newScheduler registers the cleanup jobs enabled in cfg; a zero duration disables a job
+func newScheduler(cfg *config.Config, db *gorm.DB, hub *websocket.Hub) *jobs.Scheduler {
+	clock := jobs.RealClock()
+	scheduler := jobs.NewScheduler(clock)
 
-		c.Next()
+	if cfg.Cleanup.RoomIdleTimeout > 0 {
+		scheduler.Register(&jobs.RoomExpiryJob{
+			DB:          db,
+			Presence:    hub,
+			Clock:       clock,
+			IdleTimeout: cfg.Cleanup.RoomIdleTimeout,
+		}, cfg.Cleanup.Interval)
+	}
+	if cfg.Cleanup.MembershipRetention > 0 {
+		scheduler.Register(&jobs.MembershipPruneJob{
+			DB:        db,
+			Clock:     clock,
+			Retention: cfg.Cleanup.MembershipRetention,
+		}, cfg.Cleanup.Interval)
 	}
+	scheduler.Register(&jobs.TicketPruneJob{
+		DB:    db,
+		Clock: clock,
+	}, cfg.Cleanup.Interval)
+	scheduler.Register(&jobs.SessionPruneJob{
+		DB:    db,
+		Clock: clock,
+	}, cfg.Cleanup.Interval)
+	scheduler.Register(&jobs.GuestPruneJob{
+		DB:    db,
+		Clock: clock,
+	}, cfg.Cleanup.Interval)
+	scheduler.Register(&jobs.InvitePruneJob{
+		DB:    db,
+		Clock: clock,
+	}, cfg.Cleanup.Interval)
+	scheduler.Register(&jobs.OIDCStatePruneJob{
+		DB:    db,
+		Clock: clock,
+	}, cfg.Cleanup.Interval)
+	if cfg.Cleanup.ChatRetention > 0 {
+		scheduler.Register(&jobs.ChatPruneJob{
+			DB:        db,
+			Clock:     clock,
+			Retention: cfg.Cleanup.ChatRetention,
+		}, cfg.Cleanup.Interval)
+	}
+
+	return scheduler
 }
@@ -0,0 +1,148 @@
+// Command gen-security writes the security requirements of every OpenAPI operation as a
+// Go table, which the gateway uses to pick each route's authentication.
+//
+//	go run ./cmd/gen-security ../../api-specs/openapi.yaml > internal/api/security.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// methods are the operation keys of an OpenAPI path item
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// pathParam matches an OpenAPI path parameter such as {roomCode}
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Security requirements are a list of alternatives, each mapping scheme names to scopes.
+// A nil pointer means the key was absent, which differs from an explicit empty list.
+type requirements = *[]map[string][]string
+
+type document struct {
+	Security requirements                    `yaml:"security"`
+	Paths    map[string]map[string]yaml.Node `yaml:"paths"`
+}
+
+type operation struct {
+	Security requirements `yaml:"security"`
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: gen-security <openapi.yaml>")
+		os.Exit(2)
+	}
+	spec, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	out, err := generate(spec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(out)
+}
+
+// generate renders the api.OperationSecurity table for an OpenAPI document
+func generate(spec []byte) ([]byte, error) {
+	table, err := operationSecurity(spec)
+	if err != nil {
+		return nil, err
+	}
+	return render(table)
+}
+
+// operationSecurity maps "METHOD /gin/:route" to each operation's security alternatives
+func operationSecurity(spec []byte) (map[string][][]string, error) {
+	var doc document
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+
+	table := make(map[string][][]string)
+	for path, item := range doc.Paths {
+		route := pathParam.ReplaceAllString(path, ":$1")
+		for _, method := range methods {
+			node, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op operation
+			if err := node.Decode(&op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			security := op.Security
+			if security == nil {
+				security = doc.Security
+			}
+			table[strings.ToUpper(method)+" "+route] = alternatives(security)
+		}
+	}
+	return table, nil
+}
+
+func render(table map[string][][]string) ([]byte, error) {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString(`// Code generated by cmd/gen-security from api-specs/openapi.yaml. DO NOT EDIT.
+
+package api
+
+// OperationSecurity lists the security requirements of every operation, keyed by method
+// and gin route relative to the base URL. Each alternative names the schemes it needs;
+// an empty alternative allows anonymous requests and no alternatives means the operation
+// is public.
+var OperationSecurity = map[string][][]string{
+`)
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "\t%q: {", key)
+		for i, alt := range table[key] {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString("{")
+			for j, scheme := range alt {
+				if j > 0 {
+					buf.WriteString(", ")
+				}
+				fmt.Fprintf(&buf, "%q", scheme)
+			}
+			buf.WriteString("}")
+		}
+		buf.WriteString("},\n")
+	}
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
+
+// alternatives flattens requirements to the scheme names of each alternative
+func alternatives(security requirements) [][]string {
+	if security == nil {
+		return nil
+	}
+	alts := make([][]string, 0, len(*security))
+	for _, requirement := range *security {
+		schemes := make([]string, 0, len(requirement))
+		for scheme := range requirement {
+			schemes = append(schemes, scheme)
+		}
+		sort.Strings(schemes)
+		alts = append(alts, schemes)
+	}
+	return alts
+}
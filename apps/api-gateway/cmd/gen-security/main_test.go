@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The checked-in table must match the spec
+func TestGeneratedFileIsCurrent(t *testing.T) {
+	spec, err := os.ReadFile("../../../../api-specs/openapi.yaml")
+	require.NoError(t, err)
+	want, err := generate(spec)
+	require.NoError(t, err)
+
+	got, err := os.ReadFile("../../internal/api/security.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "internal/api/security.go is stale; run make generate-api")
+}
+
+func TestOperationSecurity(t *testing.T) {
+	table, err := operationSecurity([]byte(`
+security:
+  - bearerAuth: []
+paths:
+  /health:
+    get:
+      security: []
+  /rooms/{roomCode}/members/{userId}:
+    delete: {}
+    get:
+      security:
+        - {}
+        - bearerAuth: []
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][][]string{
+		"GET /health": {},
+		"DELETE /rooms/:roomCode/members/:userId": {{"bearerAuth"}},
+		"GET /rooms/:roomCode/members/:userId":    {{}, {"bearerAuth"}},
+	}, table)
+	assert.NotNil(t, table["GET /health"], "an explicit empty list overrides the document default")
+}
@@ -0,0 +1,367 @@
+// Command gen-wsevents writes the WebSocket event constants and payload types described by
+// the AsyncAPI document.
+//
+//	go run ./cmd/gen-wsevents ../../api-specs/asyncapi.yaml > internal/websocket/protocol.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// apiImport is where types referenced from openapi.yaml live
+const apiImport = "github.com/yourusername/cowatch/api-gateway/internal/api"
+
+// Operation actions are from the gateway's side: it receives client events and sends
+// server events
+const (
+	actionReceive = "receive"
+	actionSend    = "send"
+)
+
+var (
+	localSchema    = regexp.MustCompile(`^#/components/schemas/(\w+)$`)
+	openAPISchema  = regexp.MustCompile(`^\./openapi\.yaml#/components/schemas/(\w+)$`)
+	channelMessage = regexp.MustCompile(`^#/channels/(\w+)/messages/(\w+)$`)
+	componentMsg   = regexp.MustCompile(`^#/components/messages/(\w+)$`)
+	initialism     = regexp.MustCompile(`(Id|Url)([A-Z]|$)`)
+)
+
+type ref struct {
+	Ref string `yaml:"$ref"`
+}
+
+type document struct {
+	Channels map[string]struct {
+		Messages map[string]ref `yaml:"messages"`
+	} `yaml:"channels"`
+	Operations yaml.Node `yaml:"operations"`
+	Components struct {
+		Messages map[string]message `yaml:"messages"`
+		Schemas  yaml.Node          `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type operation struct {
+	Action   string `yaml:"action"`
+	Messages []ref  `yaml:"messages"`
+}
+
+type message struct {
+	Name    string `yaml:"name"`
+	Summary string `yaml:"summary"`
+	Payload ref    `yaml:"payload"`
+}
+
+type schema struct {
+	Ref         string    `yaml:"$ref"`
+	Type        string    `yaml:"type"`
+	Format      string    `yaml:"format"`
+	Description string    `yaml:"description"`
+	Properties  yaml.Node `yaml:"properties"`
+	Required    []string  `yaml:"required"`
+	Items       *schema   `yaml:"items"`
+}
+
+// event is a message the gateway sends or receives
+type event struct {
+	Const   string // Go constant, e.g. EventVideoPlay
+	Name    string // wire type, e.g. video:play
+	Summary string
+	Payload string // Go payload type
+}
+
+// protocol is everything the generated file declares
+type protocol struct {
+	Client, Server []event
+	Types          []goType
+	UsesAPI        bool
+}
+
+type goType struct {
+	Name        string
+	Description string
+	Fields      []field
+}
+
+type field struct {
+	Name, Type, Tag, Description string
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: gen-wsevents <asyncapi.yaml>")
+		os.Exit(2)
+	}
+	spec, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	out, err := generate(spec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(out)
+}
+
+// generate renders the Go protocol file for an AsyncAPI document
+func generate(spec []byte) ([]byte, error) {
+	p, err := parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	return render(p)
+}
+
+// parse collects the events of each operation and the schemas they use
+func parse(spec []byte) (*protocol, error) {
+	var doc document
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+
+	p := &protocol{}
+	err := eachPair(&doc.Operations, func(name string, node *yaml.Node) error {
+		var op operation
+		if err := node.Decode(&op); err != nil {
+			return fmt.Errorf("operation %s: %w", name, err)
+		}
+		events := make([]event, 0, len(op.Messages))
+		for _, m := range op.Messages {
+			ev, err := doc.event(m.Ref)
+			if err != nil {
+				return fmt.Errorf("operation %s: %w", name, err)
+			}
+			events = append(events, ev)
+		}
+		switch op.Action {
+		case actionReceive:
+			p.Client = append(p.Client, events...)
+		case actionSend:
+			p.Server = append(p.Server, events...)
+		default:
+			return fmt.Errorf("operation %s: unknown action %q", name, op.Action)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = eachPair(&doc.Components.Schemas, func(name string, node *yaml.Node) error {
+		var s schema
+		if err := node.Decode(&s); err != nil {
+			return fmt.Errorf("schema %s: %w", name, err)
+		}
+		t, err := p.structType(name, &s)
+		if err != nil {
+			return fmt.Errorf("schema %s: %w", name, err)
+		}
+		p.Types = append(p.Types, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(p.Types, func(i, j int) bool { return p.Types[i].Name < p.Types[j].Name })
+	return p, nil
+}
+
+// event resolves an operation's message reference through its channel to the component
+func (doc *document) event(messageRef string) (event, error) {
+	m := channelMessage.FindStringSubmatch(messageRef)
+	if m == nil {
+		return event{}, fmt.Errorf("unsupported message reference %q", messageRef)
+	}
+	target := doc.Channels[m[1]].Messages[m[2]].Ref
+	c := componentMsg.FindStringSubmatch(target)
+	if c == nil {
+		return event{}, fmt.Errorf("%s: unsupported message reference %q", messageRef, target)
+	}
+	msg, ok := doc.Components.Messages[c[1]]
+	if !ok {
+		return event{}, fmt.Errorf("%s: no component message %q", messageRef, c[1])
+	}
+	s := localSchema.FindStringSubmatch(msg.Payload.Ref)
+	if s == nil {
+		return event{}, fmt.Errorf("message %s: payload must reference a component schema", c[1])
+	}
+	return event{
+		Const:   "Event" + exported(c[1]),
+		Name:    msg.Name,
+		Summary: msg.Summary,
+		Payload: s[1],
+	}, nil
+}
+
+// structType converts an object schema to a Go struct
+func (p *protocol) structType(name string, s *schema) (goType, error) {
+	if s.Type != "object" {
+		return goType{}, fmt.Errorf("only object schemas are supported, got %q", s.Type)
+	}
+	required := make(map[string]bool, len(s.Required))
+	for _, r := range s.Required {
+		required[r] = true
+	}
+
+	t := goType{Name: name, Description: s.Description}
+	err := eachPair(&s.Properties, func(prop string, node *yaml.Node) error {
+		var ps schema
+		if err := node.Decode(&ps); err != nil {
+			return fmt.Errorf("%s: %w", prop, err)
+		}
+		typ, err := p.fieldType(&ps)
+		if err != nil {
+			return fmt.Errorf("%s: %w", prop, err)
+		}
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+			if !strings.HasPrefix(typ, "[]") {
+				typ = "*" + typ
+			}
+		}
+		t.Fields = append(t.Fields, field{
+			Name:        exported(prop),
+			Type:        typ,
+			Tag:         fmt.Sprintf("`json:%q`", tag),
+			Description: ps.Description,
+		})
+		return nil
+	})
+	return t, err
+}
+
+// fieldType is the Go type of a property schema
+func (p *protocol) fieldType(s *schema) (string, error) {
+	if s.Ref != "" {
+		if m := localSchema.FindStringSubmatch(s.Ref); m != nil {
+			return m[1], nil
+		}
+		if m := openAPISchema.FindStringSubmatch(s.Ref); m != nil {
+			p.UsesAPI = true
+			return "api." + m[1], nil
+		}
+		return "", fmt.Errorf("unsupported reference %q", s.Ref)
+	}
+
+	switch s.Type {
+	case "string":
+		return "string", nil
+	case "number":
+		return "float64", nil
+	case "integer":
+		if s.Format == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := p.fieldType(s.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	}
+	return "", fmt.Errorf("unsupported type %q; inline objects need a component schema", s.Type)
+}
+
+func render(p *protocol) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by cmd/gen-wsevents from api-specs/asyncapi.yaml. DO NOT EDIT.\n\npackage websocket\n\n")
+	if p.UsesAPI {
+		fmt.Fprintf(&buf, "import %q\n\n", apiImport)
+	}
+
+	writeEvents(&buf, "Client event types (客户端发送事件)", p.Client)
+	writeEvents(&buf, "Server event types (服务端推送事件)", p.Server)
+	writePayloads(&buf, "ClientEventPayloads maps each client event to the payload type it decodes into", p.Client)
+	writePayloads(&buf, "ServerEventPayloads maps each server event to the payload type it carries", p.Server)
+
+	for _, t := range p.Types {
+		if t.Description != "" {
+			writeComment(&buf, "", t.Name+" "+t.Description)
+		} else {
+			fmt.Fprintf(&buf, "// %s defines model for %s.\n", t.Name, t.Name)
+		}
+		if len(t.Fields) == 0 {
+			fmt.Fprintf(&buf, "type %s struct{}\n\n", t.Name)
+			continue
+		}
+		fmt.Fprintf(&buf, "type %s struct {\n", t.Name)
+		for _, f := range t.Fields {
+			if f.Description != "" {
+				writeComment(&buf, "\t", f.Name+" "+f.Description)
+			}
+			fmt.Fprintf(&buf, "\t%s %s %s\n", f.Name, f.Type, f.Tag)
+		}
+		buf.WriteString("}\n\n")
+	}
+	return format.Source(buf.Bytes())
+}
+
+func writeEvents(buf *bytes.Buffer, comment string, events []event) {
+	fmt.Fprintf(buf, "// %s\nconst (\n", comment)
+	for _, ev := range events {
+		if ev.Summary != "" {
+			writeComment(buf, "\t", ev.Const+" "+ev.Summary)
+		}
+		fmt.Fprintf(buf, "\t%s = %q\n", ev.Const, ev.Name)
+	}
+	buf.WriteString(")\n\n")
+}
+
+func writePayloads(buf *bytes.Buffer, comment string, events []event) {
+	fmt.Fprintf(buf, "// %s\nvar %s = map[string]any{\n", comment, strings.Fields(comment)[0])
+	for _, ev := range events {
+		fmt.Fprintf(buf, "\t%s: %s{},\n", ev.Const, ev.Payload)
+	}
+	buf.WriteString("}\n\n")
+}
+
+func writeComment(buf *bytes.Buffer, indent, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		fmt.Fprintf(buf, "%s// %s\n", indent, line)
+	}
+}
+
+// eachPair calls fn for each key of a YAML mapping in document order
+func eachPair(node *yaml.Node, fn func(key string, value *yaml.Node) error) error {
+	if node.Kind == 0 {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if err := fn(node.Content[i].Value, node.Content[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exported turns a camelCase name into an exported Go identifier, e.g. videoId -> VideoID
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+	return initialism.ReplaceAllStringFunc(name, func(m string) string {
+		parts := initialism.FindStringSubmatch(m)
+		return strings.ToUpper(parts[1]) + parts[2]
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The checked-in types must match the spec
+func TestGeneratedFileIsCurrent(t *testing.T) {
+	spec, err := os.ReadFile("../../../../api-specs/asyncapi.yaml")
+	require.NoError(t, err)
+	want, err := generate(spec)
+	require.NoError(t, err)
+
+	got, err := os.ReadFile("../../internal/websocket/protocol.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "internal/websocket/protocol.go is stale; run make generate-api")
+}
+
+const testSpec = `
+channels:
+  room:
+    messages:
+      ping:
+        $ref: '#/components/messages/ping'
+      pong:
+        $ref: '#/components/messages/pong'
+operations:
+  in:
+    action: receive
+    messages:
+      - $ref: '#/channels/room/messages/ping'
+  out:
+    action: send
+    messages:
+      - $ref: '#/channels/room/messages/pong'
+components:
+  messages:
+    ping:
+      name: test:ping
+      payload:
+        $ref: '#/components/schemas/Ping'
+    pong:
+      name: test:pong
+      summary: Reply
+      payload:
+        $ref: '#/components/schemas/Pong'
+  schemas:
+    Ping:
+      type: object
+    Pong:
+      type: object
+      properties:
+        userId:
+          type: string
+        avatarUrl:
+          type: string
+        sentAt:
+          type: integer
+          format: int64
+        tags:
+          type: array
+          items:
+            type: string
+        user:
+          $ref: './openapi.yaml#/components/schemas/User'
+      required: [userId, user]
+`
+
+func TestParse(t *testing.T) {
+	p, err := parse([]byte(testSpec))
+	require.NoError(t, err)
+
+	assert.Equal(t, []event{{Const: "EventPing", Name: "test:ping", Payload: "Ping"}}, p.Client)
+	assert.Equal(t, []event{{Const: "EventPong", Name: "test:pong", Summary: "Reply", Payload: "Pong"}}, p.Server)
+	assert.True(t, p.UsesAPI)
+
+	require.Len(t, p.Types, 2)
+	assert.Empty(t, p.Types[0].Fields)
+	assert.Equal(t, []field{
+		{Name: "UserID", Type: "string", Tag: "`json:\"userId\"`"},
+		{Name: "AvatarURL", Type: "*string", Tag: "`json:\"avatarUrl,omitempty\"`"},
+		{Name: "SentAt", Type: "*int64", Tag: "`json:\"sentAt,omitempty\"`"},
+		{Name: "Tags", Type: "[]string", Tag: "`json:\"tags,omitempty\"`"},
+		{Name: "User", Type: "api.User", Tag: "`json:\"user\"`"},
+	}, p.Types[1].Fields, "optional fields are pointers unless they are slices")
+}
+
+func TestParseRejectsInlineObjects(t *testing.T) {
+	_, err := parse([]byte(`
+components:
+  schemas:
+    Outer:
+      type: object
+      properties:
+        inner:
+          type: object
+`))
+	assert.ErrorContains(t, err, "Outer: inner")
+}
@@ -10,10 +10,18 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/oapi-codegen/runtime"
+	openapi_types "github.com/oapi-codegen/runtime/types"
 )
 
 const (
 	BearerAuthScopes = "bearerAuth.Scopes"
+	GuestAuthScopes  = "guestAuth.Scopes"
+)
+
+// Defines values for DeleteAccountRequestOwnedRooms.
+const (
+	Close    DeleteAccountRequestOwnedRooms = "close"
+	Transfer DeleteAccountRequestOwnedRooms = "transfer"
 )
 
 // Defines values for ParseVideoRequestType.
@@ -30,6 +38,13 @@ const (
 	Member RoomCurrentUserRole = "member"
 )
 
+// Defines values for RoomVisibility.
+const (
+	Private  RoomVisibility = "private"
+	Public   RoomVisibility = "public"
+	Unlisted RoomVisibility = "unlisted"
+)
+
 // Defines values for VideoSourceType.
 const (
 	VideoSourceTypeBilibili VideoSourceType = "bilibili"
@@ -37,34 +52,202 @@ const (
 	VideoSourceTypeYoutube  VideoSourceType = "youtube"
 )
 
+// Defines values for GetRoomsParamsSort.
+const (
+	Activity GetRoomsParamsSort = "activity"
+	Newest   GetRoomsParamsSort = "newest"
+	Online   GetRoomsParamsSort = "online"
+)
+
+// Defines values for GetUsersMeExportParamsFormat.
+const (
+	Json GetUsersMeExportParamsFormat = "json"
+	Zip  GetUsersMeExportParamsFormat = "zip"
+)
+
 // AuthResponse defines model for AuthResponse.
 type AuthResponse struct {
-	// Token JWT 认证令牌
+	// ExpiresIn 访问令牌有效期（秒）
+	ExpiresIn int `json:"expiresIn"`
+
+	// RefreshToken 刷新令牌，用于换取新的访问令牌
+	RefreshToken string `json:"refreshToken"`
+
+	// Token JWT 访问令牌（短期有效）
 	Token string `json:"token"`
 	User  User   `json:"user"`
 }
 
+// ChangePasswordRequest defines model for ChangePasswordRequest.
+type ChangePasswordRequest struct {
+	NewPassword string `json:"newPassword"`
+
+	// OldPassword 通过第三方登录创建、尚未设置密码的账号可留空
+	OldPassword string `json:"oldPassword"`
+}
+
+// CreateInviteRequest defines model for CreateInviteRequest.
+type CreateInviteRequest struct {
+	// ExpiresInSeconds 有效期（秒）
+	ExpiresInSeconds *int `json:"expiresInSeconds,omitempty"`
+
+	// GrantControl 接受邀请的用户是否直接获得播放控制权限
+	GrantControl *bool `json:"grantControl,omitempty"`
+
+	// MaxUses 最多可使用次数
+	MaxUses *int `json:"maxUses,omitempty"`
+}
+
 // CreateRoomRequest defines model for CreateRoomRequest.
 type CreateRoomRequest struct {
-	MaxUsers *int   `json:"maxUsers,omitempty"`
-	Name     string `json:"name"`
+	// AllowGuests 是否允许无账号的访客加入
+	AllowGuests *bool  `json:"allowGuests,omitempty"`
+	MaxUsers    *int   `json:"maxUsers,omitempty"`
+	Name        string `json:"name"`
 
 	// Password 可选的房间密码
 	Password *string `json:"password,omitempty"`
+
+	// Visibility 房间可见性：
+	// - public: 出现在房间列表中
+	// - unlisted: 不出现在列表中，可通过房间码访问
+	// - private: 仅房主和成员可见，非成员无法通过房间码加入
+	Visibility *RoomVisibility `json:"visibility,omitempty"`
+}
+
+// DeleteAccountRequest defines model for DeleteAccountRequest.
+type DeleteAccountRequest struct {
+	// OwnedRooms 自己创建的房间转让给其他成员（transfer）还是直接删除（close）
+	OwnedRooms *DeleteAccountRequestOwnedRooms `json:"ownedRooms,omitempty"`
+
+	// Password 当前密码，设置过密码的账号必填
+	Password *string `json:"password,omitempty"`
 }
 
+// DeleteAccountRequestOwnedRooms 自己创建的房间转让给其他成员（transfer）还是直接删除（close）
+type DeleteAccountRequestOwnedRooms string
+
 // Error defines model for Error.
 type Error struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 }
 
+// ExportedMembership defines model for ExportedMembership.
+type ExportedMembership struct {
+	HasControlPermission  bool      `json:"hasControlPermission"`
+	JoinedAt              time.Time `json:"joinedAt"`
+	LastVisitedAt         time.Time `json:"lastVisitedAt"`
+	LastWatchedVideoTitle *string   `json:"lastWatchedVideoTitle,omitempty"`
+	RoomCode              *string   `json:"roomCode,omitempty"`
+	RoomId                string    `json:"roomId"`
+	RoomName              *string   `json:"roomName,omitempty"`
+}
+
+// ExportedMessage defines model for ExportedMessage.
+type ExportedMessage struct {
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+	Id        string    `json:"id"`
+	RoomId    string    `json:"roomId"`
+}
+
+// ExportedProfile defines model for ExportedProfile.
+type ExportedProfile struct {
+	AvatarUrl *string   `json:"avatarUrl,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Id        string    `json:"id"`
+	IsAdmin   bool      `json:"isAdmin"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Username  string    `json:"username"`
+}
+
+// ExportedRoom defines model for ExportedRoom.
+type ExportedRoom struct {
+	AllowGuests bool      `json:"allowGuests"`
+	Code        string    `json:"code"`
+	CreatedAt   time.Time `json:"createdAt"`
+	HasPassword bool      `json:"hasPassword"`
+	Id          string    `json:"id"`
+	IsActive    bool      `json:"isActive"`
+	Name        string    `json:"name"`
+	Visibility  string    `json:"visibility"`
+}
+
+// ExportedSession defines model for ExportedSession.
+type ExportedSession struct {
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	Id        string     `json:"id"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// GuestAuthResponse defines model for GuestAuthResponse.
+type GuestAuthResponse struct {
+	// ExpiresIn 访客令牌有效期（秒）
+	ExpiresIn int       `json:"expiresIn"`
+	Guest     RoomGuest `json:"guest"`
+	Room      Room      `json:"room"`
+
+	// Token 访客令牌，仅对该房间有效
+	Token string `json:"token"`
+}
+
+// GuestJoinRequest defines model for GuestJoinRequest.
+type GuestJoinRequest struct {
+	Nickname string `json:"nickname"`
+
+	// Password 房间密码（如果需要）
+	Password *string `json:"password,omitempty"`
+}
+
+// JobStatus defines model for JobStatus.
+type JobStatus struct {
+	// IntervalSeconds 执行间隔（秒）
+	IntervalSeconds int `json:"intervalSeconds"`
+
+	// LastDurationMs 上次执行耗时（毫秒）
+	LastDurationMs *int `json:"lastDurationMs,omitempty"`
+
+	// LastError 上次执行的错误信息
+	LastError *string    `json:"lastError,omitempty"`
+	LastRunAt *time.Time `json:"lastRunAt,omitempty"`
+	Name      string     `json:"name"`
+	NextRunAt time.Time  `json:"nextRunAt"`
+	RunCount  int        `json:"runCount"`
+	Running   bool       `json:"running"`
+}
+
+// LinkedIdentity defines model for LinkedIdentity.
+type LinkedIdentity struct {
+	Email    *string   `json:"email,omitempty"`
+	LinkedAt time.Time `json:"linkedAt"`
+	Provider string    `json:"provider"`
+}
+
 // LoginRequest defines model for LoginRequest.
 type LoginRequest struct {
 	Password string `json:"password"`
 	Username string `json:"username"`
 }
 
+// OIDCAuthorizeResponse defines model for OIDCAuthorizeResponse.
+type OIDCAuthorizeResponse struct {
+	AuthorizationUrl string `json:"authorizationUrl"`
+}
+
+// OIDCCallbackRequest defines model for OIDCCallbackRequest.
+type OIDCCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// OIDCProvider defines model for OIDCProvider.
+type OIDCProvider struct {
+	DisplayName string `json:"displayName"`
+	Name        string `json:"name"`
+}
+
 // ParseVideoRequest defines model for ParseVideoRequest.
 type ParseVideoRequest struct {
 	// Type 视频源类型，如果不提供会自动识别
@@ -82,6 +265,11 @@ type RecentRoom struct {
 	Room                  Room      `json:"room"`
 }
 
+// RefreshRequest defines model for RefreshRequest.
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
 // RegisterRequest defines model for RegisterRequest.
 type RegisterRequest struct {
 	Password string `json:"password"`
@@ -90,6 +278,9 @@ type RegisterRequest struct {
 
 // Room defines model for Room.
 type Room struct {
+	// AllowGuests 是否允许无账号的访客加入
+	AllowGuests *bool `json:"allowGuests,omitempty"`
+
 	// Code 8位大写房间码，用于加入房间
 	Code      string     `json:"code"`
 	CreatedAt *time.Time `json:"createdAt,omitempty"`
@@ -105,18 +296,62 @@ type Room struct {
 	HasPassword *bool `json:"hasPassword,omitempty"`
 
 	// Id 房间内部 ID
-	Id        string  `json:"id"`
-	IsActive  bool    `json:"isActive"`
+	Id       string `json:"id"`
+	IsActive bool   `json:"isActive"`
+
+	// IsPlaying 房间当前是否正在播放
+	IsPlaying *bool   `json:"isPlaying,omitempty"`
 	MaxUsers  *int    `json:"maxUsers,omitempty"`
 	Name      string  `json:"name"`
 	OwnerId   string  `json:"ownerId"`
 	OwnerName *string `json:"ownerName,omitempty"`
 	UserCount *int    `json:"userCount,omitempty"`
+
+	// Visibility 房间可见性：
+	// - public: 出现在房间列表中
+	// - unlisted: 不出现在列表中，可通过房间码访问
+	// - private: 仅房主和成员可见，非成员无法通过房间码加入
+	Visibility *RoomVisibility `json:"visibility,omitempty"`
 }
 
 // RoomCurrentUserRole 当前用户在此房间的角色
 type RoomCurrentUserRole string
 
+// RoomGuest defines model for RoomGuest.
+type RoomGuest struct {
+	Id       string `json:"id"`
+	Nickname string `json:"nickname"`
+}
+
+// RoomInvite defines model for RoomInvite.
+type RoomInvite struct {
+	ExpiresAt    time.Time `json:"expiresAt"`
+	GrantControl bool      `json:"grantControl"`
+	MaxUses      int       `json:"maxUses"`
+	RoomCode     string    `json:"roomCode"`
+
+	// Token 邀请令牌，仅在创建时返回
+	Token string `json:"token"`
+	Uses  int    `json:"uses"`
+}
+
+// RoomVisibility 房间可见性：
+// - public: 出现在房间列表中
+// - unlisted: 不出现在列表中，可通过房间码访问
+// - private: 仅房主和成员可见，非成员无法通过房间码加入
+type RoomVisibility string
+
+// UpdateProfileRequest 只修改提供的字段
+type UpdateProfileRequest struct {
+	Username *string `json:"username,omitempty"`
+}
+
+// UpdateRoomRequest defines model for UpdateRoomRequest.
+type UpdateRoomRequest struct {
+	// AllowGuests 是否允许无账号的访客加入
+	AllowGuests *bool `json:"allowGuests,omitempty"`
+}
+
 // User defines model for User.
 type User struct {
 	AvatarUrl *string `json:"avatarUrl,omitempty"`
@@ -124,6 +359,17 @@ type User struct {
 	Username  string  `json:"username"`
 }
 
+// UserDataExport defines model for UserDataExport.
+type UserDataExport struct {
+	ExportedAt  time.Time            `json:"exportedAt"`
+	Identities  []LinkedIdentity     `json:"identities"`
+	Memberships []ExportedMembership `json:"memberships"`
+	Messages    []ExportedMessage    `json:"messages"`
+	OwnedRooms  []ExportedRoom       `json:"ownedRooms"`
+	Profile     ExportedProfile      `json:"profile"`
+	Sessions    []ExportedSession    `json:"sessions"`
+}
+
 // VideoSource defines model for VideoSource.
 type VideoSource struct {
 	// Duration 时长（秒）
@@ -141,18 +387,57 @@ type VideoSource struct {
 // VideoSourceType defines model for VideoSource.Type.
 type VideoSourceType string
 
+// WSTicket defines model for WSTicket.
+type WSTicket struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	// Ticket 一次性 WebSocket 连接票据
+	Ticket string `json:"ticket"`
+}
+
 // GetRoomsParams defines parameters for GetRooms.
 type GetRoomsParams struct {
 	Limit  *int `form:"limit,omitempty" json:"limit,omitempty"`
 	Offset *int `form:"offset,omitempty" json:"offset,omitempty"`
+
+	// Q 按房间名称搜索（不区分大小写）
+	Q *string `form:"q,omitempty" json:"q,omitempty"`
+
+	// HasPassword 按是否需要密码筛选
+	HasPassword *bool `form:"hasPassword,omitempty" json:"hasPassword,omitempty"`
+
+	// HasSpace 为 true 时仅返回在线人数未满的房间
+	HasSpace *bool `form:"hasSpace,omitempty" json:"hasSpace,omitempty"`
+
+	// IsPlaying 为 true 时仅返回正在播放视频的房间
+	IsPlaying *bool `form:"isPlaying,omitempty" json:"isPlaying,omitempty"`
+
+	// Sort 排序方式：newest 最新创建，online 在线人数，activity 最近活跃
+	Sort *GetRoomsParamsSort `form:"sort,omitempty" json:"sort,omitempty"`
 }
 
+// GetRoomsParamsSort defines parameters for GetRooms.
+type GetRoomsParamsSort string
+
 // PostRoomsRoomCodeJoinJSONBody defines parameters for PostRoomsRoomCodeJoin.
 type PostRoomsRoomCodeJoinJSONBody struct {
 	// Password 房间密码（如果需要）
 	Password *string `json:"password,omitempty"`
 }
 
+// PutUsersMeAvatarMultipartBody defines parameters for PutUsersMeAvatar.
+type PutUsersMeAvatarMultipartBody struct {
+	Avatar openapi_types.File `json:"avatar"`
+}
+
+// GetUsersMeExportParams defines parameters for GetUsersMeExport.
+type GetUsersMeExportParams struct {
+	Format *GetUsersMeExportParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+}
+
+// GetUsersMeExportParamsFormat defines parameters for GetUsersMeExport.
+type GetUsersMeExportParamsFormat string
+
 // GetUsersMeRecentRoomsParams defines parameters for GetUsersMeRecentRooms.
 type GetUsersMeRecentRoomsParams struct {
 	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
@@ -161,20 +446,53 @@ type GetUsersMeRecentRoomsParams struct {
 // PostAuthLoginJSONRequestBody defines body for PostAuthLogin for application/json ContentType.
 type PostAuthLoginJSONRequestBody = LoginRequest
 
+// PostAuthOidcProviderCallbackJSONRequestBody defines body for PostAuthOidcProviderCallback for application/json ContentType.
+type PostAuthOidcProviderCallbackJSONRequestBody = OIDCCallbackRequest
+
+// PostAuthRefreshJSONRequestBody defines body for PostAuthRefresh for application/json ContentType.
+type PostAuthRefreshJSONRequestBody = RefreshRequest
+
 // PostAuthRegisterJSONRequestBody defines body for PostAuthRegister for application/json ContentType.
 type PostAuthRegisterJSONRequestBody = RegisterRequest
 
 // PostRoomsJSONRequestBody defines body for PostRooms for application/json ContentType.
 type PostRoomsJSONRequestBody = CreateRoomRequest
 
+// PatchRoomsRoomCodeJSONRequestBody defines body for PatchRoomsRoomCode for application/json ContentType.
+type PatchRoomsRoomCodeJSONRequestBody = UpdateRoomRequest
+
+// PostRoomsRoomCodeGuestJSONRequestBody defines body for PostRoomsRoomCodeGuest for application/json ContentType.
+type PostRoomsRoomCodeGuestJSONRequestBody = GuestJoinRequest
+
+// PostRoomsRoomCodeInvitesJSONRequestBody defines body for PostRoomsRoomCodeInvites for application/json ContentType.
+type PostRoomsRoomCodeInvitesJSONRequestBody = CreateInviteRequest
+
 // PostRoomsRoomCodeJoinJSONRequestBody defines body for PostRoomsRoomCodeJoin for application/json ContentType.
 type PostRoomsRoomCodeJoinJSONRequestBody PostRoomsRoomCodeJoinJSONBody
 
+// DeleteUsersMeJSONRequestBody defines body for DeleteUsersMe for application/json ContentType.
+type DeleteUsersMeJSONRequestBody = DeleteAccountRequest
+
+// PatchUsersMeJSONRequestBody defines body for PatchUsersMe for application/json ContentType.
+type PatchUsersMeJSONRequestBody = UpdateProfileRequest
+
+// PutUsersMeAvatarMultipartRequestBody defines body for PutUsersMeAvatar for multipart/form-data ContentType.
+type PutUsersMeAvatarMultipartRequestBody PutUsersMeAvatarMultipartBody
+
+// PostUsersMeIdentitiesProviderJSONRequestBody defines body for PostUsersMeIdentitiesProvider for application/json ContentType.
+type PostUsersMeIdentitiesProviderJSONRequestBody = OIDCCallbackRequest
+
+// PutUsersMePasswordJSONRequestBody defines body for PutUsersMePassword for application/json ContentType.
+type PutUsersMePasswordJSONRequestBody = ChangePasswordRequest
+
 // PostVideosParseJSONRequestBody defines body for PostVideosParse for application/json ContentType.
 type PostVideosParseJSONRequestBody = ParseVideoRequest
 
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
+	// 获取后台任务状态
+	// (GET /admin/jobs)
+	GetAdminJobs(c *gin.Context)
 	// 用户登录
 	// (POST /auth/login)
 	PostAuthLogin(c *gin.Context)
@@ -184,9 +502,24 @@ type ServerInterface interface {
 	// 获取当前登录用户信息
 	// (GET /auth/me)
 	GetAuthMe(c *gin.Context)
+	// 获取可用的第三方登录提供方
+	// (GET /auth/oidc/providers)
+	GetAuthOidcProviders(c *gin.Context)
+	// 发起第三方登录
+	// (POST /auth/oidc/{provider}/authorize)
+	PostAuthOidcProviderAuthorize(c *gin.Context, provider string)
+	// 完成第三方登录
+	// (POST /auth/oidc/{provider}/callback)
+	PostAuthOidcProviderCallback(c *gin.Context, provider string)
+	// 刷新访问令牌
+	// (POST /auth/refresh)
+	PostAuthRefresh(c *gin.Context)
 	// 用户注册
 	// (POST /auth/register)
 	PostAuthRegister(c *gin.Context)
+	// 接受房间邀请
+	// (POST /invites/{token}/accept)
+	PostInvitesTokenAccept(c *gin.Context, token string)
 	// 获取房间列表
 	// (GET /rooms)
 	GetRooms(c *gin.Context, params GetRoomsParams)
@@ -196,9 +529,45 @@ type ServerInterface interface {
 	// 获取房间详情
 	// (GET /rooms/{roomCode})
 	GetRoomsRoomCode(c *gin.Context, roomCode string)
+	// 更新房间设置
+	// (PATCH /rooms/{roomCode})
+	PatchRoomsRoomCode(c *gin.Context, roomCode string)
+	// 以访客身份加入房间
+	// (POST /rooms/{roomCode}/guest)
+	PostRoomsRoomCodeGuest(c *gin.Context, roomCode string)
+	// 创建房间邀请链接
+	// (POST /rooms/{roomCode}/invites)
+	PostRoomsRoomCodeInvites(c *gin.Context, roomCode string)
 	// 加入房间
 	// (POST /rooms/{roomCode}/join)
 	PostRoomsRoomCodeJoin(c *gin.Context, roomCode string)
+	// 获取 WebSocket 连接票据
+	// (POST /rooms/{roomCode}/ws-ticket)
+	PostRoomsRoomCodeWsTicket(c *gin.Context, roomCode string)
+	// 注销账号
+	// (DELETE /users/me)
+	DeleteUsersMe(c *gin.Context)
+	// 修改当前用户资料
+	// (PATCH /users/me)
+	PatchUsersMe(c *gin.Context)
+	// 上传头像
+	// (PUT /users/me/avatar)
+	PutUsersMeAvatar(c *gin.Context)
+	// 导出个人数据
+	// (GET /users/me/export)
+	GetUsersMeExport(c *gin.Context, params GetUsersMeExportParams)
+	// 获取已绑定的第三方账号
+	// (GET /users/me/identities)
+	GetUsersMeIdentities(c *gin.Context)
+	// 解绑第三方账号
+	// (DELETE /users/me/identities/{provider})
+	DeleteUsersMeIdentitiesProvider(c *gin.Context, provider string)
+	// 绑定第三方账号
+	// (POST /users/me/identities/{provider})
+	PostUsersMeIdentitiesProvider(c *gin.Context, provider string)
+	// 修改密码
+	// (PUT /users/me/password)
+	PutUsersMePassword(c *gin.Context)
 	// 获取当前用户最近加入的房间
 	// (GET /users/me/recent-rooms)
 	GetUsersMeRecentRooms(c *gin.Context, params GetUsersMeRecentRoomsParams)
@@ -216,6 +585,21 @@ type ServerInterfaceWrapper struct {
 
 type MiddlewareFunc func(c *gin.Context)
 
+// GetAdminJobs operation middleware
+func (siw *ServerInterfaceWrapper) GetAdminJobs(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetAdminJobs(c)
+}
+
 // PostAuthLogin operation middleware
 func (siw *ServerInterfaceWrapper) PostAuthLogin(c *gin.Context) {
 
@@ -259,6 +643,82 @@ func (siw *ServerInterfaceWrapper) GetAuthMe(c *gin.Context) {
 	siw.Handler.GetAuthMe(c)
 }
 
+// GetAuthOidcProviders operation middleware
+func (siw *ServerInterfaceWrapper) GetAuthOidcProviders(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetAuthOidcProviders(c)
+}
+
+// PostAuthOidcProviderAuthorize operation middleware
+func (siw *ServerInterfaceWrapper) PostAuthOidcProviderAuthorize(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "provider" -------------
+	var provider string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "provider", c.Param("provider"), &provider, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter provider: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostAuthOidcProviderAuthorize(c, provider)
+}
+
+// PostAuthOidcProviderCallback operation middleware
+func (siw *ServerInterfaceWrapper) PostAuthOidcProviderCallback(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "provider" -------------
+	var provider string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "provider", c.Param("provider"), &provider, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter provider: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostAuthOidcProviderCallback(c, provider)
+}
+
+// PostAuthRefresh operation middleware
+func (siw *ServerInterfaceWrapper) PostAuthRefresh(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostAuthRefresh(c)
+}
+
 // PostAuthRegister operation middleware
 func (siw *ServerInterfaceWrapper) PostAuthRegister(c *gin.Context) {
 
@@ -272,11 +732,39 @@ func (siw *ServerInterfaceWrapper) PostAuthRegister(c *gin.Context) {
 	siw.Handler.PostAuthRegister(c)
 }
 
+// PostInvitesTokenAccept operation middleware
+func (siw *ServerInterfaceWrapper) PostInvitesTokenAccept(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "token" -------------
+	var token string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "token", c.Param("token"), &token, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter token: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostInvitesTokenAccept(c, token)
+}
+
 // GetRooms operation middleware
 func (siw *ServerInterfaceWrapper) GetRooms(c *gin.Context) {
 
 	var err error
 
+	c.Set(BearerAuthScopes, []string{})
+
 	// Parameter object where we will unmarshal all parameters from the context
 	var params GetRoomsParams
 
@@ -296,6 +784,46 @@ func (siw *ServerInterfaceWrapper) GetRooms(c *gin.Context) {
 		return
 	}
 
+	// ------------- Optional query parameter "q" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "q", c.Request.URL.Query(), &params.Q)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter q: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "hasPassword" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "hasPassword", c.Request.URL.Query(), &params.HasPassword)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter hasPassword: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "hasSpace" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "hasSpace", c.Request.URL.Query(), &params.HasSpace)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter hasSpace: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "isPlaying" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "isPlaying", c.Request.URL.Query(), &params.IsPlaying)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter isPlaying: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "sort" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "sort", c.Request.URL.Query(), &params.Sort)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter sort: %w", err), http.StatusBadRequest)
+		return
+	}
+
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -335,6 +863,8 @@ func (siw *ServerInterfaceWrapper) GetRoomsRoomCode(c *gin.Context) {
 		return
 	}
 
+	c.Set(BearerAuthScopes, []string{})
+
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -345,6 +875,82 @@ func (siw *ServerInterfaceWrapper) GetRoomsRoomCode(c *gin.Context) {
 	siw.Handler.GetRoomsRoomCode(c, roomCode)
 }
 
+// PatchRoomsRoomCode operation middleware
+func (siw *ServerInterfaceWrapper) PatchRoomsRoomCode(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "roomCode" -------------
+	var roomCode string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "roomCode", c.Param("roomCode"), &roomCode, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter roomCode: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PatchRoomsRoomCode(c, roomCode)
+}
+
+// PostRoomsRoomCodeGuest operation middleware
+func (siw *ServerInterfaceWrapper) PostRoomsRoomCodeGuest(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "roomCode" -------------
+	var roomCode string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "roomCode", c.Param("roomCode"), &roomCode, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter roomCode: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostRoomsRoomCodeGuest(c, roomCode)
+}
+
+// PostRoomsRoomCodeInvites operation middleware
+func (siw *ServerInterfaceWrapper) PostRoomsRoomCodeInvites(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "roomCode" -------------
+	var roomCode string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "roomCode", c.Param("roomCode"), &roomCode, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter roomCode: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostRoomsRoomCodeInvites(c, roomCode)
+}
+
 // PostRoomsRoomCodeJoin operation middleware
 func (siw *ServerInterfaceWrapper) PostRoomsRoomCodeJoin(c *gin.Context) {
 
@@ -371,6 +977,189 @@ func (siw *ServerInterfaceWrapper) PostRoomsRoomCodeJoin(c *gin.Context) {
 	siw.Handler.PostRoomsRoomCodeJoin(c, roomCode)
 }
 
+// PostRoomsRoomCodeWsTicket operation middleware
+func (siw *ServerInterfaceWrapper) PostRoomsRoomCodeWsTicket(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "roomCode" -------------
+	var roomCode string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "roomCode", c.Param("roomCode"), &roomCode, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter roomCode: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	c.Set(GuestAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostRoomsRoomCodeWsTicket(c, roomCode)
+}
+
+// DeleteUsersMe operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersMe(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.DeleteUsersMe(c)
+}
+
+// PatchUsersMe operation middleware
+func (siw *ServerInterfaceWrapper) PatchUsersMe(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PatchUsersMe(c)
+}
+
+// PutUsersMeAvatar operation middleware
+func (siw *ServerInterfaceWrapper) PutUsersMeAvatar(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PutUsersMeAvatar(c)
+}
+
+// GetUsersMeExport operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersMeExport(c *gin.Context) {
+
+	var err error
+
+	c.Set(BearerAuthScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetUsersMeExportParams
+
+	// ------------- Optional query parameter "format" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "format", c.Request.URL.Query(), &params.Format)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter format: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetUsersMeExport(c, params)
+}
+
+// GetUsersMeIdentities operation middleware
+func (siw *ServerInterfaceWrapper) GetUsersMeIdentities(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetUsersMeIdentities(c)
+}
+
+// DeleteUsersMeIdentitiesProvider operation middleware
+func (siw *ServerInterfaceWrapper) DeleteUsersMeIdentitiesProvider(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "provider" -------------
+	var provider string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "provider", c.Param("provider"), &provider, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter provider: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.DeleteUsersMeIdentitiesProvider(c, provider)
+}
+
+// PostUsersMeIdentitiesProvider operation middleware
+func (siw *ServerInterfaceWrapper) PostUsersMeIdentitiesProvider(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "provider" -------------
+	var provider string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "provider", c.Param("provider"), &provider, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter provider: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostUsersMeIdentitiesProvider(c, provider)
+}
+
+// PutUsersMePassword operation middleware
+func (siw *ServerInterfaceWrapper) PutUsersMePassword(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PutUsersMePassword(c)
+}
+
 // GetUsersMeRecentRooms operation middleware
 func (siw *ServerInterfaceWrapper) GetUsersMeRecentRooms(c *gin.Context) {
 
@@ -402,6 +1191,8 @@ func (siw *ServerInterfaceWrapper) GetUsersMeRecentRooms(c *gin.Context) {
 // PostVideosParse operation middleware
 func (siw *ServerInterfaceWrapper) PostVideosParse(c *gin.Context) {
 
+	c.Set(BearerAuthScopes, []string{})
+
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -439,14 +1230,32 @@ func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options
 		ErrorHandler:       errorHandler,
 	}
 
+	router.GET(options.BaseURL+"/admin/jobs", wrapper.GetAdminJobs)
 	router.POST(options.BaseURL+"/auth/login", wrapper.PostAuthLogin)
 	router.POST(options.BaseURL+"/auth/logout", wrapper.PostAuthLogout)
 	router.GET(options.BaseURL+"/auth/me", wrapper.GetAuthMe)
+	router.GET(options.BaseURL+"/auth/oidc/providers", wrapper.GetAuthOidcProviders)
+	router.POST(options.BaseURL+"/auth/oidc/:provider/authorize", wrapper.PostAuthOidcProviderAuthorize)
+	router.POST(options.BaseURL+"/auth/oidc/:provider/callback", wrapper.PostAuthOidcProviderCallback)
+	router.POST(options.BaseURL+"/auth/refresh", wrapper.PostAuthRefresh)
 	router.POST(options.BaseURL+"/auth/register", wrapper.PostAuthRegister)
+	router.POST(options.BaseURL+"/invites/:token/accept", wrapper.PostInvitesTokenAccept)
 	router.GET(options.BaseURL+"/rooms", wrapper.GetRooms)
 	router.POST(options.BaseURL+"/rooms", wrapper.PostRooms)
 	router.GET(options.BaseURL+"/rooms/:roomCode", wrapper.GetRoomsRoomCode)
+	router.PATCH(options.BaseURL+"/rooms/:roomCode", wrapper.PatchRoomsRoomCode)
+	router.POST(options.BaseURL+"/rooms/:roomCode/guest", wrapper.PostRoomsRoomCodeGuest)
+	router.POST(options.BaseURL+"/rooms/:roomCode/invites", wrapper.PostRoomsRoomCodeInvites)
 	router.POST(options.BaseURL+"/rooms/:roomCode/join", wrapper.PostRoomsRoomCodeJoin)
+	router.POST(options.BaseURL+"/rooms/:roomCode/ws-ticket", wrapper.PostRoomsRoomCodeWsTicket)
+	router.DELETE(options.BaseURL+"/users/me", wrapper.DeleteUsersMe)
+	router.PATCH(options.BaseURL+"/users/me", wrapper.PatchUsersMe)
+	router.PUT(options.BaseURL+"/users/me/avatar", wrapper.PutUsersMeAvatar)
+	router.GET(options.BaseURL+"/users/me/export", wrapper.GetUsersMeExport)
+	router.GET(options.BaseURL+"/users/me/identities", wrapper.GetUsersMeIdentities)
+	router.DELETE(options.BaseURL+"/users/me/identities/:provider", wrapper.DeleteUsersMeIdentitiesProvider)
+	router.POST(options.BaseURL+"/users/me/identities/:provider", wrapper.PostUsersMeIdentitiesProvider)
+	router.PUT(options.BaseURL+"/users/me/password", wrapper.PutUsersMePassword)
 	router.GET(options.BaseURL+"/users/me/recent-rooms", wrapper.GetUsersMeRecentRooms)
 	router.POST(options.BaseURL+"/videos/parse", wrapper.PostVideosParse)
 }
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourusername/cowatch/pkg/authtoken"
+)
+
+// LoadPrivateKeys reads every *.pem file in dir as a signing key, using the file name
+// (without extension) as the key ID. Keys may be PKCS#8 (RSA or Ed25519) or PKCS#1 RSA.
+func LoadPrivateKeys(dir string) (map[string]crypto.Signer, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.Signer, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %s: %w", filepath.Base(path), err)
+		}
+		keys[strings.TrimSuffix(filepath.Base(path), ".pem")] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("auth: no *.pem keys found in %s", dir)
+	}
+	return keys, nil
+}
+
+// ParsePrivateKey decodes a PEM-encoded RSA or Ed25519 private key
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		if _, err := authtoken.SigningMethodFor(signer.Public()); err != nil {
+			return nil, err
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
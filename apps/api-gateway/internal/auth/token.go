@@ -0,0 +1,289 @@
+// Package auth issues and validates access tokens shared by the REST API and WebSocket handshake
+package auth
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+	"github.com/yourusername/cowatch/pkg/authtoken"
+)
+
+var (
+	// ErrSessionRevoked is returned when a token's session has been revoked or has expired
+	ErrSessionRevoked = errors.New("session revoked")
+
+	// ErrUnknownKey is returned when a token was signed with a key ID we don't have
+	ErrUnknownKey = errors.New("unknown signing key")
+)
+
+// Claims are the claims carried by an access token; the type is shared with other services via authtoken
+type Claims = authtoken.Claims
+
+// signingKey pairs a key with the one algorithm it may be used with.
+// Checking the token's alg against it stops "none" and algorithm-confusion attacks.
+type signingKey struct {
+	method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+	public crypto.PublicKey // nil for HMAC secrets, which are never published
+}
+
+// Config configures a TokenService
+type Config struct {
+	// Secrets maps key IDs to HMAC secrets. Every key is accepted for verification,
+	// so old keys can stay listed until tokens signed with them have expired.
+	Secrets map[string]string
+
+	// PrivateKeys maps key IDs to RSA or Ed25519 keys; their public halves are served as a JWKS
+	PrivateKeys map[string]crypto.Signer
+
+	// ActiveKeyID selects the key used to sign new tokens
+	ActiveKeyID string
+
+	Issuer    string
+	Audience  string
+	AccessTTL time.Duration
+}
+
+// TokenService signs and verifies access tokens
+type TokenService struct {
+	keys        map[string]signingKey
+	activeKeyID string
+	issuer      string
+	audience    string
+	accessTTL   time.Duration
+	parser      *jwt.Parser
+}
+
+// NewTokenService validates cfg and creates a TokenService
+func NewTokenService(cfg Config) (*TokenService, error) {
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("auth: issuer and audience are required")
+	}
+	if cfg.AccessTTL <= 0 {
+		return nil, errors.New("auth: access token TTL must be positive")
+	}
+
+	keys := make(map[string]signingKey, len(cfg.Secrets)+len(cfg.PrivateKeys))
+	for kid, secret := range cfg.Secrets {
+		if secret == "" {
+			return nil, fmt.Errorf("auth: secret for key %q is empty", kid)
+		}
+		keys[kid] = signingKey{method: jwt.SigningMethodHS256, sign: []byte(secret), verify: []byte(secret)}
+	}
+	for kid, private := range cfg.PrivateKeys {
+		if _, dup := keys[kid]; dup {
+			return nil, fmt.Errorf("auth: key ID %q is used more than once", kid)
+		}
+		method, err := authtoken.SigningMethodFor(private.Public())
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %q: %w", kid, err)
+		}
+		keys[kid] = signingKey{method: method, sign: private, verify: private.Public(), public: private.Public()}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("auth: at least one signing key is required")
+	}
+	if _, ok := keys[cfg.ActiveKeyID]; !ok {
+		return nil, fmt.Errorf("auth: active key %q is not among the configured keys", cfg.ActiveKeyID)
+	}
+
+	methods := make([]string, 0, 3)
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+
+	return &TokenService{
+		keys:        keys,
+		activeKeyID: cfg.ActiveKeyID,
+		issuer:      cfg.Issuer,
+		audience:    cfg.Audience,
+		accessTTL:   cfg.AccessTTL,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(methods),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+		),
+	}, nil
+}
+
+// AccessTTL returns the lifetime of issued access tokens
+func (s *TokenService) AccessTTL() time.Duration {
+	return s.accessTTL
+}
+
+// Issue creates a signed access token for user bound to a session
+func (s *TokenService) Issue(user *models.User, sessionID string) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{s.audience},
+			Subject:   user.ID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	key := s.keys[s.activeKeyID]
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = s.activeKeyID
+	return token.SignedString(key.sign)
+}
+
+// IssueGuest creates a room-scoped token for an anonymous guest that expires with the guest record
+func (s *TokenService) IssueGuest(guest *models.RoomGuest) (string, error) {
+	claims := &Claims{
+		UserID:   guest.ID,
+		Username: guest.Nickname,
+		Guest:    true,
+		RoomID:   guest.RoomID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{s.audience},
+			Subject:   guest.ID,
+			ExpiresAt: jwt.NewNumericDate(guest.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	key := s.keys[s.activeKeyID]
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = s.activeKeyID
+	return token.SignedString(key.sign)
+}
+
+// SignMediaURL returns rawURL with a signature that lets userID GET its path from the media
+// service for ttl. Media URLs end up in video elements, logs and Referer headers, so they
+// carry their own audience and can't be replayed as access tokens. The media service
+// verifies them against the JWKS, so the active key must be RS256 or EdDSA.
+func (s *TokenService) SignMediaURL(userID, rawURL string, ttl time.Duration) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	key := s.keys[s.activeKeyID]
+	if key.public == nil {
+		return "", fmt.Errorf("auth: active key %q is not published, media URLs need an RS256 or EdDSA key", s.activeKeyID)
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID: userID,
+		Path:   u.Path,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{authtoken.MediaAudience(s.audience)},
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = s.activeKeyID
+	signed, err := token.SignedString(key.sign)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set(authtoken.MediaURLParam, signed)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Parse verifies a signed access token and returns its claims
+func (s *TokenService) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := s.parser.ParseWithClaims(tokenString, claims, s.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// JWKS returns the public keys other services need to verify tokens. HMAC secrets are never included.
+func (s *TokenService) JWKS() authtoken.JWKS {
+	set := authtoken.JWKS{Keys: []authtoken.JWK{}}
+	for kid, key := range s.keys {
+		if key.public == nil {
+			continue
+		}
+		if jwk, err := authtoken.PublicJWK(kid, key.public); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// keyFunc picks the verification key from the token's kid header
+func (s *TokenService) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", token.Header["alg"], kid)
+	}
+	return key.verify, nil
+}
+
+// LoadActiveGuest returns the guest a guest token belongs to, or ErrSessionRevoked if the
+// guest has expired or the host has since turned guest access off
+func LoadActiveGuest(db *gorm.DB, claims *Claims) (*models.RoomGuest, error) {
+	if !claims.Guest {
+		return nil, ErrSessionRevoked
+	}
+	return LoadGuest(db, claims.UserID, claims.RoomID)
+}
+
+// LoadGuest returns an unexpired guest of roomID whose room still allows guests
+func LoadGuest(db *gorm.DB, guestID, roomID string) (*models.RoomGuest, error) {
+	var guest models.RoomGuest
+	if err := db.Preload("Room").First(&guest, "id = ? AND room_id = ?", guestID, roomID).Error; err != nil {
+		return nil, ErrSessionRevoked
+	}
+	if guest.IsExpired(time.Now()) || guest.Room == nil || !guest.Room.IsActive || !guest.Room.AllowGuests {
+		return nil, ErrSessionRevoked
+	}
+	return &guest, nil
+}
+
+// LoadActiveSession returns the session a token belongs to, or ErrSessionRevoked
+// if the session no longer exists, was revoked, or belongs to another user
+func LoadActiveSession(db *gorm.DB, claims *Claims) (*models.AuthSession, error) {
+	if claims.Guest || claims.SessionID == "" {
+		return nil, ErrSessionRevoked
+	}
+
+	var session models.AuthSession
+	if err := db.First(&session, "id = ?", claims.SessionID).Error; err != nil {
+		return nil, ErrSessionRevoked
+	}
+	if session.UserID != claims.UserID || !session.IsActive(time.Now()) {
+		return nil, ErrSessionRevoked
+	}
+	return &session, nil
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+	"github.com/yourusername/cowatch/pkg/authtoken"
+)
+
+func newTestService(t *testing.T, secrets map[string]string, activeKeyID string) *TokenService {
+	s, err := NewTokenService(Config{
+		Secrets:     secrets,
+		ActiveKeyID: activeKeyID,
+		Issuer:      "cowatch-test",
+		Audience:    "cowatch",
+		AccessTTL:   time.Minute,
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func TestTokenServiceRoundTrip(t *testing.T) {
+	s := newTestService(t, map[string]string{"k1": "secret-1"}, "k1")
+	user := &models.User{ID: "user-1", Username: "alice"}
+
+	token, err := s.Issue(user, "session-1")
+	require.NoError(t, err)
+
+	claims, err := s.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, "alice", claims.Username)
+	assert.Equal(t, "session-1", claims.SessionID)
+}
+
+func TestTokenServiceKeyRotation(t *testing.T) {
+	user := &models.User{ID: "user-1", Username: "alice"}
+	old := newTestService(t, map[string]string{"k1": "secret-1"}, "k1")
+	rotated := newTestService(t, map[string]string{"k1": "secret-1", "k2": "secret-2"}, "k2")
+	retired := newTestService(t, map[string]string{"k2": "secret-2"}, "k2")
+
+	token, err := old.Issue(user, "session-1")
+	require.NoError(t, err)
+
+	_, err = rotated.Parse(token)
+	assert.NoError(t, err, "tokens signed with a still-listed key stay valid")
+
+	_, err = retired.Parse(token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestTokenServiceRejects(t *testing.T) {
+	s := newTestService(t, map[string]string{"k1": "secret-1"}, "k1")
+
+	sign := func(method jwt.SigningMethod, key interface{}, kid string, mutate func(*Claims)) string {
+		now := time.Now()
+		claims := &Claims{
+			UserID: "user-1",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "cowatch-test",
+				Audience:  jwt.ClaimStrings{"cowatch"},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+		}
+		if mutate != nil {
+			mutate(claims)
+		}
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	cases := map[string]string{
+		"none algorithm": sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "k1", nil),
+		"other HMAC":     sign(jwt.SigningMethodHS512, []byte("secret-1"), "k1", nil),
+		"missing kid":    sign(jwt.SigningMethodHS256, []byte("secret-1"), "", nil),
+		"wrong secret":   sign(jwt.SigningMethodHS256, []byte("nope"), "k1", nil),
+		"wrong issuer": sign(jwt.SigningMethodHS256, []byte("secret-1"), "k1", func(c *Claims) {
+			c.Issuer = "someone-else"
+		}),
+		"wrong audience": sign(jwt.SigningMethodHS256, []byte("secret-1"), "k1", func(c *Claims) {
+			c.Audience = jwt.ClaimStrings{"media-service"}
+		}),
+		"expired": sign(jwt.SigningMethodHS256, []byte("secret-1"), "k1", func(c *Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}),
+		"no expiry": sign(jwt.SigningMethodHS256, []byte("secret-1"), "k1", func(c *Claims) {
+			c.ExpiresAt = nil
+		}),
+	}
+
+	for name, token := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.Parse(token)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewTokenServiceValidatesConfig(t *testing.T) {
+	_, err := NewTokenService(Config{
+		Secrets:     map[string]string{"k1": "secret-1"},
+		ActiveKeyID: "k2",
+		Issuer:      "cowatch",
+		Audience:    "cowatch",
+		AccessTTL:   time.Minute,
+	})
+	assert.Error(t, err)
+}
+
+func TestTokenServiceAsymmetricKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	user := &models.User{ID: "user-1", Username: "alice"}
+	newService := func(active string) *TokenService {
+		s, err := NewTokenService(Config{
+			Secrets:     map[string]string{"hmac": "secret-1"},
+			PrivateKeys: map[string]crypto.Signer{"rsa": rsaKey, "ed": edKey},
+			ActiveKeyID: active,
+			Issuer:      "cowatch-test",
+			Audience:    "cowatch",
+			AccessTTL:   time.Minute,
+		})
+		require.NoError(t, err)
+		return s
+	}
+
+	for _, kid := range []string{"hmac", "rsa", "ed"} {
+		t.Run("signs with "+kid, func(t *testing.T) {
+			s := newService(kid)
+			token, err := s.Issue(user, "session-1")
+			require.NoError(t, err)
+			_, err = s.Parse(token)
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("JWKS publishes only public keys", func(t *testing.T) {
+		set := newService("rsa").JWKS()
+		require.Len(t, set.Keys, 2)
+		assert.Equal(t, "ed", set.Keys[0].Kid)
+		assert.Equal(t, "EdDSA", set.Keys[0].Alg)
+		assert.Equal(t, "rsa", set.Keys[1].Kid)
+		assert.Equal(t, "RS256", set.Keys[1].Alg)
+	})
+
+	t.Run("HMAC token can't claim an RSA key ID", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "cowatch-test",
+				Audience:  jwt.ClaimStrings{"cowatch"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		})
+		token.Header["kid"] = "rsa"
+		signed, err := token.SignedString([]byte("secret-1"))
+		require.NoError(t, err)
+
+		_, err = newService("rsa").Parse(signed)
+		assert.Error(t, err)
+	})
+}
+
+func TestSignMediaURL(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	s, err := NewTokenService(Config{
+		Secrets:     map[string]string{"hmac": "secret-1"},
+		PrivateKeys: map[string]crypto.Signer{"ed": edKey},
+		ActiveKeyID: "ed",
+		Issuer:      "cowatch-test",
+		Audience:    "cowatch",
+		AccessTTL:   time.Minute,
+	})
+	require.NoError(t, err)
+
+	signed, err := s.SignMediaURL("user-1", "http://media:8081/api/v1/streams/abc?quality=720", time.Minute)
+	require.NoError(t, err)
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/streams/abc", u.Path)
+	assert.Equal(t, "720", u.Query().Get("quality"))
+
+	_, err = s.Parse(u.Query().Get(authtoken.MediaURLParam))
+	assert.Error(t, err, "a media URL signature is not an access token")
+
+	_, err = newTestService(t, map[string]string{"hmac": "secret-1"}, "hmac").
+		SignMediaURL("user-1", "http://media:8081/api/v1/streams/abc", time.Minute)
+	assert.Error(t, err, "HMAC keys aren't published, so the media service couldn't verify the URL")
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+
+	key, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.Equal(t, edKey.Public(), key.Public())
+
+	_, err = ParsePrivateKey([]byte("not a key"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+// Package avatar validates uploaded profile pictures and normalizes them to a square PNG
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+
+	// Register decoders for the accepted upload formats
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// Size is the width and height of stored avatars in pixels
+	Size = 256
+
+	// ContentType is the format of stored avatars
+	ContentType = "image/png"
+
+	// maxDimension bounds the decoded image so a small file can't expand into a huge bitmap
+	maxDimension = 4096
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("avatar: unsupported image format")
+	ErrImageTooLarge     = errors.New("avatar: image dimensions too large")
+	ErrInvalidImage      = errors.New("avatar: invalid image")
+)
+
+// allowedTypes are the sniffed content types accepted for upload
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Process decodes an uploaded image, center-crops it to a square and resizes it to Size×Size PNG.
+// The format is detected from the content, not the client's declared type.
+func Process(data []byte) ([]byte, error) {
+	if !allowedTypes[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedFormat
+	}
+
+	// Check dimensions from the header before allocating the full bitmap
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width > maxDimension || cfg.Height > maxDimension {
+		return nil, ErrImageTooLarge
+	}
+	if cfg.Width == 0 || cfg.Height == 0 {
+		return nil, ErrInvalidImage
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, Size, Size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, squareCrop(src.Bounds()), draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// squareCrop returns the largest centered square within r
+func squareCrop(r image.Rectangle) image.Rectangle {
+	w, h := r.Dx(), r.Dy()
+	if w > h {
+		offset := (w - h) / 2
+		return image.Rect(r.Min.X+offset, r.Min.Y, r.Min.X+offset+h, r.Max.Y)
+	}
+	offset := (h - w) / 2
+	return image.Rect(r.Min.X, r.Min.Y+offset, r.Max.X, r.Min.Y+offset+w)
+}
@@ -0,0 +1,71 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("resizes to a square PNG", func(t *testing.T) {
+		out, err := Process(encodePNG(t, 600, 300))
+		require.NoError(t, err)
+
+		img, format, err := image.Decode(bytes.NewReader(out))
+		require.NoError(t, err)
+		assert.Equal(t, "png", format)
+		assert.Equal(t, image.Rect(0, 0, Size, Size), img.Bounds())
+	})
+
+	t.Run("accepts JPEG", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 80)), nil))
+
+		_, err := Process(buf.Bytes())
+		require.NoError(t, err)
+	})
+
+	t.Run("rejects non-images", func(t *testing.T) {
+		_, err := Process([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"))
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+
+	t.Run("rejects truncated images", func(t *testing.T) {
+		data := encodePNG(t, 10, 10)
+		_, err := Process(data[:20])
+		assert.ErrorIs(t, err, ErrInvalidImage)
+	})
+
+	t.Run("rejects huge dimensions", func(t *testing.T) {
+		// A tiny 1-bit-per-pixel image can still declare enormous dimensions
+		img := image.NewGray(image.Rect(0, 0, maxDimension+1, 1))
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, img))
+
+		_, err := Process(buf.Bytes())
+		assert.ErrorIs(t, err, ErrImageTooLarge)
+	})
+}
+
+func TestSquareCrop(t *testing.T) {
+	assert.Equal(t, image.Rect(50, 0, 150, 100), squareCrop(image.Rect(0, 0, 200, 100)))
+	assert.Equal(t, image.Rect(0, 50, 100, 150), squareCrop(image.Rect(0, 0, 100, 200)))
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func writeConfigFile(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	t.Setenv("CONFIG_FILE", path)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, Development, cfg.Environment)
+	assert.Equal(t, "8080", cfg.Server.Port)
+	assert.Equal(t, 15*time.Minute, cfg.Auth.AccessTokenTTL)
+	assert.Equal(t, map[string]string{"default": DefaultJWTSecret}, cfg.Auth.JWTSecrets)
+	assert.Equal(t, 256, cfg.WebSocket.SendBuffer)
+}
+
+func TestLoadFileThenEnv(t *testing.T) {
+	writeConfigFile(t, `
+server:
+  port: "9000"
+  cors:
+    allowed_origins: [https://watch.example.com, "https://*.preview.example.com"]
+database:
+  max_open_conns: 50
+auth:
+  access_token_ttl: 5m
+websocket:
+  max_message_size: 4096
+oidc_providers:
+  - name: corp
+    issuer: https://idp.example.com
+    client_id: cowatch
+    client_secret: from-file
+`)
+	t.Setenv("PORT", "9100")
+	t.Setenv("WS_PONG_TIMEOUT", "30s")
+	t.Setenv("OIDC_PROVIDERS", "corp,github")
+	t.Setenv("OIDC_CORP_CLIENT_SECRET", "from-env")
+	t.Setenv("OIDC_GITHUB_ISSUER", "https://github.example.com")
+	t.Setenv("OIDC_GITHUB_CLIENT_ID", "gh")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, "9100", cfg.Server.Port, "env overrides the file")
+	assert.Equal(t, []string{"https://watch.example.com", "https://*.preview.example.com"}, cfg.Server.CORS.AllowedOrigins)
+	assert.Equal(t, 2*time.Hour, cfg.Server.CORS.MaxAge)
+	assert.Equal(t, 50, cfg.Database.MaxOpenConns)
+	assert.Equal(t, 5, cfg.Database.MaxIdleConns, "unset keys keep their defaults")
+	assert.Equal(t, 5*time.Minute, cfg.Auth.AccessTokenTTL)
+	assert.Equal(t, int64(4096), cfg.WebSocket.MaxMessageSize)
+	assert.Equal(t, 30*time.Second, cfg.WebSocket.PongTimeout)
+
+	require.Len(t, cfg.OIDCProviders, 2)
+	assert.Equal(t, "from-env", cfg.OIDCProviders[0].ClientSecret)
+	assert.Equal(t, "https://idp.example.com", cfg.OIDCProviders[0].Issuer)
+	assert.Equal(t, "github", cfg.OIDCProviders[1].Name)
+	assert.Equal(t, "github", cfg.OIDCProviders[1].DisplayName)
+}
+
+func TestLoadRejectsBadInput(t *testing.T) {
+	t.Run("unknown file key", func(t *testing.T) {
+		writeConfigFile(t, "server:\n  prot: \"9000\"\n")
+		_, err := Load()
+		assert.ErrorContains(t, err, "prot")
+	})
+
+	t.Run("unparseable env value", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", "")
+		t.Setenv("ACCESS_TOKEN_TTL", "fifteen minutes")
+		_, err := Load()
+		assert.ErrorContains(t, err, "ACCESS_TOKEN_TTL")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+		want   string
+	}{
+		{"bad port", func(c *Config) { c.Server.Port = "http" }, "server.port"},
+		{"metrics on the public port", func(c *Config) { c.Server.MetricsPort = c.Server.Port }, "server.metrics_port"},
+		{"no shutdown timeout", func(c *Config) { c.Server.ShutdownTimeout = 0 }, "server.shutdown_timeout"},
+		{"bad origin", func(c *Config) { c.Server.CORS.AllowedOrigins = []string{"example.com"} }, "server.cors.allowed_origins"},
+		{"inner wildcard", func(c *Config) { c.Server.CORS.AllowedOrigins = []string{"https://app.*.com"} }, "server.cors.allowed_origins"},
+		{"any origin with credentials", func(c *Config) {
+			c.Server.CORS.AllowedOrigins = []string{"*"}
+			c.Server.CORS.AllowCredentials = true
+		}, "allow_credentials"},
+		{"idle above open", func(c *Config) { c.Database.MaxIdleConns = 100 }, "max_idle_conns"},
+		{"refresh shorter than access", func(c *Config) { c.Auth.RefreshTokenTTL = time.Minute }, "refresh_token_ttl"},
+		{"no message limit", func(c *Config) { c.WebSocket.MaxMessageSize = 0 }, "websocket.max_message_size"},
+		{"rate without burst", func(c *Config) { c.WebSocket.EventBurst = 0 }, "websocket.event_burst"},
+		{"compression level", func(c *Config) { c.WebSocket.CompressionLevel = 0 }, "websocket.compression_level"},
+		{"s3 without bucket", func(c *Config) { c.Storage.Backend = "s3" }, "storage.s3"},
+		{"bad media url", func(c *Config) { c.MediaService.URL = "media:8081" }, "media_service.url"},
+		{"unknown exporter", func(c *Config) { c.Tracing.Exporter = "jaeger" }, "tracing.exporter"},
+		{"sample ratio above one", func(c *Config) { c.Tracing.SampleRatio = 2 }, "tracing.sample_ratio"},
+		{"incomplete provider", func(c *Config) { c.OIDCProviders = []OIDCProvider{{Name: "corp"}} }, "needs an issuer"},
+		{"default secret in production", func(c *Config) { c.Environment = Production }, "default JWT secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Defaults()
+			tt.mutate(cfg)
+			assert.ErrorContains(t, cfg.Validate(), tt.want)
+		})
+	}
+
+	t.Run("reports every problem", func(t *testing.T) {
+		cfg := Defaults()
+		cfg.Server.Port = ""
+		cfg.Cleanup.Interval = 0
+		err := cfg.Validate()
+		assert.ErrorContains(t, err, "server.port")
+		assert.ErrorContains(t, err, "cleanup.interval")
+	})
+
+	t.Run("production with a real secret", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", "")
+		t.Setenv("APP_ENV", Production)
+		t.Setenv("JWT_SECRET", "a-long-random-production-secret")
+		_, err := Load()
+		assert.NoError(t, err)
+
+		t.Setenv("JWT_SECRETS", "old:"+DefaultJWTSecret+",new:another-secret")
+		t.Setenv("JWT_ACTIVE_KID", "new")
+		_, err = Load()
+		assert.ErrorContains(t, err, "default JWT secret", "a rotated-out default key is still accepted, so it's refused too")
+	})
+}
+
+func TestRandomizeDefaultSecrets(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("JWT_SECRETS", "old:"+DefaultJWTSecret+",new:another-secret")
+	cfg, err := Read()
+	require.NoError(t, err)
+
+	randomized, err := cfg.RandomizeDefaultSecrets()
+	require.NoError(t, err)
+	assert.True(t, randomized)
+	assert.NotEqual(t, DefaultJWTSecret, cfg.Auth.JWTSecrets["old"])
+	assert.Len(t, cfg.Auth.JWTSecrets["old"], 43)
+	assert.Equal(t, "another-secret", cfg.Auth.JWTSecrets["new"], "configured secrets are kept")
+
+	again, err := Defaults().RandomizeDefaultSecrets()
+	require.NoError(t, err)
+	assert.False(t, again, "nothing to replace before the default key is filled in")
+
+	t.Setenv("JWT_SECRETS", "")
+	t.Setenv("JWT_SECRET", "a-configured-secret")
+	cfg, err = Read()
+	require.NoError(t, err)
+	randomized, err = cfg.RandomizeDefaultSecrets()
+	require.NoError(t, err)
+	assert.False(t, randomized)
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Defaults()
+	cfg.Database.URL = "postgresql://cowatch:hunter2@db:5432/cowatch?sslmode=disable"
+	cfg.Auth.JWTSecrets = map[string]string{"2024a": "s3cret"}
+	cfg.Storage.S3.SecretKey = "aws-secret"
+	cfg.OIDCProviders = []OIDCProvider{{Name: "corp", ClientID: "cowatch", ClientSecret: "oidc-secret"}}
+
+	out, err := cfg.Redacted().YAML()
+	require.NoError(t, err)
+	text := string(out)
+	for _, secret := range []string{"hunter2", "s3cret", "aws-secret", "oidc-secret", DefaultJWTSecret} {
+		assert.NotContains(t, text, secret)
+	}
+	assert.Contains(t, text, "cowatch:REDACTED@db:5432")
+	assert.Contains(t, text, "client_id: cowatch")
+	assert.Contains(t, text, "access_token_ttl: 15m0s")
+
+	assert.Equal(t, "s3cret", cfg.Auth.JWTSecrets["2024a"], "the original is untouched")
+	assert.Equal(t, "oidc-secret", cfg.OIDCProviders[0].ClientSecret)
+
+	// Printed output is a valid config file
+	var parsed Config
+	require.NoError(t, yaml.Unmarshal(out, &parsed))
+	assert.Equal(t, cfg.Auth.AccessTokenTTL, parsed.Auth.AccessTokenTTL)
+
+	assert.Equal(t, "host=db user=cowatch password=REDACTED dbname=cowatch",
+		redactDSN("host=db user=cowatch password=hunter2 dbname=cowatch"))
+	assert.False(t, strings.Contains(redactDSN("postgres://db/cowatch?password=x"), "password=x"))
+}
+
+// The example file must keep up with the Config fields
+func TestExampleFileLoads(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join("..", "..", "config.example.yaml"))
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, Defaults().WebSocket, cfg.WebSocket)
+}
@@ -0,0 +1,193 @@
+// Package cors decides which browser origins may use the API. The same allowlist drives
+// CORS responses and WebSocket upgrades, so a page that can't read API responses can't
+// open a room connection with the visitor's credentials either.
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	allowMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	allowHeaders = "Origin, Content-Type, Accept, Authorization"
+)
+
+// Options configures a Policy
+type Options struct {
+	// AllowedOrigins are exact origins ("https://cowatch.example.com"), subdomain wildcards
+	// ("https://*.example.com", which doesn't match example.com itself) or "*" for any origin
+	AllowedOrigins []string
+
+	// AllowCredentials lets browsers send cookies and read responses to credentialed requests.
+	// It can't be combined with "*".
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache a preflight response; zero leaves it to the browser
+	MaxAge time.Duration
+}
+
+// origin is a parsed origin or pattern: scheme, lowercased host and explicit port
+type origin struct {
+	scheme string
+	host   string
+	port   string
+}
+
+// Policy is an origin allowlist. It is safe for concurrent use.
+type Policy struct {
+	any         bool
+	exact       map[origin]bool
+	wildcards   []origin // host holds the suffix after "*", e.g. ".example.com"
+	credentials bool
+	maxAge      time.Duration
+}
+
+// New builds a Policy, rejecting malformed patterns
+func New(opts Options) (*Policy, error) {
+	p := &Policy{
+		exact:       make(map[origin]bool),
+		credentials: opts.AllowCredentials,
+		maxAge:      opts.MaxAge,
+	}
+
+	for _, pattern := range opts.AllowedOrigins {
+		if pattern == "*" {
+			p.any = true
+			continue
+		}
+		o, err := parseOrigin(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if rest, ok := strings.CutPrefix(o.host, "*."); ok {
+			if rest == "" || strings.Contains(rest, "*") {
+				return nil, fmt.Errorf("cors: invalid wildcard in %q", pattern)
+			}
+			o.host = "." + rest
+			p.wildcards = append(p.wildcards, o)
+			continue
+		}
+		if strings.Contains(o.host, "*") {
+			return nil, fmt.Errorf("cors: %q: a wildcard may only replace the leftmost subdomain", pattern)
+		}
+		p.exact[o] = true
+	}
+
+	if p.any && p.credentials {
+		return nil, errors.New(`cors: "*" can't be combined with credentials; list the allowed origins`)
+	}
+	return p, nil
+}
+
+// parseOrigin parses scheme://host[:port], filling in the scheme's default port
+func parseOrigin(raw string) (origin, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return origin{}, fmt.Errorf("cors: %q is not an origin like https://example.com", raw)
+	}
+
+	o := origin{scheme: u.Scheme, host: strings.ToLower(u.Hostname()), port: u.Port()}
+	if o.port == "" {
+		o.port = "80"
+		if o.scheme == "https" {
+			o.port = "443"
+		}
+	} else if _, err := strconv.Atoi(o.port); err != nil {
+		return origin{}, fmt.Errorf("cors: %q has an invalid port", raw)
+	}
+	return o, nil
+}
+
+// Allowed reports whether requests from the Origin header value may be served
+func (p *Policy) Allowed(raw string) bool {
+	if p.any {
+		return true
+	}
+	o, err := parseOrigin(raw)
+	if err != nil {
+		return false
+	}
+	if p.exact[o] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if o.scheme == w.scheme && o.port == w.port &&
+			strings.HasSuffix(o.host, w.host) && len(o.host) > len(w.host) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOrigin is a websocket.Upgrader CheckOrigin. Requests without an Origin come from
+// non-browser clients and same-origin pages are always allowed; anything else must be
+// on the allowlist.
+func (p *Policy) CheckOrigin(r *http.Request) bool {
+	raw := r.Header.Get("Origin")
+	if raw == "" {
+		return true
+	}
+	if u, err := url.Parse(raw); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return p.Allowed(raw)
+}
+
+// Middleware answers preflight requests and adds CORS headers for allowed origins.
+// Responses to other origins carry no CORS headers, so browsers won't expose them.
+func (p *Policy) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestOrigin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if requestOrigin == "" {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !p.Allowed(requestOrigin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if p.any {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", requestOrigin)
+		}
+		if p.credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Set("Access-Control-Allow-Methods", allowMethods)
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+			if p.maxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(p.maxAge.Seconds())))
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,150 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowed(t *testing.T) {
+	policy, err := New(Options{AllowedOrigins: []string{
+		"https://cowatch.example.com",
+		"https://*.preview.example.com",
+		"http://localhost:3000",
+	}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://cowatch.example.com", true},
+		{"https://COWATCH.example.com", true},
+		{"https://cowatch.example.com:443", true},
+		{"http://cowatch.example.com", false},
+		{"https://cowatch.example.com:8443", false},
+		{"https://evil.com", false},
+		{"https://cowatch.example.com.evil.com", false},
+		{"https://pr-12.preview.example.com", true},
+		{"https://a.b.preview.example.com", true},
+		{"https://preview.example.com", false},
+		{"https://evilpreview.example.com", false},
+		{"http://pr-12.preview.example.com", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:3001", false},
+		{"null", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, policy.Allowed(tt.origin), tt.origin)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, pattern := range []string{"cowatch.example.com", "https://app.*.example.com", "https://*.", "ftp://example.com", "https://example.com/path"} {
+		_, err := New(Options{AllowedOrigins: []string{pattern}})
+		assert.Error(t, err, pattern)
+	}
+
+	_, err := New(Options{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	assert.Error(t, err, "any origin with credentials would let every site act as the user")
+
+	policy, err := New(Options{AllowedOrigins: []string{"*"}})
+	require.NoError(t, err)
+	assert.True(t, policy.Allowed("https://anything.example"))
+}
+
+func TestCheckOrigin(t *testing.T) {
+	policy, err := New(Options{AllowedOrigins: []string{"https://*.example.com"}})
+	require.NoError(t, err)
+
+	request := func(origin string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://api.cowatch.dev/ws/rooms/ABC", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+
+	assert.True(t, policy.CheckOrigin(request("")), "non-browser clients send no Origin")
+	assert.True(t, policy.CheckOrigin(request("http://api.cowatch.dev")), "same origin")
+	assert.True(t, policy.CheckOrigin(request("https://app.example.com")))
+	assert.False(t, policy.CheckOrigin(request("https://attacker.dev")))
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(opts Options) *gin.Engine {
+		policy, err := New(opts)
+		require.NoError(t, err)
+		router := gin.New()
+		router.Use(policy.Middleware())
+		router.GET("/rooms", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return router
+	}
+	send := func(router *gin.Engine, method, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/rooms", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	router := newRouter(Options{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowCredentials: true,
+		MaxAge:           2 * time.Hour,
+	})
+
+	t.Run("allowed origin is echoed with credentials", func(t *testing.T) {
+		w := send(router, http.MethodGet, "https://app.example.com", false)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Contains(t, w.Header().Values("Vary"), "Origin")
+	})
+
+	t.Run("other origins get no CORS headers", func(t *testing.T) {
+		w := send(router, http.MethodGet, "https://attacker.dev", false)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("preflight is answered and cacheable", func(t *testing.T) {
+		w := send(router, http.MethodOptions, "https://app.example.com", true)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "7200", w.Header().Get("Access-Control-Max-Age"))
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+		assert.Contains(t, w.Header().Values("Vary"), "Access-Control-Request-Headers")
+	})
+
+	t.Run("preflight from other origins is refused", func(t *testing.T) {
+		w := send(router, http.MethodOptions, "https://attacker.dev", true)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("requests without Origin pass through", func(t *testing.T) {
+		w := send(router, http.MethodGet, "", false)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Vary"))
+	})
+
+	t.Run("any origin without credentials", func(t *testing.T) {
+		w := send(newRouter(Options{AllowedOrigins: []string{"*"}}), http.MethodGet, "https://anywhere.dev", false)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+}
@@ -0,0 +1,315 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// migrationLockID is the Postgres advisory lock key held while migrating, so replicas
+// starting together apply each migration once
+const migrationLockID int64 = 0x636f7761746368 // "cowatch"
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// baselineVersion is the migration that adopts databases created by AutoMigrate
+const baselineVersion int64 = 1
+
+// autoMigratedColumns are the columns models gained after the last release that created
+// its schema with AutoMigrate. CREATE TABLE IF NOT EXISTS leaves those tables as they
+// are, so the columns are added before the baseline migration indexes them.
+var autoMigratedColumns = []struct {
+	table, column, definition string
+}{
+	{"users", "avatar_key", "varchar(255)"},
+	{"users", "is_admin", "boolean DEFAULT false"},
+	{"rooms", "visibility", "varchar(10) DEFAULT 'public'"},
+	{"rooms", "allow_guests", "boolean DEFAULT false"},
+	{"rooms", "last_active_at", "timestamptz"},
+	{"ws_tickets", "is_guest", "boolean DEFAULT false"},
+}
+
+// Migration is one schema change, read from <version>_<name>.up.sql and .down.sql
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaMigration records an applied migration
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName sets the table name for applied migrations
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrations returns the migrations embedded in the binary
+func Migrations() fs.FS {
+	sub, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// LoadMigrations reads the migrations in fsys ordered by version. Every version needs an
+// up file; a missing down file makes that migration irreversible.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations, recording them in schema_migrations
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the migrations in fsys
+func NewMigrator(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Migrate applies all pending embedded migrations
+func Migrate(ctx context.Context, db *gorm.DB) error {
+	m, err := NewMigrator(db, Migrations())
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(ctx)
+	return err
+}
+
+// Up applies pending migrations in order and returns how many were applied. Each
+// migration runs in its own transaction together with its schema_migrations row.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if migration.Version == baselineVersion {
+					if err := adoptAutoMigratedSchema(tx); err != nil {
+						return err
+					}
+				}
+				if err := tx.Exec(migration.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					AppliedAt: time.Now().UTC(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the latest steps applied migrations and returns how many were rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps < 1 {
+		return 0, nil
+	}
+	rolledBack := 0
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		var records []schemaMigration
+		if err := conn.Order("version DESC").Limit(steps).Find(&records).Error; err != nil {
+			return err
+		}
+		for _, record := range records {
+			migration, ok := m.find(record.Version)
+			if !ok {
+				return fmt.Errorf("migration %d_%s is applied but unknown to this build", record.Version, record.Name)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s can't be rolled back", migration.Version, migration.Name)
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, "version = ?", migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			slog.Info("Rolled back migration", "version", migration.Version, "name", migration.Name)
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status lists the known migrations and when each was applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn := m.db.WithContext(ctx)
+	if err := createMigrationsTable(conn); err != nil {
+		return nil, err
+	}
+	done, err := appliedVersions(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		s := MigrationStatus{Migration: migration}
+		if appliedAt, ok := done[migration.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// withLock runs fn on a single connection holding the migration lock. The lock is a
+// session-level advisory lock on Postgres; other databases run fn unlocked.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if conn.Dialector.Name() == "postgres" {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockID).Error; err != nil {
+				return fmt.Errorf("acquire migration lock: %w", err)
+			}
+			// Unlock even if ctx is done, or the pooled connection would keep holding the lock
+			defer conn.Session(&gorm.Session{Context: context.Background()}).
+				Exec("SELECT pg_advisory_unlock(?)", migrationLockID)
+		}
+		if err := createMigrationsTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+// adoptAutoMigratedSchema adds the columns an AutoMigrate-created database is missing.
+// Rooms that gain last_active_at count as active since their last update.
+func adoptAutoMigratedSchema(tx *gorm.DB) error {
+	for _, c := range autoMigratedColumns {
+		if !tx.Migrator().HasTable(c.table) || tx.Migrator().HasColumn(c.table, c.column) {
+			continue
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)).Error; err != nil {
+			return fmt.Errorf("adopt %s.%s: %w", c.table, c.column, err)
+		}
+		if c.table == "rooms" && c.column == "last_active_at" {
+			if err := tx.Exec("UPDATE rooms SET last_active_at = updated_at").Error; err != nil {
+				return err
+			}
+		}
+		slog.Info("Added column to existing table", "table", c.table, "column", c.column)
+	}
+	return nil
+}
+
+func createMigrationsTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint       NOT NULL,
+		name       varchar(255) NOT NULL,
+		applied_at timestamp    NOT NULL,
+		PRIMARY KEY (version)
+	)`).Error
+}
+
+func appliedVersions(db *gorm.DB) (map[int64]time.Time, error) {
+	var records []schemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[int64]time.Time, len(records))
+	for _, record := range records {
+		done[record.Version] = record.AppliedAt
+	}
+	return done, nil
+}
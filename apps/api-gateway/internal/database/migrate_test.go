@@ -0,0 +1,255 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// Every connection to :memory: is a separate database
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("ordered by version", func(t *testing.T) {
+		migrations, err := LoadMigrations(fstest.MapFS{
+			"0002_second.up.sql":   {Data: []byte("up 2")},
+			"0001_first.up.sql":    {Data: []byte("up 1")},
+			"0001_first.down.sql":  {Data: []byte("down 1")},
+			"README.md":            {Data: []byte("ignored")},
+			"0010_tenth.up.sql":    {Data: []byte("up 10")},
+			"0010_tenth.down.sql":  {Data: []byte("down 10")},
+			"0002_second.down.sql": {Data: []byte("down 2")},
+		})
+		require.NoError(t, err)
+		require.Len(t, migrations, 3)
+		assert.Equal(t, Migration{Version: 1, Name: "first", Up: "up 1", Down: "down 1"}, migrations[0])
+		assert.Equal(t, int64(2), migrations[1].Version)
+		assert.Equal(t, int64(10), migrations[2].Version)
+	})
+
+	t.Run("missing up file", func(t *testing.T) {
+		_, err := LoadMigrations(fstest.MapFS{"0001_first.down.sql": {Data: []byte("down")}})
+		assert.Error(t, err)
+	})
+
+	t.Run("duplicate version", func(t *testing.T) {
+		_, err := LoadMigrations(fstest.MapFS{
+			"0001_first.up.sql": {Data: []byte("up")},
+			"0001_other.up.sql": {Data: []byte("up")},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	fsys := fstest.MapFS{
+		"0001_widgets.up.sql":      {Data: []byte("CREATE TABLE widgets (id integer PRIMARY KEY);")},
+		"0001_widgets.down.sql":    {Data: []byte("DROP TABLE widgets;")},
+		"0002_gadgets.up.sql":      {Data: []byte("CREATE TABLE gadgets (id integer PRIMARY KEY); INSERT INTO gadgets (id) VALUES (1);")},
+		"0002_gadgets.down.sql":    {Data: []byte("DROP TABLE gadgets;")},
+		"0003_irreversible.up.sql": {Data: []byte("ALTER TABLE gadgets ADD COLUMN name text;")},
+	}
+	m, err := NewMigrator(db, fsys)
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, applied)
+	assert.True(t, db.Migrator().HasTable("widgets"))
+	assert.True(t, db.Migrator().HasColumn("gadgets", "name"))
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, applied, "applied migrations are skipped")
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 3)
+	for _, s := range status {
+		assert.NotNil(t, s.AppliedAt, "migration %d", s.Version)
+	}
+
+	_, err = m.Down(ctx, 1)
+	assert.Error(t, err, "migration without a down file can't be rolled back")
+
+	// Dropping the irreversible migration from the set simulates an older build
+	delete(fsys, "0003_irreversible.up.sql")
+	older, err := NewMigrator(db, fsys)
+	require.NoError(t, err)
+	_, err = older.Down(ctx, 1)
+	assert.Error(t, err, "applied migrations unknown to the build are refused")
+
+	db.Exec("DELETE FROM schema_migrations WHERE version = 3")
+	rolledBack, err := older.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rolledBack)
+	assert.False(t, db.Migrator().HasTable("gadgets"))
+	assert.True(t, db.Migrator().HasTable("widgets"))
+
+	status, err = older.Status(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, status[0].AppliedAt)
+	assert.Nil(t, status[1].AppliedAt)
+}
+
+func TestMigratorFailedMigrationIsRolledBack(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	m, err := NewMigrator(db, fstest.MapFS{
+		"0001_ok.up.sql":     {Data: []byte("CREATE TABLE ok (id integer);")},
+		"0002_broken.up.sql": {Data: []byte("CREATE TABLE half (id integer); CREATE TABLE ok (id integer);")},
+	})
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, applied)
+	assert.False(t, db.Migrator().HasTable("half"), "statements of the failed migration are rolled back")
+
+	var versions []int64
+	db.Model(&schemaMigration{}).Pluck("version", &versions)
+	assert.Equal(t, []int64{1}, versions)
+}
+
+// TestEmbeddedMigrationsMatchModels guards against model changes without a migration.
+// The embedded SQL sticks to syntax SQLite accepts so it can be checked here.
+func TestEmbeddedMigrationsMatchModels(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	m, err := NewMigrator(db, Migrations())
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	assertSchemaMatchesModels(t, db)
+
+	// Rolling everything back leaves only the bookkeeping table
+	_, err = m.Down(ctx, len(m.migrations))
+	require.NoError(t, err)
+	tables, err := db.Migrator().GetTables()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"schema_migrations"}, tables)
+}
+
+// The last release before versioned migrations created these tables with AutoMigrate
+type baselineUser struct {
+	ID           string  `gorm:"type:uuid;primaryKey"`
+	Username     string  `gorm:"size:20;uniqueIndex;not null"`
+	PasswordHash string  `gorm:"size:255;not null"`
+	AvatarURL    *string `gorm:"size:500"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineRoom struct {
+	ID           string        `gorm:"type:uuid;primaryKey"`
+	Code         string        `gorm:"size:8;uniqueIndex;not null"`
+	Name         string        `gorm:"size:100;not null"`
+	OwnerID      string        `gorm:"type:uuid;not null;index"`
+	Owner        *baselineUser `gorm:"foreignKey:OwnerID"`
+	PasswordHash *string       `gorm:"size:255"`
+	MaxUsers     int           `gorm:"default:20"`
+	IsActive     bool          `gorm:"default:true;index"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (baselineRoom) TableName() string { return "rooms" }
+
+type baselineRoomMember struct {
+	ID                    string        `gorm:"type:uuid;primaryKey"`
+	RoomID                string        `gorm:"type:uuid;not null;uniqueIndex:idx_room_user"`
+	Room                  *baselineRoom `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE"`
+	UserID                string        `gorm:"type:uuid;not null;uniqueIndex:idx_room_user"`
+	User                  *baselineUser `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	HasControlPermission  bool          `gorm:"default:false"`
+	LastVisitedAt         time.Time     `gorm:"index"`
+	LastWatchedVideoTitle *string       `gorm:"size:255"`
+	CreatedAt             time.Time
+}
+
+func (baselineRoomMember) TableName() string { return "room_members" }
+
+func TestMigrateAdoptsAutoMigratedDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&baselineUser{}, &baselineRoom{}, &baselineRoomMember{}))
+
+	require.NoError(t, db.Create(&baselineUser{ID: "u1", Username: "alice", PasswordHash: "x"}).Error)
+	require.NoError(t, db.Create(&baselineRoom{ID: "r1", Code: "ABCD1234", Name: "Movie night", OwnerID: "u1"}).Error)
+
+	require.NoError(t, Migrate(ctx, db))
+	assertSchemaMatchesModels(t, db)
+
+	var user models.User
+	require.NoError(t, db.First(&user, "id = ?", "u1").Error)
+	assert.Equal(t, "alice", user.Username)
+	assert.False(t, user.IsAdmin)
+
+	var room models.Room
+	require.NoError(t, db.Select("visibility", "allow_guests").First(&room, "id = ?", "r1").Error)
+	assert.Equal(t, models.RoomVisibilityPublic, room.Visibility)
+	assert.False(t, room.AllowGuests)
+
+	var stale int64
+	db.Model(&models.Room{}).Where("last_active_at IS NULL OR last_active_at <> updated_at").Count(&stale)
+	assert.Zero(t, stale, "last activity starts at the last update")
+}
+
+// assertSchemaMatchesModels checks every model's columns, indexes and constraints exist
+func assertSchemaMatchesModels(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	for _, model := range []interface{}{
+		&models.User{},
+		&models.Room{},
+		&models.RoomMember{},
+		&models.ChatMessage{},
+		&models.RoomInvite{},
+		&models.AuthSession{},
+		&models.RefreshToken{},
+		&models.WSTicket{},
+		&models.RoomGuest{},
+		&models.UserIdentity{},
+		&models.OIDCState{},
+	} {
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(model))
+		table := stmt.Schema.Table
+		require.True(t, db.Migrator().HasTable(model), "table %s", table)
+
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" {
+				assert.True(t, db.Migrator().HasColumn(model, field.DBName), "column %s.%s", table, field.DBName)
+			}
+		}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			assert.True(t, db.Migrator().HasIndex(model, idx.Name), "index %s on %s", idx.Name, table)
+		}
+		for _, rel := range stmt.Schema.Relationships.Relations {
+			if constraint := rel.ParseConstraint(); constraint != nil && constraint.Schema == stmt.Schema {
+				assert.True(t, db.Migrator().HasConstraint(model, constraint.Name), "constraint %s on %s", constraint.Name, table)
+			}
+		}
+	}
+}
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS oidc_states;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS room_guests;
+DROP TABLE IF EXISTS ws_tickets;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS auth_sessions;
+DROP TABLE IF EXISTS room_invites;
+DROP TABLE IF EXISTS chat_messages;
+DROP TABLE IF EXISTS room_members;
+DROP TABLE IF EXISTS rooms;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,162 @@
+-- Baseline schema. Everything is IF NOT EXISTS and uses the names AutoMigrate generated,
+-- so databases created before versioned migrations are adopted. Columns those databases
+-- lack are added by the migrator before this runs (see autoMigratedColumns).
+
+CREATE TABLE IF NOT EXISTS users (
+    id            uuid         NOT NULL,
+    username      varchar(20)  NOT NULL,
+    password_hash varchar(255) NOT NULL,
+    avatar_url    varchar(500),
+    avatar_key    varchar(255),
+    is_admin      boolean      DEFAULT false,
+    created_at    timestamptz,
+    updated_at    timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+
+CREATE TABLE IF NOT EXISTS rooms (
+    id             uuid         NOT NULL,
+    code           varchar(8)   NOT NULL,
+    name           varchar(100) NOT NULL,
+    owner_id       uuid         NOT NULL,
+    password_hash  varchar(255),
+    max_users      bigint       DEFAULT 20,
+    is_active      boolean      DEFAULT true,
+    visibility     varchar(10)  DEFAULT 'public',
+    allow_guests   boolean      DEFAULT false,
+    last_active_at timestamptz,
+    created_at     timestamptz,
+    updated_at     timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_rooms_owner FOREIGN KEY (owner_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rooms_code ON rooms (code);
+CREATE INDEX IF NOT EXISTS idx_rooms_owner_id ON rooms (owner_id);
+CREATE INDEX IF NOT EXISTS idx_rooms_is_active ON rooms (is_active);
+CREATE INDEX IF NOT EXISTS idx_rooms_visibility ON rooms (visibility);
+CREATE INDEX IF NOT EXISTS idx_rooms_last_active_at ON rooms (last_active_at);
+
+CREATE TABLE IF NOT EXISTS room_members (
+    id                       uuid         NOT NULL,
+    room_id                  uuid         NOT NULL,
+    user_id                  uuid         NOT NULL,
+    has_control_permission   boolean      DEFAULT false,
+    last_visited_at          timestamptz,
+    last_watched_video_title varchar(255),
+    created_at               timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_room_members_room FOREIGN KEY (room_id) REFERENCES rooms (id) ON DELETE CASCADE,
+    CONSTRAINT fk_room_members_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_room_user ON room_members (room_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_room_members_last_visited_at ON room_members (last_visited_at);
+
+CREATE TABLE IF NOT EXISTS chat_messages (
+    id         uuid          NOT NULL,
+    room_id    uuid          NOT NULL,
+    user_id    varchar(64),
+    username   varchar(20)   NOT NULL,
+    content    varchar(1000) NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_chat_messages_room FOREIGN KEY (room_id) REFERENCES rooms (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_chat_room_created ON chat_messages (room_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_chat_messages_user_id ON chat_messages (user_id);
+
+CREATE TABLE IF NOT EXISTS room_invites (
+    id            uuid        NOT NULL,
+    room_id       uuid        NOT NULL,
+    created_by_id uuid        NOT NULL,
+    token_hash    varchar(64) NOT NULL,
+    max_uses      bigint      NOT NULL DEFAULT 1,
+    uses          bigint      NOT NULL DEFAULT 0,
+    grant_control boolean     DEFAULT false,
+    expires_at    timestamptz NOT NULL,
+    created_at    timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_room_invites_room FOREIGN KEY (room_id) REFERENCES rooms (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_room_invites_room_id ON room_invites (room_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_room_invites_token_hash ON room_invites (token_hash);
+CREATE INDEX IF NOT EXISTS idx_room_invites_expires_at ON room_invites (expires_at);
+
+CREATE TABLE IF NOT EXISTS auth_sessions (
+    id         uuid        NOT NULL,
+    user_id    uuid        NOT NULL,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_auth_sessions_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_user_id ON auth_sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_expires_at ON auth_sessions (expires_at);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id         uuid        NOT NULL,
+    session_id uuid        NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_refresh_tokens_session FOREIGN KEY (session_id) REFERENCES auth_sessions (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens (session_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS ws_tickets (
+    id         uuid        NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    user_id    uuid        NOT NULL,
+    is_guest   boolean     DEFAULT false,
+    room_id    uuid        NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ws_tickets_token_hash ON ws_tickets (token_hash);
+CREATE INDEX IF NOT EXISTS idx_ws_tickets_expires_at ON ws_tickets (expires_at);
+
+CREATE TABLE IF NOT EXISTS room_guests (
+    id         uuid        NOT NULL,
+    room_id    uuid        NOT NULL,
+    nickname   varchar(20) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_room_guests_room FOREIGN KEY (room_id) REFERENCES rooms (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_room_guests_room_id ON room_guests (room_id);
+CREATE INDEX IF NOT EXISTS idx_room_guests_expires_at ON room_guests (expires_at);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         uuid         NOT NULL,
+    user_id    uuid         NOT NULL,
+    provider   varchar(50)  NOT NULL,
+    subject    varchar(255) NOT NULL,
+    email      varchar(255),
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_user_identities_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_user_provider ON user_identities (user_id, provider);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_subject ON user_identities (provider, subject);
+
+CREATE TABLE IF NOT EXISTS oidc_states (
+    id            uuid         NOT NULL,
+    state_hash    varchar(64)  NOT NULL,
+    provider      varchar(50)  NOT NULL,
+    nonce         varchar(64)  NOT NULL,
+    code_verifier varchar(128) NOT NULL,
+    link_user_id  uuid,
+    expires_at    timestamptz  NOT NULL,
+    created_at    timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oidc_states_state_hash ON oidc_states (state_hash);
+CREATE INDEX IF NOT EXISTS idx_oidc_states_expires_at ON oidc_states (expires_at);
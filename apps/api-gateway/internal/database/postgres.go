@@ -1,32 +1,49 @@
 package database
 
 import (
-	"log"
+	"log/slog"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
-
-	"github.com/yourusername/cowatch/api-gateway/internal/models"
 )
 
-func Connect(dsn string) (*gorm.DB, error) {
+// PoolConfig sizes the connection pool; zero values keep the database/sql defaults
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Connect opens the Postgres database. The schema is managed by the versioned
+// migrations in this package; see Migrate. Statements are logged through log.
+func Connect(dsn string, pool PoolConfig, log logger.Interface) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: log,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// AutoMigrate models
-	if err := db.AutoMigrate(
-		&models.User{},
-		&models.Room{},
-		&models.RoomMember{},
-	); err != nil {
+	sqlDB, err := db.DB()
+	if err != nil {
 		return nil, err
 	}
+	if pool.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
 
-	log.Println("Database connected and migrated successfully")
+	slog.Info("Database connected")
 	return db, nil
 }
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/logging"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+// DeleteUsersMe deletes the current user's account
+// DELETE /users/me
+func (s *Server) DeleteUsersMe(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	// Accounts without a password may send no body at all
+	var req api.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请求参数错误")
+		return
+	}
+
+	mode := api.Transfer
+	if req.OwnedRooms != nil {
+		mode = *req.OwnedRooms
+	}
+	if mode != api.Transfer && mode != api.Close {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请求参数错误")
+		return
+	}
+
+	// Accounts without a password (identity provider only) rely on the access token alone.
+	// A stolen access token must not become a way to guess the password, so this is throttled like login.
+	if user.HasPassword() {
+		if req.Password == nil {
+			respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请输入密码")
+			return
+		}
+		check := reserveAttempts(c, s.accountAttempts(c, user.Username)...)
+		if check == nil {
+			return
+		}
+		defer check.done()
+		if !user.CheckPassword(*req.Password) {
+			check.fail()
+			respondError(c, http.StatusForbidden, "WRONG_PASSWORD", "密码错误")
+			return
+		}
+	}
+
+	var closedRooms []string
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		closedRooms, err = deleteAccount(tx, user, mode)
+		return err
+	}); err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "注销账号失败")
+		return
+	}
+
+	// Open connections were authorized before the account went away; end them now
+	if s.presence != nil {
+		s.presence.DisconnectUser(user.ID, "account deleted")
+		for _, roomID := range closedRooms {
+			s.presence.CloseRoom(roomID, "room closed")
+		}
+	}
+
+	// The account is gone; a failed delete only leaves an orphaned file behind
+	if user.AvatarKey != nil && s.storage != nil {
+		if err := s.storage.Delete(c.Request.Context(), *user.AvatarKey); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("Failed to delete avatar", "key", *user.AvatarKey, "error", err)
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// deleteAccount removes a user and everything tied to their identity and returns the IDs of
+// the rooms it deleted. Owned rooms are handed over or deleted, and chat messages stay in
+// their rooms without author details.
+func deleteAccount(tx *gorm.DB, user *models.User, mode api.DeleteAccountRequestOwnedRooms) ([]string, error) {
+	var rooms []models.Room
+	if err := tx.Where("owner_id = ?", user.ID).Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, room := range rooms {
+		if mode == api.Transfer {
+			transferred, err := transferRoom(tx, &room, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			if transferred {
+				continue
+			}
+		}
+		if err := deleteRoom(tx, room.ID); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, room.ID)
+	}
+
+	if err := tx.Model(&models.ChatMessage{}).
+		Where("user_id = ?", user.ID).
+		Updates(map[string]interface{}{"user_id": "", "username": models.DeletedUsername}).Error; err != nil {
+		return nil, err
+	}
+
+	// Sessions and refresh tokens go first so no token outlives the account
+	sessionIDs := tx.Model(&models.AuthSession{}).Select("id").Where("user_id = ?", user.ID)
+	if err := tx.Where("session_id IN (?)", sessionIDs).Delete(&models.RefreshToken{}).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.AuthSession{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ? AND is_guest = ?", user.ID, false).Delete(&models.WSTicket{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RoomMember{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("created_by_id = ?", user.ID).Delete(&models.RoomInvite{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserIdentity{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("link_user_id = ?", user.ID).Delete(&models.OIDCState{}).Error; err != nil {
+		return nil, err
+	}
+
+	return deleted, tx.Delete(user).Error
+}
+
+// transferRoom hands a room to the member most likely to keep it going: one with control
+// permission if any, otherwise the most recent visitor. It reports false if nobody else is a member.
+func transferRoom(tx *gorm.DB, room *models.Room, ownerID string) (bool, error) {
+	var successor models.RoomMember
+	err := tx.Where("room_id = ? AND user_id <> ?", room.ID, ownerID).
+		Order("has_control_permission DESC, last_visited_at DESC").
+		First(&successor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Model(room).Update("owner_id", successor.UserID).Error; err != nil {
+		return false, err
+	}
+	return true, tx.Model(&successor).Update("has_control_permission", true).Error
+}
+
+// deleteRoom removes a room together with its members, messages, invites, guests and tickets
+func deleteRoom(tx *gorm.DB, roomID string) error {
+	for _, model := range []interface{}{
+		&models.ChatMessage{},
+		&models.RoomMember{},
+		&models.RoomInvite{},
+		&models.RoomGuest{},
+		&models.WSTicket{},
+	} {
+		if err := tx.Where("room_id = ?", roomID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Delete(&models.Room{}, "id = ?", roomID).Error
+}
+
+// GetUsersMeExport returns a copy of the current user's personal data
+// GET /users/me/export
+func (s *Server) GetUsersMeExport(c *gin.Context, params api.GetUsersMeExportParams) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	format := api.Json
+	if params.Format != nil {
+		format = *params.Format
+	}
+	if format != api.Json && format != api.Zip {
+		respondError(c, http.StatusBadRequest, "INVALID_FORMAT", "不支持的导出格式")
+		return
+	}
+
+	export, err := s.collectUserData(user)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "导出数据失败")
+		return
+	}
+
+	filename := "cowatch-export-" + export.ExportedAt.Format("20060102")
+	if format == api.Json {
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	archive, err := zipUserData(export)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "导出数据失败")
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// collectUserData gathers everything stored about user
+func (s *Server) collectUserData(user *models.User) (*api.UserDataExport, error) {
+	export := &api.UserDataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: api.ExportedProfile{
+			Id:        user.ID,
+			Username:  user.Username,
+			AvatarUrl: user.AvatarURL,
+			IsAdmin:   user.IsAdmin,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		},
+		OwnedRooms:  []api.ExportedRoom{},
+		Memberships: []api.ExportedMembership{},
+		Messages:    []api.ExportedMessage{},
+		Sessions:    []api.ExportedSession{},
+		Identities:  []api.LinkedIdentity{},
+	}
+
+	var rooms []models.Room
+	if err := s.db.Where("owner_id = ?", user.ID).Order("created_at").Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	for _, room := range rooms {
+		export.OwnedRooms = append(export.OwnedRooms, api.ExportedRoom{
+			Id:          room.ID,
+			Code:        room.Code,
+			Name:        room.Name,
+			Visibility:  room.Visibility,
+			IsActive:    room.IsActive,
+			AllowGuests: room.AllowGuests,
+			HasPassword: room.HasPassword(),
+			CreatedAt:   room.CreatedAt,
+		})
+	}
+
+	var members []models.RoomMember
+	if err := s.db.Preload("Room").Where("user_id = ?", user.ID).Order("created_at").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		membership := api.ExportedMembership{
+			RoomId:                member.RoomID,
+			HasControlPermission:  member.HasControlPermission,
+			JoinedAt:              member.CreatedAt,
+			LastVisitedAt:         member.LastVisitedAt,
+			LastWatchedVideoTitle: member.LastWatchedVideoTitle,
+		}
+		if member.Room != nil {
+			membership.RoomCode = &member.Room.Code
+			membership.RoomName = &member.Room.Name
+		}
+		export.Memberships = append(export.Memberships, membership)
+	}
+
+	var messages []models.ChatMessage
+	if err := s.db.Where("user_id = ?", user.ID).Order("created_at").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		export.Messages = append(export.Messages, api.ExportedMessage{
+			Id:        message.ID,
+			RoomId:    message.RoomID,
+			Content:   message.Content,
+			CreatedAt: message.CreatedAt,
+		})
+	}
+
+	var sessions []models.AuthSession
+	if err := s.db.Where("user_id = ?", user.ID).Order("created_at").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, api.ExportedSession{
+			Id:        session.ID,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			RevokedAt: session.RevokedAt,
+		})
+	}
+
+	var identities []models.UserIdentity
+	if err := s.db.Where("user_id = ?", user.ID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		export.Identities = append(export.Identities, identityToAPI(&identity))
+	}
+
+	return export, nil
+}
+
+// zipUserData packs an export as one JSON file per section
+func zipUserData(export *api.UserDataExport) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, file := range []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"rooms.json", export.OwnedRooms},
+		{"memberships.json", export.Memberships},
+		{"messages.json", export.Messages},
+		{"sessions.json", export.Sessions},
+		{"identities.json", export.Identities},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
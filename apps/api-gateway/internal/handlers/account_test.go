@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+// closingPresence records the connections handlers ask the hub to close
+type closingPresence struct {
+	fakePresence
+	disconnected []string
+	closedRooms  []string
+}
+
+func (p *closingPresence) DisconnectUser(userID, reason string) {
+	p.disconnected = append(p.disconnected, userID)
+}
+
+func (p *closingPresence) CloseRoom(roomID, reason string) {
+	p.closedRooms = append(p.closedRooms, roomID)
+}
+
+func TestDeleteUsersMe(t *testing.T) {
+	server, router := setupTestServer(t)
+	presence := &closingPresence{}
+	server.presence = presence
+	router.DELETE("/users/me", middleware.AuthMiddleware(server.db, server.tokens), server.DeleteUsersMe)
+
+	deleteMe := func(token string, req api.DeleteAccountRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest("DELETE", "/users/me", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	password := func(p string) *string { return &p }
+
+	newUser := func(username string) (models.User, string) {
+		user := models.User{Username: username}
+		user.SetPassword("password123")
+		server.db.Create(&user)
+		return user, issueTestToken(t, server.db, &user)
+	}
+
+	t.Run("wrong password", func(t *testing.T) {
+		_, token := newUser("keepme")
+		w := deleteMe(token, api.DeleteAccountRequest{Password: password("wrongpassword")})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("password is required when the account has one", func(t *testing.T) {
+		_, token := newUser("keepmetoo")
+		w := doJSON(router, "DELETE", "/users/me", token, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("passwordless account needs no body", func(t *testing.T) {
+		user := models.User{Username: "oidconly"}
+		require.NoError(t, server.db.Create(&user).Error)
+		token := issueTestToken(t, server.db, &user)
+
+		w := doJSON(router, "DELETE", "/users/me", token, nil)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		var count int64
+		server.db.Model(&models.User{}).Where("id = ?", user.ID).Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("rooms are transferred and messages anonymized", func(t *testing.T) {
+		owner, token := newUser("leavinguser")
+		member, _ := newUser("staysuser")
+		recent, _ := newUser("recentuser")
+
+		shared := models.Room{Name: "Shared", OwnerID: owner.ID, IsActive: true}
+		lonely := models.Room{Name: "Lonely", OwnerID: owner.ID, IsActive: true}
+		server.db.Create(&shared)
+		server.db.Create(&lonely)
+		server.db.Create(&models.RoomMember{RoomID: shared.ID, UserID: owner.ID})
+		server.db.Create(&models.RoomMember{RoomID: shared.ID, UserID: member.ID, HasControlPermission: true, LastVisitedAt: time.Now().Add(-time.Hour)})
+		server.db.Create(&models.RoomMember{RoomID: shared.ID, UserID: recent.ID, LastVisitedAt: time.Now()})
+		server.db.Create(&models.RoomMember{RoomID: lonely.ID, UserID: owner.ID})
+		message := models.ChatMessage{RoomID: shared.ID, UserID: owner.ID, Username: owner.Username, Content: "bye"}
+		server.db.Create(&message)
+
+		w := deleteMe(token, api.DeleteAccountRequest{Password: password("password123")})
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		var count int64
+		server.db.Model(&models.User{}).Where("id = ?", owner.ID).Count(&count)
+		assert.Zero(t, count, "user should be deleted")
+		server.db.Model(&models.AuthSession{}).Where("user_id = ?", owner.ID).Count(&count)
+		assert.Zero(t, count, "sessions should be deleted")
+		server.db.Model(&models.RoomMember{}).Where("user_id = ?", owner.ID).Count(&count)
+		assert.Zero(t, count, "memberships should be deleted")
+
+		// Members with control permission are preferred over more recent visitors
+		var stored models.Room
+		require.NoError(t, server.db.First(&stored, "id = ?", shared.ID).Error)
+		assert.Equal(t, member.ID, stored.OwnerID)
+
+		server.db.Model(&models.Room{}).Where("id = ?", lonely.ID).Count(&count)
+		assert.Zero(t, count, "room without other members should be deleted")
+
+		assert.Contains(t, presence.disconnected, owner.ID)
+		assert.Contains(t, presence.closedRooms, lonely.ID)
+		assert.NotContains(t, presence.closedRooms, shared.ID, "transferred rooms stay open")
+
+		var storedMessage models.ChatMessage
+		require.NoError(t, server.db.First(&storedMessage, "id = ?", message.ID).Error)
+		assert.Empty(t, storedMessage.UserID)
+		assert.Equal(t, models.DeletedUsername, storedMessage.Username)
+		assert.Equal(t, "bye", storedMessage.Content)
+	})
+
+	t.Run("close deletes owned rooms", func(t *testing.T) {
+		owner, token := newUser("closinguser")
+		member, _ := newUser("othermember")
+
+		room := models.Room{Name: "Closing", OwnerID: owner.ID, IsActive: true}
+		server.db.Create(&room)
+		server.db.Create(&models.RoomMember{RoomID: room.ID, UserID: owner.ID})
+		server.db.Create(&models.RoomMember{RoomID: room.ID, UserID: member.ID})
+
+		mode := api.Close
+		w := deleteMe(token, api.DeleteAccountRequest{Password: password("password123"), OwnedRooms: &mode})
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		var count int64
+		server.db.Model(&models.Room{}).Where("id = ?", room.ID).Count(&count)
+		assert.Zero(t, count)
+		server.db.Model(&models.RoomMember{}).Where("room_id = ?", room.ID).Count(&count)
+		assert.Zero(t, count)
+	})
+}
+
+func TestGetUsersMeExport(t *testing.T) {
+	server, router := setupTestServer(t)
+	router.GET("/users/me/export", middleware.AuthMiddleware(server.db, server.tokens), func(c *gin.Context) {
+		var params api.GetUsersMeExportParams
+		if format := api.GetUsersMeExportParamsFormat(c.Query("format")); format != "" {
+			params.Format = &format
+		}
+		server.GetUsersMeExport(c, params)
+	})
+
+	user := models.User{Username: "exportuser"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+	token := issueTestToken(t, server.db, &user)
+
+	room := models.Room{Name: "Export Room", OwnerID: user.ID, IsActive: true}
+	server.db.Create(&room)
+	server.db.Create(&models.RoomMember{RoomID: room.ID, UserID: user.ID})
+	server.db.Create(&models.ChatMessage{RoomID: room.ID, UserID: user.ID, Username: user.Username, Content: "hello"})
+	server.db.Create(&models.ChatMessage{RoomID: room.ID, UserID: "someone-else", Username: "other", Content: "not mine"})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/users/me/export"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("json", func(t *testing.T) {
+		w := get("")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Disposition"), ".json")
+
+		var export api.UserDataExport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &export))
+		assert.Equal(t, "exportuser", export.Profile.Username)
+		require.Len(t, export.OwnedRooms, 1)
+		assert.Equal(t, room.Code, export.OwnedRooms[0].Code)
+		require.Len(t, export.Memberships, 1)
+		require.Len(t, export.Messages, 1)
+		assert.Equal(t, "hello", export.Messages[0].Content)
+		assert.Len(t, export.Sessions, 1)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		w := get("?format=zip")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+
+		archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		require.NoError(t, err)
+
+		files := map[string]*zip.File{}
+		for _, f := range archive.File {
+			files[f.Name] = f
+		}
+		for _, name := range []string{"profile.json", "rooms.json", "memberships.json", "messages.json", "sessions.json", "identities.json"} {
+			assert.Contains(t, files, name)
+		}
+
+		rc, err := files["messages.json"].Open()
+		require.NoError(t, err)
+		defer rc.Close()
+		var messages []api.ExportedMessage
+		require.NoError(t, json.NewDecoder(rc).Decode(&messages))
+		require.Len(t, messages, 1)
+		assert.Equal(t, "hello", messages[0].Content)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		w := get("?format=xml")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
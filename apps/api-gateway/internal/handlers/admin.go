@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+)
+
+// GetAdminJobs returns the status of background jobs
+// GET /admin/jobs
+func (s *Server) GetAdminJobs(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+	if !user.IsAdmin {
+		respondError(c, http.StatusForbidden, "FORBIDDEN", "需要管理员权限")
+		return
+	}
+
+	result := []api.JobStatus{}
+	if s.scheduler == nil {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	for _, status := range s.scheduler.Statuses() {
+		item := api.JobStatus{
+			Name:            status.Name,
+			IntervalSeconds: int(status.Interval.Seconds()),
+			Running:         status.Running,
+			RunCount:        status.RunCount,
+			LastRunAt:       status.LastRunAt,
+			NextRunAt:       status.NextRunAt,
+		}
+		if status.LastRunAt != nil {
+			durationMs := int(status.LastDuration.Milliseconds())
+			item.LastDurationMs = &durationMs
+		}
+		if status.LastError != "" {
+			lastError := status.LastError
+			item.LastError = &lastError
+		}
+		result = append(result, item)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/jobs"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+type noopJob struct{}
+
+func (noopJob) Name() string                  { return "noop" }
+func (noopJob) Run(ctx context.Context) error { return nil }
+
+func TestGetAdminJobs(t *testing.T) {
+	server, router := setupTestServer(t)
+	scheduler := jobs.NewScheduler(jobs.RealClock())
+	scheduler.Register(noopJob{}, time.Hour)
+	require.NoError(t, scheduler.RunNow(context.Background(), "noop"))
+	server.scheduler = scheduler
+	router.GET("/admin/jobs", middleware.AuthMiddleware(server.db, server.tokens), server.GetAdminJobs)
+
+	admin := models.User{Username: "adminuser", IsAdmin: true}
+	admin.SetPassword("password123")
+	server.db.Create(&admin)
+	adminToken := issueTestToken(t, server.db, &admin)
+
+	regular := models.User{Username: "regularuser"}
+	regular.SetPassword("password123")
+	server.db.Create(&regular)
+	regularToken := issueTestToken(t, server.db, &regular)
+
+	t.Run("admin sees job status", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/admin/jobs", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var statuses []api.JobStatus
+		err := json.Unmarshal(w.Body.Bytes(), &statuses)
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		assert.Equal(t, "noop", statuses[0].Name)
+		assert.Equal(t, 3600, statuses[0].IntervalSeconds)
+		assert.Equal(t, 1, statuses[0].RunCount)
+		assert.NotNil(t, statuses[0].LastRunAt)
+	})
+
+	t.Run("non-admin is forbidden", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/admin/jobs", nil)
+		req.Header.Set("Authorization", "Bearer "+regularToken)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
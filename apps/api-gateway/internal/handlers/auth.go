@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -20,18 +21,23 @@ func (s *Server) PostAuthRegister(c *gin.Context) {
 	}
 
 	// Validate input
-	if len(req.Username) < 2 || len(req.Username) > 20 {
+	if !validUsername(req.Username) {
 		respondError(c, http.StatusBadRequest, "INVALID_USERNAME", "用户名长度必须在2-20个字符之间")
 		return
 	}
-	if len(req.Password) < 6 || len(req.Password) > 100 {
+	if !validPassword(req.Password) {
 		respondError(c, http.StatusBadRequest, "INVALID_PASSWORD", "密码长度必须在6-100个字符之间")
 		return
 	}
 
 	// Check if username already exists
-	var existingUser models.User
-	if err := s.db.Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
+	ctx := c.Request.Context()
+	taken, err := s.users.UsernameTaken(ctx, req.Username, "")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "服务器内部错误")
+		return
+	}
+	if taken {
 		respondError(c, http.StatusConflict, "USERNAME_EXISTS", "用户名已存在")
 		return
 	}
@@ -45,26 +51,19 @@ func (s *Server) PostAuthRegister(c *gin.Context) {
 		return
 	}
 
-	if err := s.db.Create(&user).Error; err != nil {
+	if err := s.users.Create(ctx, &user); err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "创建用户失败")
 		return
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateToken(&user, s.jwtSecret)
+	// Start a login session
+	response, err := s.startSession(&user)
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "生成令牌失败")
 		return
 	}
 
-	c.JSON(http.StatusCreated, api.AuthResponse{
-		User: api.User{
-			Id:        user.ID,
-			Username:  user.Username,
-			AvatarUrl: user.AvatarURL,
-		},
-		Token: token,
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
 // PostAuthLogin handles user login
@@ -76,41 +75,80 @@ func (s *Server) PostAuthLogin(c *gin.Context) {
 		return
 	}
 
-	// Find user by username
-	var user models.User
-	if err := s.db.Where("username = ?", req.Username).First(&user).Error; err != nil {
+	// Throttle guessing per client and per account, before touching the password
+	check := reserveAttempts(c, s.accountAttempts(c, req.Username)...)
+	if check == nil {
+		return
+	}
+	defer check.done()
+
+	// Find user by username; unknown usernames count as failures too
+	user, err := s.users.GetByUsername(c.Request.Context(), req.Username)
+	if err != nil {
+		check.fail()
 		respondError(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", "用户名或密码错误")
 		return
 	}
 
 	// Verify password
 	if !user.CheckPassword(req.Password) {
+		check.fail()
 		respondError(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", "用户名或密码错误")
 		return
 	}
+	s.throttles.Username.Reset(req.Username)
+	s.throttles.Account.Reset(req.Username + "|" + c.ClientIP())
 
-	// Generate JWT token
-	token, err := middleware.GenerateToken(&user, s.jwtSecret)
+	// Start a login session
+	response, err := s.startSession(user)
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "生成令牌失败")
 		return
 	}
 
-	c.JSON(http.StatusOK, api.AuthResponse{
-		User: api.User{
-			Id:        user.ID,
-			Username:  user.Username,
-			AvatarUrl: user.AvatarURL,
-		},
-		Token: token,
-	})
+	c.JSON(http.StatusOK, response)
+}
+
+// PostAuthRefresh exchanges a refresh token for a new token pair
+// POST /auth/refresh
+func (s *Server) PostAuthRefresh(c *gin.Context) {
+	var req api.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请求参数错误")
+		return
+	}
+
+	response, err := s.rotateRefreshToken(req.RefreshToken)
+	switch {
+	case errors.Is(err, errRefreshTokenReused):
+		respondError(c, http.StatusUnauthorized, "REFRESH_TOKEN_REUSED", "刷新令牌已被使用，请重新登录")
+		return
+	case errors.Is(err, errRefreshTokenInvalid):
+		respondError(c, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN", "无效的刷新令牌")
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "生成令牌失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // PostAuthLogout handles user logout
 // POST /auth/logout
 func (s *Server) PostAuthLogout(c *gin.Context) {
-	// For JWT-based auth, logout is handled client-side by removing the token
-	// Server-side, we just return success
+	session, ok := middleware.GetSession(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	// Revoking the session invalidates its access token and refresh tokens
+	if err := revokeSession(s.db, session.ID); err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "登出失败")
+		return
+	}
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -127,7 +127,7 @@ func TestPostAuthLogin(t *testing.T) {
 
 func TestGetAuthMe(t *testing.T) {
 	server, router := setupTestServer(t)
-	router.GET("/auth/me", middleware.AuthMiddleware(server.db, testJWTSecret), server.GetAuthMe)
+	router.GET("/auth/me", middleware.AuthMiddleware(server.db, server.tokens), server.GetAuthMe)
 
 	// Create a test user
 	user := models.User{Username: "meuser"}
@@ -135,7 +135,7 @@ func TestGetAuthMe(t *testing.T) {
 	server.db.Create(&user)
 
 	t.Run("authenticated user", func(t *testing.T) {
-		token, _ := middleware.GenerateToken(&user, testJWTSecret)
+		token := issueTestToken(t, server.db, &user)
 
 		req := httptest.NewRequest("GET", "/auth/me", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
@@ -163,14 +163,127 @@ func TestGetAuthMe(t *testing.T) {
 
 func TestPostAuthLogout(t *testing.T) {
 	server, router := setupTestServer(t)
-	router.POST("/auth/logout", server.PostAuthLogout)
+	auth := middleware.AuthMiddleware(server.db, server.tokens)
+	router.POST("/auth/logout", auth, server.PostAuthLogout)
+	router.GET("/auth/me", auth, server.GetAuthMe)
 
-	t.Run("logout returns no content", func(t *testing.T) {
+	user := models.User{Username: "logoutuser"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+	token := issueTestToken(t, server.db, &user)
+
+	t.Run("logout revokes the access token", func(t *testing.T) {
 		req := httptest.NewRequest("POST", "/auth/logout", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		req = httptest.NewRequest("GET", "/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var errResp api.Error
+		err := json.Unmarshal(w.Body.Bytes(), &errResp)
+		require.NoError(t, err)
+		assert.Equal(t, "TOKEN_REVOKED", errResp.Code)
+	})
+
+	t.Run("logout without auth", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/auth/logout", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestPostAuthRefresh(t *testing.T) {
+	server, router := setupTestServer(t)
+	router.POST("/auth/login", server.PostAuthLogin)
+	router.POST("/auth/refresh", server.PostAuthRefresh)
+	router.GET("/auth/me", middleware.AuthMiddleware(server.db, server.tokens), server.GetAuthMe)
+
+	user := models.User{Username: "refreshuser"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+
+	login := func(t *testing.T) api.AuthResponse {
+		body := `{"username":"refreshuser","password":"password123"}`
+		req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response api.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	refresh := func(t *testing.T, refreshToken string) *httptest.ResponseRecorder {
+		body := `{"refreshToken":"` + refreshToken + `"}`
+		req := httptest.NewRequest("POST", "/auth/refresh", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	me := func(t *testing.T, token string) int {
+		req := httptest.NewRequest("GET", "/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("login returns short-lived token pair", func(t *testing.T) {
+		response := login(t)
+		assert.NotEmpty(t, response.RefreshToken)
+		assert.Equal(t, int(server.tokens.AccessTTL().Seconds()), response.ExpiresIn)
+	})
+
+	t.Run("refresh rotates the refresh token", func(t *testing.T) {
+		first := login(t)
+
+		w := refresh(t, first.RefreshToken)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var second api.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+		assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+		assert.Equal(t, http.StatusOK, me(t, second.Token))
+	})
+
+	t.Run("reusing a refresh token revokes the session", func(t *testing.T) {
+		first := login(t)
+
+		w := refresh(t, first.RefreshToken)
+		require.Equal(t, http.StatusOK, w.Code)
+		var second api.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+
+		w = refresh(t, first.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var errResp api.Error
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+		assert.Equal(t, "REFRESH_TOKEN_REUSED", errResp.Code)
+
+		// Both the rotated refresh token and its access token are dead now
+		assert.Equal(t, http.StatusUnauthorized, refresh(t, second.RefreshToken).Code)
+		assert.Equal(t, http.StatusUnauthorized, me(t, second.Token))
+	})
+
+	t.Run("unknown refresh token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, refresh(t, "bogus").Code)
 	})
 }
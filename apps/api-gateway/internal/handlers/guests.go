@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+// guestTTL is how long a guest token stays valid; guests simply rejoin after it runs out
+const guestTTL = 24 * time.Hour
+
+// PostRoomsRoomCodeGuest admits an anonymous guest to a room that allows guests
+// POST /rooms/{roomCode}/guest
+func (s *Server) PostRoomsRoomCodeGuest(c *gin.Context, roomCode string) {
+	var req api.GuestJoinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请求参数错误")
+		return
+	}
+
+	nickname := strings.TrimSpace(req.Nickname)
+	if n := utf8.RuneCountInString(nickname); n < 1 || n > 20 {
+		respondError(c, http.StatusBadRequest, "INVALID_NICKNAME", "昵称长度必须在1-20个字符之间")
+		return
+	}
+
+	room, err := s.rooms.GetByCode(c.Request.Context(), roomCode, true)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "ROOM_NOT_FOUND", "房间不存在")
+		return
+	}
+
+	if room.Visibility == models.RoomVisibilityPrivate {
+		respondError(c, http.StatusForbidden, "ROOM_PRIVATE", "该房间为私密房间，仅限成员加入")
+		return
+	}
+	if !room.AllowGuests {
+		respondError(c, http.StatusForbidden, "GUESTS_DISABLED", "该房间未开启访客模式")
+		return
+	}
+	if s.roomFull(room) {
+		respondError(c, http.StatusConflict, "ROOM_FULL", "房间已满")
+		return
+	}
+
+	if room.HasPassword() {
+		password := ""
+		if req.Password != nil {
+			password = *req.Password
+		}
+
+		// Guests share the room's password throttle with members joining
+		check := reserveAttempts(c, s.roomPasswordAttempts(c, room)...)
+		if check == nil {
+			return
+		}
+		defer check.done()
+		if !room.CheckPassword(password) {
+			check.fail()
+			respondError(c, http.StatusForbidden, "WRONG_PASSWORD", "密码错误")
+			return
+		}
+	}
+
+	guest := models.RoomGuest{
+		RoomID:    room.ID,
+		Nickname:  nickname,
+		ExpiresAt: time.Now().Add(guestTTL),
+	}
+	if err := s.db.Create(&guest).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "加入房间失败")
+		return
+	}
+
+	token, err := s.tokens.IssueGuest(&guest)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "生成令牌失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, api.GuestAuthResponse{
+		Guest: api.RoomGuest{
+			Id:       guest.ID,
+			Nickname: guest.Nickname,
+		},
+		Room:      s.roomToAPI(room, nil, nil),
+		Token:     token,
+		ExpiresIn: int(guestTTL.Seconds()),
+	})
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+func TestGuestAccess(t *testing.T) {
+	server, router := setupTestServer(t)
+	auth := middleware.AuthMiddleware(server.db, server.tokens)
+	router.PATCH("/rooms/:roomCode", auth, func(c *gin.Context) {
+		server.PatchRoomsRoomCode(c, c.Param("roomCode"))
+	})
+	router.POST("/rooms/:roomCode/guest", func(c *gin.Context) {
+		server.PostRoomsRoomCodeGuest(c, c.Param("roomCode"))
+	})
+	router.POST("/rooms/:roomCode/ws-ticket", middleware.GuestAuthMiddleware(server.db, server.tokens), func(c *gin.Context) {
+		server.PostRoomsRoomCodeWsTicket(c, c.Param("roomCode"))
+	})
+	router.GET("/users/me/recent-rooms", auth, func(c *gin.Context) {
+		server.GetUsersMeRecentRooms(c, api.GetUsersMeRecentRoomsParams{})
+	})
+
+	owner := models.User{Username: "guesthost"}
+	owner.SetPassword("password123")
+	server.db.Create(&owner)
+	ownerToken := issueTestToken(t, server.db, &owner)
+
+	member := models.User{Username: "guestmember"}
+	member.SetPassword("password123")
+	server.db.Create(&member)
+	memberToken := issueTestToken(t, server.db, &member)
+
+	room := models.Room{Name: "Guest Room", OwnerID: owner.ID, IsActive: true}
+	server.db.Create(&room)
+	server.db.Create(&models.RoomMember{RoomID: room.ID, UserID: owner.ID})
+	server.db.Create(&models.RoomMember{RoomID: room.ID, UserID: member.ID})
+	other := models.Room{Name: "Other Room", OwnerID: owner.ID, IsActive: true, AllowGuests: true}
+	server.db.Create(&other)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	setGuests := func(token string, allow bool) *httptest.ResponseRecorder {
+		body := `{"allowGuests":false}`
+		if allow {
+			body = `{"allowGuests":true}`
+		}
+		return do("PATCH", "/rooms/"+room.Code, token, body)
+	}
+
+	joinAsGuest := func(t *testing.T, nickname string) api.GuestAuthResponse {
+		w := do("POST", "/rooms/"+room.Code+"/guest", "", `{"nickname":"`+nickname+`"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var response api.GuestAuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("guests are disabled by default", func(t *testing.T) {
+		w := do("POST", "/rooms/"+room.Code+"/guest", "", `{"nickname":"路人甲"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var errResp api.Error
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+		assert.Equal(t, "GUESTS_DISABLED", errResp.Code)
+	})
+
+	t.Run("only the host can enable guests", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, setGuests(memberToken, true).Code)
+
+		w := setGuests(ownerToken, true)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response api.Room
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.AllowGuests)
+		assert.True(t, *response.AllowGuests)
+	})
+
+	t.Run("nickname is required", func(t *testing.T) {
+		w := do("POST", "/rooms/"+room.Code+"/guest", "", `{"nickname":"   "}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("guest joins and gets a ticket for that room only", func(t *testing.T) {
+		response := joinAsGuest(t, "路人甲")
+		assert.Equal(t, "路人甲", response.Guest.Nickname)
+		assert.NotEmpty(t, response.Token)
+		require.NotNil(t, response.Room.CurrentUserRole)
+		assert.Equal(t, api.Guest, *response.Room.CurrentUserRole)
+		assert.False(t, *response.Room.CurrentUserHasControl)
+
+		w := do("POST", "/rooms/"+room.Code+"/ws-ticket", response.Token, "")
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var ticket api.WSTicket
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ticket))
+		var stored models.WSTicket
+		require.NoError(t, server.db.First(&stored, "token_hash = ?", models.HashOpaqueToken(ticket.Ticket)).Error)
+		assert.True(t, stored.IsGuest)
+		assert.Equal(t, response.Guest.Id, stored.UserID)
+
+		w = do("POST", "/rooms/"+other.Code+"/ws-ticket", response.Token, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("guest tokens can't reach account endpoints", func(t *testing.T) {
+		response := joinAsGuest(t, "路人乙")
+
+		w := do("GET", "/users/me/recent-rooms", response.Token, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var count int64
+		server.db.Model(&models.RoomMember{}).Where("user_id = ?", response.Guest.Id).Count(&count)
+		assert.Zero(t, count, "guests must not become room members")
+	})
+
+	t.Run("full room", func(t *testing.T) {
+		server.presence = fakePresence{counts: map[string]int{room.ID: room.MaxUsers}}
+		defer func() { server.presence = nil }()
+
+		w := do("POST", "/rooms/"+room.Code+"/guest", "", `{"nickname":"路人丁"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var guests int64
+		server.db.Model(&models.RoomGuest{}).Where("nickname = ?", "路人丁").Count(&guests)
+		assert.Zero(t, guests)
+	})
+
+	t.Run("disabling guests revokes their tokens", func(t *testing.T) {
+		response := joinAsGuest(t, "路人丙")
+
+		require.Equal(t, http.StatusOK, setGuests(ownerToken, false).Code)
+
+		w := do("POST", "/rooms/"+room.Code+"/ws-ticket", response.Token, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+const (
+	defaultInviteTTL = 24 * time.Hour
+	minInviteTTL     = time.Minute
+	maxInviteTTL     = 30 * 24 * time.Hour
+	maxInviteUses    = 100
+)
+
+var (
+	errInviteExpired   = errors.New("invite expired")
+	errInviteExhausted = errors.New("invite exhausted")
+	errRoomFull        = errors.New("room full")
+)
+
+// PostRoomsRoomCodeInvites creates an invite link for a room
+// POST /rooms/{roomCode}/invites
+func (s *Server) PostRoomsRoomCodeInvites(c *gin.Context, roomCode string) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	room, err := s.rooms.GetByCode(c.Request.Context(), roomCode, true)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "ROOM_NOT_FOUND", "房间不存在")
+		return
+	}
+
+	if room.OwnerID != user.ID {
+		respondError(c, http.StatusForbidden, "FORBIDDEN", "只有房主可以创建邀请")
+		return
+	}
+
+	// The body is optional; an empty one creates a single-use invite valid for a day
+	var req api.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请求参数错误")
+		return
+	}
+
+	maxUses := 1
+	if req.MaxUses != nil {
+		if *req.MaxUses < 1 || *req.MaxUses > maxInviteUses {
+			respondError(c, http.StatusBadRequest, "INVALID_MAX_USES", "使用次数必须在1-100之间")
+			return
+		}
+		maxUses = *req.MaxUses
+	}
+
+	ttl := defaultInviteTTL
+	if req.ExpiresInSeconds != nil {
+		ttl = time.Duration(*req.ExpiresInSeconds) * time.Second
+		if ttl < minInviteTTL || ttl > maxInviteTTL {
+			respondError(c, http.StatusBadRequest, "INVALID_EXPIRY", "有效期必须在1分钟到30天之间")
+			return
+		}
+	}
+
+	token, hash, err := models.NewOpaqueToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "生成邀请失败")
+		return
+	}
+
+	invite := models.RoomInvite{
+		RoomID:       room.ID,
+		CreatedByID:  user.ID,
+		TokenHash:    hash,
+		MaxUses:      maxUses,
+		GrantControl: req.GrantControl != nil && *req.GrantControl,
+		ExpiresAt:    time.Now().Add(ttl),
+	}
+	if err := s.db.Create(&invite).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "创建邀请失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, api.RoomInvite{
+		Token:        token,
+		RoomCode:     room.Code,
+		MaxUses:      invite.MaxUses,
+		Uses:         invite.Uses,
+		GrantControl: invite.GrantControl,
+		ExpiresAt:    invite.ExpiresAt,
+	})
+}
+
+// PostInvitesTokenAccept joins the current user to the invite's room
+// POST /invites/{token}/accept
+func (s *Server) PostInvitesTokenAccept(c *gin.Context, token string) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	var invite models.RoomInvite
+	err := s.db.Preload("Room").Preload("Room.Owner").
+		Where("token_hash = ?", models.HashOpaqueToken(token)).
+		First(&invite).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "加入房间失败")
+		return
+	}
+	if err != nil || invite.Room == nil || !invite.Room.IsActive {
+		respondError(c, http.StatusNotFound, "INVITE_NOT_FOUND", "邀请不存在")
+		return
+	}
+	room := invite.Room
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var member models.RoomMember
+		err := tx.Where("room_id = ? AND user_id = ?", room.ID, user.ID).First(&member).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		isMember := err == nil
+
+		// Existing members don't use up the invite unless it grants them something new
+		if room.OwnerID == user.ID {
+			return nil
+		}
+		if isMember && (!invite.GrantControl || member.HasControlPermission) {
+			return tx.Model(&member).Update("last_visited_at", time.Now()).Error
+		}
+
+		now := time.Now()
+		if invite.IsExpired(now) {
+			return errInviteExpired
+		}
+		if !isMember && s.roomFull(room) {
+			return errRoomFull
+		}
+
+		// Claim a use atomically so concurrent accepts can't exceed MaxUses
+		result := tx.Model(&models.RoomInvite{}).
+			Where("id = ? AND uses < max_uses AND expires_at > ?", invite.ID, now).
+			Update("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInviteExhausted
+		}
+
+		if isMember {
+			return tx.Model(&member).Updates(map[string]interface{}{
+				"has_control_permission": true,
+				"last_visited_at":        now,
+			}).Error
+		}
+
+		return tx.Create(&models.RoomMember{
+			RoomID:               room.ID,
+			UserID:               user.ID,
+			HasControlPermission: invite.GrantControl,
+			LastVisitedAt:        now,
+		}).Error
+	})
+
+	switch {
+	case errors.Is(err, errInviteExpired):
+		respondError(c, http.StatusGone, "INVITE_EXPIRED", "邀请已过期")
+		return
+	case errors.Is(err, errInviteExhausted):
+		respondError(c, http.StatusGone, "INVITE_EXHAUSTED", "邀请已被使用")
+		return
+	case errors.Is(err, errRoomFull):
+		respondError(c, http.StatusConflict, "ROOM_FULL", "房间已满")
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "加入房间失败")
+		return
+	}
+
+	if err := s.rooms.Touch(c.Request.Context(), room.ID, time.Now()); err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "加入房间失败")
+		return
+	}
+
+	s.respondRoom(c, room, user)
+}
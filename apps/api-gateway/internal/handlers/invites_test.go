@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+func TestRoomInvites(t *testing.T) {
+	server, router := setupTestServer(t)
+	auth := middleware.AuthMiddleware(server.db, server.tokens)
+	router.POST("/rooms/:roomCode/invites", auth, func(c *gin.Context) {
+		server.PostRoomsRoomCodeInvites(c, c.Param("roomCode"))
+	})
+	router.POST("/invites/:token/accept", auth, func(c *gin.Context) {
+		server.PostInvitesTokenAccept(c, c.Param("token"))
+	})
+
+	owner := models.User{Username: "inviteowner"}
+	owner.SetPassword("password123")
+	server.db.Create(&owner)
+	ownerToken := issueTestToken(t, server.db, &owner)
+
+	guest := models.User{Username: "inviteguest"}
+	guest.SetPassword("password123")
+	server.db.Create(&guest)
+	guestToken := issueTestToken(t, server.db, &guest)
+
+	other := models.User{Username: "inviteother"}
+	other.SetPassword("password123")
+	server.db.Create(&other)
+	otherToken := issueTestToken(t, server.db, &other)
+
+	room := models.Room{
+		Name:       "Invite Only",
+		OwnerID:    owner.ID,
+		IsActive:   true,
+		Visibility: models.RoomVisibilityPrivate,
+	}
+	room.SetPassword("roompassword")
+	server.db.Create(&room)
+
+	createInvite := func(t *testing.T, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rooms/"+room.Code+"/invites", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	accept := func(t *testing.T, token, inviteToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/invites/"+inviteToken+"/accept", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("only owner can create invites", func(t *testing.T) {
+		w := createInvite(t, guestToken, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("invalid max uses", func(t *testing.T) {
+		w := createInvite(t, ownerToken, `{"maxUses":0}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("single-use invite with control", func(t *testing.T) {
+		w := createInvite(t, ownerToken, `{"grantControl":true}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var invite api.RoomInvite
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invite))
+		assert.NotEmpty(t, invite.Token)
+		assert.Equal(t, 1, invite.MaxUses)
+		assert.True(t, invite.GrantControl)
+
+		// The plaintext token is never stored
+		var stored models.RoomInvite
+		require.NoError(t, server.db.First(&stored, "room_id = ?", room.ID).Error)
+		assert.NotEqual(t, invite.Token, stored.TokenHash)
+
+		// Joins a private, password-protected room without the password
+		w = accept(t, guestToken, invite.Token)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var joined api.Room
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &joined))
+		assert.Equal(t, api.Member, *joined.CurrentUserRole)
+		assert.True(t, *joined.CurrentUserHasControl)
+
+		// A second user can't reuse it
+		w = accept(t, otherToken, invite.Token)
+		assert.Equal(t, http.StatusGone, w.Code)
+	})
+
+	t.Run("expired invite", func(t *testing.T) {
+		w := createInvite(t, ownerToken, `{"maxUses":5}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var invite api.RoomInvite
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invite))
+
+		server.db.Model(&models.RoomInvite{}).
+			Where("token_hash = ?", models.HashOpaqueToken(invite.Token)).
+			Update("expires_at", time.Now().Add(-time.Minute))
+
+		w = accept(t, otherToken, invite.Token)
+		assert.Equal(t, http.StatusGone, w.Code)
+
+		var errResp api.Error
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+		assert.Equal(t, "INVITE_EXPIRED", errResp.Code)
+	})
+
+	t.Run("unknown invite", func(t *testing.T) {
+		w := accept(t, otherToken, "not-a-real-token")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("full room", func(t *testing.T) {
+		w := createInvite(t, ownerToken, `{"maxUses":5}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var invite api.RoomInvite
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invite))
+
+		server.presence = fakePresence{counts: map[string]int{room.ID: room.MaxUsers}}
+		defer func() { server.presence = nil }()
+
+		newcomer := models.User{Username: "invitelate"}
+		server.db.Create(&newcomer)
+		w = accept(t, issueTestToken(t, server.db, &newcomer), invite.Token)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var uses int
+		server.db.Model(&models.RoomInvite{}).Where("token_hash = ?", models.HashOpaqueToken(invite.Token)).Pluck("uses", &uses)
+		assert.Zero(t, uses, "a refused accept doesn't use up the invite")
+
+		// Members already in the room can still come back
+		w = accept(t, guestToken, invite.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
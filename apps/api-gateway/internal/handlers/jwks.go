@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publishes the public keys used to sign access tokens so other services can verify them
+// GET /.well-known/jwks.json
+func (s *Server) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, s.tokens.JWKS())
+}
@@ -0,0 +1,417 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+	"github.com/yourusername/cowatch/api-gateway/internal/oidc"
+)
+
+// oidcStateTTL is how long a user has to finish signing in at the provider
+const oidcStateTTL = 10 * time.Minute
+
+var errOIDCStateInvalid = errors.New("oidc state invalid")
+
+// oidcStateCookie names the cookie that ties a provider's state to the browser that started the flow
+func oidcStateCookie(provider string) string {
+	return "oidc_state_" + provider
+}
+
+// setOIDCStateCookie stores state in a short-lived cookie only this API can read. Without it,
+// an attacker could start a login and get a victim's browser to finish it, signing the victim
+// into the attacker's account.
+func setOIDCStateCookie(c *gin.Context, provider, state string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie(provider), state, maxAge, "/", "", secure, true)
+}
+
+// GetAuthOidcProviders lists the identity providers users can sign in with
+// GET /auth/oidc/providers
+func (s *Server) GetAuthOidcProviders(c *gin.Context) {
+	result := make([]api.OIDCProvider, 0)
+	if s.providers != nil {
+		for _, cfg := range s.providers.List() {
+			name := cfg.DisplayName
+			if name == "" {
+				name = cfg.Name
+			}
+			result = append(result, api.OIDCProvider{Name: cfg.Name, DisplayName: name})
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// PostAuthOidcProviderAuthorize starts a login, or a link when the caller is signed in
+// POST /auth/oidc/{provider}/authorize
+func (s *Server) PostAuthOidcProviderAuthorize(c *gin.Context, provider string) {
+	p, ok := s.oidcProvider(c, provider)
+	if !ok {
+		return
+	}
+
+	state, stateHash, err := models.NewOpaqueToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "服务器内部错误")
+		return
+	}
+	nonce, _, err := models.NewOpaqueToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "服务器内部错误")
+		return
+	}
+
+	record := models.OIDCState{
+		StateHash:    stateHash,
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: oidc.NewVerifier(),
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}
+	if user, ok := middleware.GetUser(c); ok {
+		record.LinkUserID = &user.ID
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "服务器内部错误")
+		return
+	}
+	setOIDCStateCookie(c, provider, state, int(oidcStateTTL.Seconds()))
+
+	c.JSON(http.StatusOK, api.OIDCAuthorizeResponse{
+		AuthorizationUrl: p.AuthCodeURL(state, nonce, record.CodeVerifier),
+	})
+}
+
+// PostAuthOidcProviderCallback signs in with a provider, creating an account on first use
+// POST /auth/oidc/{provider}/callback
+func (s *Server) PostAuthOidcProviderCallback(c *gin.Context, provider string) {
+	identity, record, ok := s.completeOIDC(c, provider)
+	if !ok {
+		return
+	}
+	if record.LinkUserID != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_STATE", "该授权用于绑定账号")
+		return
+	}
+
+	var user models.User
+	var linked models.UserIdentity
+	err := s.db.Preload("User").
+		Where("provider = ? AND subject = ?", provider, identity.Subject).
+		First(&linked).Error
+	switch {
+	case err == nil && linked.User != nil:
+		user = *linked.User
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Never attach to an existing account by email: the provider's email claim
+		// isn't proof of owning the local account. Users link explicitly instead.
+		user, err = s.createOIDCUser(provider, identity)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "创建用户失败")
+			return
+		}
+	default:
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "服务器内部错误")
+		return
+	}
+
+	response, err := s.startSession(&user)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "生成令牌失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetUsersMeIdentities lists the providers linked to the current user
+// GET /users/me/identities
+func (s *Server) GetUsersMeIdentities(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	var identities []models.UserIdentity
+	if err := s.db.Where("user_id = ?", user.ID).Order("created_at").Find(&identities).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "获取绑定账号失败")
+		return
+	}
+
+	result := make([]api.LinkedIdentity, 0, len(identities))
+	for _, identity := range identities {
+		result = append(result, identityToAPI(&identity))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// PostUsersMeIdentitiesProvider links a provider account to the current user
+// POST /users/me/identities/{provider}
+func (s *Server) PostUsersMeIdentitiesProvider(c *gin.Context, provider string) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	identity, record, ok := s.completeOIDC(c, provider)
+	if !ok {
+		return
+	}
+
+	// The link must have been started by this user, so nobody can attach their account to someone else's
+	if record.LinkUserID == nil || *record.LinkUserID != user.ID {
+		respondError(c, http.StatusBadRequest, "INVALID_STATE", "授权状态无效")
+		return
+	}
+
+	var count int64
+	if err := s.db.Model(&models.UserIdentity{}).
+		Where("provider = ? AND subject = ?", provider, identity.Subject).
+		Count(&count).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "绑定失败")
+		return
+	}
+	if count > 0 {
+		respondError(c, http.StatusConflict, "IDENTITY_IN_USE", "该外部账号已绑定")
+		return
+	}
+	if err := s.db.Model(&models.UserIdentity{}).
+		Where("user_id = ? AND provider = ?", user.ID, provider).
+		Count(&count).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "绑定失败")
+		return
+	}
+	if count > 0 {
+		respondError(c, http.StatusConflict, "PROVIDER_ALREADY_LINKED", "已绑定过该登录方式")
+		return
+	}
+
+	linked := newUserIdentity(user.ID, provider, identity)
+	if err := s.db.Create(&linked).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "绑定失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, identityToAPI(&linked))
+}
+
+// DeleteUsersMeIdentitiesProvider unlinks a provider from the current user
+// DELETE /users/me/identities/{provider}
+func (s *Server) DeleteUsersMeIdentitiesProvider(c *gin.Context, provider string) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	var linked models.UserIdentity
+	if err := s.db.Where("user_id = ? AND provider = ?", user.ID, provider).First(&linked).Error; err != nil {
+		respondError(c, http.StatusNotFound, "IDENTITY_NOT_FOUND", "未绑定该登录方式")
+		return
+	}
+
+	// Keep at least one way to sign in
+	if !user.HasPassword() {
+		var count int64
+		if err := s.db.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "解绑失败")
+			return
+		}
+		if count <= 1 {
+			respondError(c, http.StatusConflict, "LAST_LOGIN_METHOD", "这是唯一的登录方式，请先设置密码")
+			return
+		}
+	}
+
+	if err := s.db.Delete(&linked).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "解绑失败")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// oidcProvider resolves a configured provider, writing an error response on failure
+func (s *Server) oidcProvider(c *gin.Context, name string) (*oidc.Provider, bool) {
+	if s.providers == nil {
+		respondError(c, http.StatusNotFound, "PROVIDER_NOT_FOUND", "不支持该登录方式")
+		return nil, false
+	}
+
+	p, err := s.providers.Get(c.Request.Context(), name)
+	if errors.Is(err, oidc.ErrUnknownProvider) {
+		respondError(c, http.StatusNotFound, "PROVIDER_NOT_FOUND", "不支持该登录方式")
+		return nil, false
+	}
+	if err != nil {
+		respondError(c, http.StatusBadGateway, "PROVIDER_UNAVAILABLE", "登录服务暂不可用")
+		return nil, false
+	}
+	return p, true
+}
+
+// completeOIDC redeems the code from a provider callback, writing an error response on failure
+func (s *Server) completeOIDC(c *gin.Context, provider string) (*oidc.Identity, *models.OIDCState, bool) {
+	var req api.OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" || req.State == "" {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请求参数错误")
+		return nil, nil, false
+	}
+
+	p, ok := s.oidcProvider(c, provider)
+	if !ok {
+		return nil, nil, false
+	}
+
+	// The callback must come from the browser that started the flow
+	cookie, err := c.Cookie(oidcStateCookie(provider))
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(req.State)) != 1 {
+		respondError(c, http.StatusBadRequest, "INVALID_STATE", "授权状态无效，请在同一浏览器中重新登录")
+		return nil, nil, false
+	}
+	setOIDCStateCookie(c, provider, "", -1)
+
+	record, err := consumeOIDCState(s.db, provider, req.State)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_STATE", "授权已过期，请重新登录")
+		return nil, nil, false
+	}
+
+	identity, err := p.Exchange(c.Request.Context(), req.Code, record.CodeVerifier, record.Nonce)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, "OIDC_FAILED", "第三方登录验证失败")
+		return nil, nil, false
+	}
+	return identity, record, true
+}
+
+// consumeOIDCState deletes and returns a pending login, so each state works exactly once
+func consumeOIDCState(db *gorm.DB, provider, state string) (*models.OIDCState, error) {
+	var record models.OIDCState
+	if err := db.Where("state_hash = ? AND provider = ? AND expires_at > ?",
+		models.HashOpaqueToken(state), provider, time.Now()).
+		First(&record).Error; err != nil {
+		return nil, err
+	}
+
+	result := db.Delete(&record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errOIDCStateInvalid
+	}
+	return &record, nil
+}
+
+// createOIDCUser creates a passwordless user for a first-time provider login
+func (s *Server) createOIDCUser(provider string, identity *oidc.Identity) (models.User, error) {
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		username, err := uniqueUsername(tx, usernameCandidates(identity))
+		if err != nil {
+			return err
+		}
+		user = models.User{Username: username}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		linked := newUserIdentity(user.ID, provider, identity)
+		return tx.Create(&linked).Error
+	})
+	return user, err
+}
+
+// usernameCandidates returns usable usernames suggested by the provider's claims, best first
+func usernameCandidates(identity *oidc.Identity) []string {
+	localPart, _, _ := strings.Cut(identity.Email, "@")
+	var candidates []string
+	for _, name := range []string{identity.PreferredUsername, identity.Name, localPart} {
+		name = truncateBytes(strings.TrimSpace(name), 20)
+		if validUsername(name) {
+			candidates = append(candidates, name)
+		}
+	}
+	return candidates
+}
+
+// uniqueUsername returns the first free candidate, or the best one with a random suffix
+func uniqueUsername(db *gorm.DB, candidates []string) (string, error) {
+	if len(candidates) == 0 {
+		candidates = []string{"user"}
+	}
+	isFree := func(name string) (bool, error) {
+		var count int64
+		err := db.Model(&models.User{}).Where("username = ?", name).Count(&count).Error
+		return count == 0, err
+	}
+
+	for _, name := range candidates {
+		free, err := isFree(name)
+		if err != nil {
+			return "", err
+		}
+		if free {
+			return name, nil
+		}
+	}
+	for i := 0; i < 5; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		name := fmt.Sprintf("%s%04d", truncateBytes(candidates[0], 16), n.Int64())
+		free, err := isFree(name)
+		if err != nil {
+			return "", err
+		}
+		if free {
+			return name, nil
+		}
+	}
+	return "", errors.New("no free username")
+}
+
+// truncateBytes shortens s to at most n bytes without splitting a character
+func truncateBytes(s string, n int) string {
+	for len(s) > n {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
+
+func newUserIdentity(userID, provider string, identity *oidc.Identity) models.UserIdentity {
+	linked := models.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  identity.Subject,
+	}
+	if identity.Email != "" {
+		linked.Email = &identity.Email
+	}
+	return linked
+}
+
+func identityToAPI(identity *models.UserIdentity) api.LinkedIdentity {
+	return api.LinkedIdentity{
+		Provider: identity.Provider,
+		Email:    identity.Email,
+		LinkedAt: identity.CreatedAt,
+	}
+}
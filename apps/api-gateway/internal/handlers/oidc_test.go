@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+	"github.com/yourusername/cowatch/api-gateway/internal/oidc"
+	"github.com/yourusername/cowatch/api-gateway/internal/oidc/oidctest"
+)
+
+func setupOIDCTest(t *testing.T) (*Server, *gin.Engine, *oidctest.Server) {
+	idp := oidctest.NewServer(t, "cowatch", "secret")
+
+	server, router := setupTestServer(t)
+	server.providers = oidc.NewRegistry([]oidc.ProviderConfig{{
+		Name:         "corp",
+		DisplayName:  "Corp SSO",
+		Issuer:       idp.URL,
+		ClientID:     "cowatch",
+		ClientSecret: "secret",
+		RedirectURL:  "http://app.example.com/auth/callback/corp",
+	}})
+
+	router.Use(sameBrowser())
+	authMiddleware := middleware.AuthMiddleware(server.db, server.tokens)
+	router.GET("/auth/oidc/providers", server.GetAuthOidcProviders)
+	router.POST("/auth/oidc/:provider/authorize", middleware.OptionalAuthMiddleware(server.db, server.tokens), func(c *gin.Context) {
+		server.PostAuthOidcProviderAuthorize(c, c.Param("provider"))
+	})
+	router.POST("/auth/oidc/:provider/callback", func(c *gin.Context) {
+		server.PostAuthOidcProviderCallback(c, c.Param("provider"))
+	})
+	router.GET("/users/me/identities", authMiddleware, server.GetUsersMeIdentities)
+	router.POST("/users/me/identities/:provider", authMiddleware, func(c *gin.Context) {
+		server.PostUsersMeIdentitiesProvider(c, c.Param("provider"))
+	})
+	router.DELETE("/users/me/identities/:provider", authMiddleware, func(c *gin.Context) {
+		server.DeleteUsersMeIdentitiesProvider(c, c.Param("provider"))
+	})
+
+	return server, router, idp
+}
+
+// sameBrowser sends every test request from one browser, replaying the cookies earlier responses set
+func sameBrowser() gin.HandlerFunc {
+	var mu sync.Mutex
+	jar := make(map[string]*http.Cookie)
+	return func(c *gin.Context) {
+		mu.Lock()
+		for _, cookie := range jar {
+			c.Request.AddCookie(cookie)
+		}
+		mu.Unlock()
+
+		c.Next()
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, cookie := range (&http.Response{Header: c.Writer.Header()}).Cookies() {
+			if cookie.MaxAge < 0 {
+				delete(jar, cookie.Name)
+			} else {
+				jar[cookie.Name] = cookie
+			}
+		}
+	}
+}
+
+func doJSON(router *gin.Engine, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// authorizeAt starts a flow (linking when token is set) and signs in at the mock provider
+func authorizeAt(t *testing.T, router *gin.Engine, idp *oidctest.Server, token string) api.OIDCCallbackRequest {
+	w := doJSON(router, "POST", "/auth/oidc/corp/authorize", token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response api.OIDCAuthorizeResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	code, state := idp.Authorize(t, response.AuthorizationUrl)
+	return api.OIDCCallbackRequest{Code: code, State: state}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	server, router, idp := setupOIDCTest(t)
+	idp.SetUser(oidctest.User{Subject: "sub-alice", Email: "alice@example.com", PreferredUsername: "alice"})
+
+	t.Run("providers are listed", func(t *testing.T) {
+		w := doJSON(router, "GET", "/auth/oidc/providers", "", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var providers []api.OIDCProvider
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &providers))
+		assert.Equal(t, []api.OIDCProvider{{Name: "corp", DisplayName: "Corp SSO"}}, providers)
+	})
+
+	var firstUserID string
+	t.Run("first login creates a passwordless account", func(t *testing.T) {
+		w := doJSON(router, "POST", "/auth/oidc/corp/callback", "", authorizeAt(t, router, idp, ""))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response api.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "alice", response.User.Username)
+		assert.NotEmpty(t, response.Token)
+		firstUserID = response.User.Id
+
+		var user models.User
+		require.NoError(t, server.db.First(&user, "id = ?", firstUserID).Error)
+		assert.False(t, user.HasPassword())
+	})
+
+	t.Run("next login signs into the same account", func(t *testing.T) {
+		w := doJSON(router, "POST", "/auth/oidc/corp/callback", "", authorizeAt(t, router, idp, ""))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response api.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, firstUserID, response.User.Id)
+	})
+
+	t.Run("username collisions get a suffix", func(t *testing.T) {
+		idp.SetUser(oidctest.User{Subject: "sub-other-alice", PreferredUsername: "alice"})
+		w := doJSON(router, "POST", "/auth/oidc/corp/callback", "", authorizeAt(t, router, idp, ""))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response api.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEqual(t, firstUserID, response.User.Id)
+		assert.Regexp(t, `^alice\d{4}$`, response.User.Username)
+	})
+
+	t.Run("state can only be used once", func(t *testing.T) {
+		callback := authorizeAt(t, router, idp, "")
+		w := doJSON(router, "POST", "/auth/oidc/corp/callback", "", callback)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = doJSON(router, "POST", "/auth/oidc/corp/callback", "", callback)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("state must come back to the browser that started the flow", func(t *testing.T) {
+		// An attacker's state, finished in a browser that has since started its own flow
+		attacker := authorizeAt(t, router, idp, "")
+		authorizeAt(t, router, idp, "")
+
+		w := doJSON(router, "POST", "/auth/oidc/corp/callback", "", attacker)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("unknown state is rejected", func(t *testing.T) {
+		callback := authorizeAt(t, router, idp, "")
+		callback.State = "forged"
+		w := doJSON(router, "POST", "/auth/oidc/corp/callback", "", callback)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		w := doJSON(router, "POST", "/auth/oidc/nope/authorize", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestOIDCLinkIdentity(t *testing.T) {
+	server, router, idp := setupOIDCTest(t)
+
+	user := models.User{Username: "linkuser"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+	token := issueTestToken(t, server.db, &user)
+
+	idp.SetUser(oidctest.User{Subject: "sub-link", Email: "link@example.com"})
+
+	t.Run("link state can't be used to log in", func(t *testing.T) {
+		w := doJSON(router, "POST", "/auth/oidc/corp/callback", "", authorizeAt(t, router, idp, token))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("login state can't be used to link", func(t *testing.T) {
+		w := doJSON(router, "POST", "/users/me/identities/corp", token, authorizeAt(t, router, idp, ""))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("link then log in with the provider", func(t *testing.T) {
+		w := doJSON(router, "POST", "/users/me/identities/corp", token, authorizeAt(t, router, idp, token))
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var linked api.LinkedIdentity
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &linked))
+		assert.Equal(t, "corp", linked.Provider)
+		require.NotNil(t, linked.Email)
+		assert.Equal(t, "link@example.com", *linked.Email)
+
+		w = doJSON(router, "POST", "/auth/oidc/corp/callback", "", authorizeAt(t, router, idp, ""))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response api.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, user.ID, response.User.Id)
+
+		w = doJSON(router, "GET", "/users/me/identities", token, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var identities []api.LinkedIdentity
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &identities))
+		assert.Len(t, identities, 1)
+	})
+
+	t.Run("identity linked to another user", func(t *testing.T) {
+		other := models.User{Username: "otherlinker"}
+		other.SetPassword("password123")
+		server.db.Create(&other)
+		otherToken := issueTestToken(t, server.db, &other)
+
+		w := doJSON(router, "POST", "/users/me/identities/corp", otherToken, authorizeAt(t, router, idp, otherToken))
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("unlink", func(t *testing.T) {
+		w := doJSON(router, "DELETE", "/users/me/identities/corp", token, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = doJSON(router, "DELETE", "/users/me/identities/corp", token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestOIDCUnlinkLastLoginMethod(t *testing.T) {
+	_, router, idp := setupOIDCTest(t)
+	idp.SetUser(oidctest.User{Subject: "sub-only", PreferredUsername: "ssoonly"})
+
+	w := doJSON(router, "POST", "/auth/oidc/corp/callback", "", authorizeAt(t, router, idp, ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	var response api.AuthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	w = doJSON(router, "DELETE", "/users/me/identities/corp", response.Token, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestUsernameCandidates(t *testing.T) {
+	assert.Equal(t, []string{"alice", "Alice Liddell", "al"},
+		usernameCandidates(&oidc.Identity{PreferredUsername: "alice", Name: "Alice Liddell", Email: "al@example.com"}))
+
+	// Too long names are cut at a character boundary; too short ones are skipped
+	assert.Equal(t, []string{"张三李四王五"},
+		usernameCandidates(&oidc.Identity{Name: "张三李四王五赵六钱七", Email: "x@example.com"}))
+	assert.Empty(t, usernameCandidates(&oidc.Identity{Subject: "sub"}))
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/avatar"
+	"github.com/yourusername/cowatch/api-gateway/internal/logging"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/repository"
+)
+
+// maxAvatarBytes is the largest accepted avatar upload
+const maxAvatarBytes = 5 << 20
+
+// firstPasswordSignInWindow is how recently an account without a password must have
+// signed in to set one. Refreshing tokens keeps the session, so it doesn't count.
+const firstPasswordSignInWindow = 10 * time.Minute
+
+// validUsername reports whether a username has an acceptable length
+func validUsername(username string) bool {
+	return len(username) >= 2 && len(username) <= 20
+}
+
+// validPassword reports whether a password has an acceptable length
+func validPassword(password string) bool {
+	return len(password) >= 6 && len(password) <= 100
+}
+
+// PatchUsersMe updates the current user's profile
+// PATCH /users/me
+func (s *Server) PatchUsersMe(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	var req api.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请求参数错误")
+		return
+	}
+
+	if req.Username != nil {
+		if !validUsername(*req.Username) {
+			respondError(c, http.StatusBadRequest, "INVALID_USERNAME", "用户名长度必须在2-20个字符之间")
+			return
+		}
+		taken, err := s.users.UsernameTaken(c.Request.Context(), *req.Username, user.ID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "修改用户名失败")
+			return
+		}
+		if taken {
+			respondError(c, http.StatusConflict, "USERNAME_EXISTS", "用户名已存在")
+			return
+		}
+
+		// The check above gives the usual answer; the unique index settles races
+		err = s.users.SetUsername(c.Request.Context(), user, *req.Username)
+		if errors.Is(err, repository.ErrDuplicate) {
+			respondError(c, http.StatusConflict, "USERNAME_EXISTS", "用户名已存在")
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "修改用户名失败")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, api.User{
+		Id:        user.ID,
+		Username:  user.Username,
+		AvatarUrl: user.AvatarURL,
+	})
+}
+
+// PutUsersMePassword changes the current user's password and signs out their other sessions
+// PUT /users/me/password
+func (s *Server) PutUsersMePassword(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+	session, ok := middleware.GetSession(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	var req api.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请求参数错误")
+		return
+	}
+
+	// The old password is throttled like login, so a stolen access token can't be used to guess it.
+	// Accounts created through an identity provider have no old password; they may set a first
+	// one only from a fresh sign-in, so a stolen token can't be turned into a password login.
+	if user.HasPassword() {
+		check := reserveAttempts(c, s.accountAttempts(c, user.Username)...)
+		if check == nil {
+			return
+		}
+		defer check.done()
+		if !user.CheckPassword(req.OldPassword) {
+			check.fail()
+			respondError(c, http.StatusForbidden, "WRONG_PASSWORD", "当前密码错误")
+			return
+		}
+	} else if time.Since(session.CreatedAt) > firstPasswordSignInWindow {
+		respondError(c, http.StatusForbidden, "REAUTH_REQUIRED", "请重新登录后再设置密码")
+		return
+	}
+	if !validPassword(req.NewPassword) {
+		respondError(c, http.StatusBadRequest, "INVALID_PASSWORD", "密码长度必须在6-100个字符之间")
+		return
+	}
+
+	if err := user.SetPassword(req.NewPassword); err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "服务器内部错误")
+		return
+	}
+	if err := s.db.Model(user).Update("password_hash", user.PasswordHash).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "修改密码失败")
+		return
+	}
+
+	// Anyone holding the old password may have signed in elsewhere; keep only this session
+	if err := revokeUserSessions(s.db, user.ID, session.ID); err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "注销其他会话失败")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PutUsersMeAvatar uploads a new avatar for the current user
+// PUT /users/me/avatar
+func (s *Server) PutUsersMeAvatar(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+	if s.storage == nil {
+		respondError(c, http.StatusServiceUnavailable, "STORAGE_UNAVAILABLE", "头像上传暂不可用")
+		return
+	}
+
+	// Leave some room for the multipart envelope around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAvatarBytes+64<<10)
+
+	file, header, err := c.Request.FormFile("avatar")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, http.StatusRequestEntityTooLarge, "AVATAR_TOO_LARGE", "头像文件不能超过5MB")
+			return
+		}
+		respondError(c, http.StatusBadRequest, "INVALID_AVATAR", "请选择头像文件")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxAvatarBytes {
+		respondError(c, http.StatusRequestEntityTooLarge, "AVATAR_TOO_LARGE", "头像文件不能超过5MB")
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxAvatarBytes))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_AVATAR", "读取头像文件失败")
+		return
+	}
+
+	processed, err := avatar.Process(data)
+	switch {
+	case errors.Is(err, avatar.ErrUnsupportedFormat):
+		respondError(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_AVATAR_TYPE", "仅支持 JPEG、PNG、GIF、WebP 格式")
+		return
+	case errors.Is(err, avatar.ErrImageTooLarge):
+		respondError(c, http.StatusRequestEntityTooLarge, "AVATAR_TOO_LARGE", "头像尺寸过大")
+		return
+	case err != nil:
+		respondError(c, http.StatusBadRequest, "INVALID_AVATAR", "无法识别的图片")
+		return
+	}
+
+	// A fresh key per upload so caches never serve the previous avatar
+	key := "avatars/" + user.ID + "/" + uuid.New().String() + ".png"
+	url, err := s.storage.Put(c.Request.Context(), key, avatar.ContentType, processed)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "保存头像失败")
+		return
+	}
+
+	// Copy the old key out; gorm writes updated values through the existing pointer
+	var oldKey string
+	if user.AvatarKey != nil {
+		oldKey = *user.AvatarKey
+	}
+	if err := s.db.Model(user).Updates(map[string]interface{}{
+		"avatar_url": url,
+		"avatar_key": key,
+	}).Error; err != nil {
+		s.storage.Delete(c.Request.Context(), key)
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "保存头像失败")
+		return
+	}
+
+	// The old file is unreferenced now; a failed delete only leaves an orphan behind
+	if oldKey != "" {
+		if err := s.storage.Delete(c.Request.Context(), oldKey); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("Failed to delete old avatar", "key", oldKey, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, api.User{
+		Id:        user.ID,
+		Username:  user.Username,
+		AvatarUrl: user.AvatarURL,
+	})
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+	"github.com/yourusername/cowatch/api-gateway/internal/storage"
+)
+
+func TestPatchUsersMe(t *testing.T) {
+	server, router := setupTestServer(t)
+	router.PATCH("/users/me", middleware.AuthMiddleware(server.db, server.tokens), server.PatchUsersMe)
+
+	user := models.User{Username: "oldname"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+	token := issueTestToken(t, server.db, &user)
+
+	other := models.User{Username: "takenname"}
+	other.SetPassword("password123")
+	server.db.Create(&other)
+
+	patchBody := func(body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/users/me", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	patch := func(username string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(api.UpdateProfileRequest{Username: &username})
+		return patchBody(body)
+	}
+
+	t.Run("username already taken", func(t *testing.T) {
+		w := patch("takenname")
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("username too short", func(t *testing.T) {
+		w := patch("a")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("change username", func(t *testing.T) {
+		w := patch("newname")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response api.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "newname", response.Username)
+
+		var stored models.User
+		server.db.First(&stored, "id = ?", user.ID)
+		assert.Equal(t, "newname", stored.Username)
+	})
+
+	t.Run("keeping the same username is allowed", func(t *testing.T) {
+		w := patch("newname")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("username is optional", func(t *testing.T) {
+		w := patchBody([]byte(`{}`))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response api.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "newname", response.Username)
+	})
+}
+
+func TestPutUsersMePassword(t *testing.T) {
+	server, router := setupTestServer(t)
+	authMiddleware := middleware.AuthMiddleware(server.db, server.tokens)
+	router.PUT("/users/me/password", authMiddleware, server.PutUsersMePassword)
+	router.GET("/auth/me", authMiddleware, server.GetAuthMe)
+
+	user := models.User{Username: "pwuser"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+	token := issueTestToken(t, server.db, &user)
+	otherToken := issueTestToken(t, server.db, &user)
+
+	changePassword := func(oldPassword, newPassword string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(api.ChangePasswordRequest{OldPassword: oldPassword, NewPassword: newPassword})
+		req := httptest.NewRequest("PUT", "/users/me/password", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	getMe := func(token string) int {
+		req := httptest.NewRequest("GET", "/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("wrong old password", func(t *testing.T) {
+		w := changePassword("wrongpassword", "newpassword456")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("new password too short", func(t *testing.T) {
+		w := changePassword("password123", "123")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("change password revokes other sessions", func(t *testing.T) {
+		w := changePassword("password123", "newpassword456")
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		var stored models.User
+		server.db.First(&stored, "id = ?", user.ID)
+		assert.True(t, stored.CheckPassword("newpassword456"))
+		assert.False(t, stored.CheckPassword("password123"))
+
+		assert.Equal(t, http.StatusOK, getMe(token), "current session stays signed in")
+		assert.Equal(t, http.StatusUnauthorized, getMe(otherToken), "other sessions are signed out")
+	})
+}
+
+func TestPutUsersMeFirstPassword(t *testing.T) {
+	server, router := setupTestServer(t)
+	authMiddleware := middleware.AuthMiddleware(server.db, server.tokens)
+	router.PUT("/users/me/password", authMiddleware, server.PutUsersMePassword)
+	router.GET("/auth/me", authMiddleware, server.GetAuthMe)
+
+	// Signed up through an identity provider, so there's no password yet
+	user := models.User{Username: "oidcuser"}
+	require.NoError(t, server.db.Create(&user).Error)
+	staleToken := issueTestToken(t, server.db, &user)
+	require.NoError(t, server.db.Model(&models.AuthSession{}).
+		Where("user_id = ?", user.ID).
+		Update("created_at", time.Now().Add(-time.Hour)).Error)
+	token := issueTestToken(t, server.db, &user)
+
+	setPassword := func(token string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(api.ChangePasswordRequest{NewPassword: "newpassword456"})
+		req := httptest.NewRequest("PUT", "/users/me/password", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	getMe := func(token string) int {
+		req := httptest.NewRequest("GET", "/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("needs a recent sign-in", func(t *testing.T) {
+		w := setPassword(staleToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "REAUTH_REQUIRED")
+
+		var stored models.User
+		server.db.First(&stored, "id = ?", user.ID)
+		assert.False(t, stored.HasPassword())
+	})
+
+	t.Run("first password revokes other sessions", func(t *testing.T) {
+		w := setPassword(token)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		var stored models.User
+		server.db.First(&stored, "id = ?", user.ID)
+		assert.True(t, stored.CheckPassword("newpassword456"))
+
+		assert.Equal(t, http.StatusOK, getMe(token), "current session stays signed in")
+		assert.Equal(t, http.StatusUnauthorized, getMe(staleToken), "other sessions are signed out")
+	})
+}
+
+func avatarRequest(t *testing.T, token, filename string, data []byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("avatar", filename)
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("PUT", "/users/me/avatar", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestPutUsersMeAvatar(t *testing.T) {
+	server, router := setupTestServer(t)
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(dir, "/uploads")
+	require.NoError(t, err)
+	server.storage = store
+	router.PUT("/users/me/avatar", middleware.AuthMiddleware(server.db, server.tokens), server.PutUsersMeAvatar)
+
+	user := models.User{Username: "avataruser"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+	token := issueTestToken(t, server.db, &user)
+
+	var pngData bytes.Buffer
+	require.NoError(t, png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 300, 200))))
+
+	upload := func(t *testing.T) string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, avatarRequest(t, token, "me.png", pngData.Bytes()))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response api.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.AvatarUrl)
+		assert.True(t, strings.HasPrefix(*response.AvatarUrl, "/uploads/avatars/"+user.ID+"/"))
+		return strings.TrimPrefix(*response.AvatarUrl, "/uploads/")
+	}
+
+	t.Run("upload is resized and replaces the old avatar", func(t *testing.T) {
+		first := upload(t)
+		stored, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(first)))
+		require.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(stored))
+		require.NoError(t, err)
+		assert.Equal(t, 256, img.Bounds().Dx())
+		assert.Equal(t, 256, img.Bounds().Dy())
+
+		second := upload(t)
+		assert.NotEqual(t, first, second)
+		_, err = os.Stat(filepath.Join(dir, filepath.FromSlash(first)))
+		assert.True(t, os.IsNotExist(err), "previous avatar should be deleted")
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, avatarRequest(t, token, "me.png", []byte("<svg></svg>")))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("file too large", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, avatarRequest(t, token, "me.png", make([]byte, maxAvatarBytes+1)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "/users/me/avatar", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
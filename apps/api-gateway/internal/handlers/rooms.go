@@ -1,18 +1,22 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
 	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/logging"
 	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
 	"github.com/yourusername/cowatch/api-gateway/internal/models"
+	"github.com/yourusername/cowatch/api-gateway/internal/repository"
 )
 
-// GetRooms returns a list of rooms
+// GetRooms returns a list of public rooms
 // GET /rooms
 func (s *Server) GetRooms(c *gin.Context, params api.GetRoomsParams) {
 	limit := 20
@@ -25,13 +29,36 @@ func (s *Server) GetRooms(c *gin.Context, params api.GetRoomsParams) {
 		offset = *params.Offset
 	}
 
+	sort := api.Newest
+	if params.Sort != nil {
+		sort = *params.Sort
+	}
+
+	filter := repository.RoomFilter{Sort: repository.SortNewest, HasPassword: params.HasPassword}
+	if params.Q != nil {
+		filter.Query = *params.Q
+	}
+	if sort == api.Activity {
+		filter.Sort = repository.SortActivity
+	}
+
+	// Online counts and playback state come from the WebSocket hub
+	live := sort == api.Online || isTrue(params.HasSpace) || isTrue(params.IsPlaying)
+	if live && s.presence == nil {
+		respondError(c, http.StatusBadRequest, "PRESENCE_UNAVAILABLE", "暂不支持按在线状态筛选或排序")
+		return
+	}
+
 	var rooms []models.Room
-	if err := s.db.Preload("Owner").
-		Where("is_active = ?", true).
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&rooms).Error; err != nil {
+	var err error
+	if live {
+		rooms, err = s.listLiveRooms(c.Request.Context(), filter, params, sort, limit, offset)
+	} else {
+		filter.Limit = limit
+		filter.Offset = offset
+		rooms, err = s.rooms.List(c.Request.Context(), filter)
+	}
+	if err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "获取房间列表失败")
 		return
 	}
@@ -39,12 +66,74 @@ func (s *Server) GetRooms(c *gin.Context, params api.GetRoomsParams) {
 	// Get current user if authenticated
 	user, _ := middleware.GetUser(c)
 
-	result := make([]api.Room, len(rooms))
-	for i, room := range rooms {
-		result[i] = roomToAPI(&room, user, s.db)
+	c.JSON(http.StatusOK, s.roomsToAPI(c.Request.Context(), rooms, user))
+}
+
+// listLiveRooms serves the presence-based filters and the online sort. Only rooms with
+// connections can be full, playing or ranked by online count, so just those are loaded
+// and checked in memory; every other room is filtered and paged in SQL.
+func (s *Server) listLiveRooms(ctx context.Context, filter repository.RoomFilter, params api.GetRoomsParams, sort api.GetRoomsParamsSort, limit, offset int) ([]models.Room, error) {
+	counts := s.presence.OnlineCounts()
+	liveIDs := make([]string, 0, len(counts))
+	for id := range counts {
+		liveIDs = append(liveIDs, id)
+	}
+
+	liveFilter := filter
+	liveFilter.IDs = liveIDs
+	liveRooms, err := s.rooms.List(ctx, liveFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	var full []string
+	matched := liveRooms[:0]
+	for _, room := range liveRooms {
+		if counts[room.ID] >= room.MaxUsers {
+			full = append(full, room.ID)
+			if isTrue(params.HasSpace) {
+				continue
+			}
+		}
+		if isTrue(params.IsPlaying) && !s.presence.IsPlaying(room.ID) {
+			continue
+		}
+		matched = append(matched, room)
+	}
+	if sort == api.Online {
+		// Stable sort keeps the requested order among rooms with equal counts
+		slices.SortStableFunc(matched, func(a, b models.Room) int {
+			return counts[b.ID] - counts[a.ID]
+		})
 	}
 
-	c.JSON(http.StatusOK, result)
+	// Nothing plays in a room nobody is in
+	if isTrue(params.IsPlaying) {
+		return paginate(matched, limit, offset), nil
+	}
+
+	if sort == api.Online {
+		page := paginate(matched, limit, offset)
+		if len(page) == limit {
+			return page, nil
+		}
+		// Rooms without connections follow, all with space and no one online
+		idleFilter := filter
+		idleFilter.ExcludeIDs = liveIDs
+		idleFilter.Limit = limit - len(page)
+		idleFilter.Offset = max(0, offset-len(matched))
+		idle, err := s.rooms.List(ctx, idleFilter)
+		if err != nil {
+			return nil, err
+		}
+		return append(page, idle...), nil
+	}
+
+	// Only hasSpace is left: leave the full rooms out
+	filter.ExcludeIDs = full
+	filter.Limit = limit
+	filter.Offset = offset
+	return s.rooms.List(ctx, filter)
 }
 
 // PostRooms creates a new room
@@ -69,9 +158,22 @@ func (s *Server) PostRooms(c *gin.Context) {
 	}
 
 	room := models.Room{
-		Name:     req.Name,
-		OwnerID:  user.ID,
-		IsActive: true,
+		Name:       req.Name,
+		OwnerID:    user.ID,
+		IsActive:   true,
+		Visibility: models.RoomVisibilityPublic,
+	}
+
+	if req.Visibility != nil {
+		if !models.IsValidVisibility(string(*req.Visibility)) {
+			respondError(c, http.StatusBadRequest, "INVALID_VISIBILITY", "无效的房间可见性")
+			return
+		}
+		room.Visibility = string(*req.Visibility)
+	}
+
+	if req.AllowGuests != nil {
+		room.AllowGuests = *req.AllowGuests
 	}
 
 	if req.MaxUsers != nil && *req.MaxUsers >= 2 && *req.MaxUsers <= 50 {
@@ -85,30 +187,32 @@ func (s *Server) PostRooms(c *gin.Context) {
 		}
 	}
 
-	if err := s.db.Create(&room).Error; err != nil {
+	ctx := c.Request.Context()
+	if err := s.rooms.Create(ctx, &room); err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "创建房间失败")
 		return
 	}
 
-	// Reload with owner
-	s.db.Preload("Owner").First(&room, "id = ?", room.ID)
-
 	// Add creator as first member
 	member := models.RoomMember{
 		RoomID:        room.ID,
 		UserID:        user.ID,
 		LastVisitedAt: time.Now(),
 	}
-	s.db.Create(&member)
+	if err := s.members.Create(ctx, &member); err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "创建房间失败")
+		return
+	}
 
-	c.JSON(http.StatusCreated, roomToAPI(&room, user, s.db))
+	c.JSON(http.StatusCreated, s.roomToAPI(&room, user, &member))
 }
 
 // GetRoomsRoomCode returns room details by code
 // GET /rooms/{roomCode}
 func (s *Server) GetRoomsRoomCode(c *gin.Context, roomCode string) {
-	var room models.Room
-	if err := s.db.Preload("Owner").Where("code = ?", roomCode).First(&room).Error; err != nil {
+	ctx := c.Request.Context()
+	room, err := s.rooms.GetByCode(ctx, roomCode, false)
+	if err != nil {
 		respondError(c, http.StatusNotFound, "ROOM_NOT_FOUND", "房间不存在")
 		return
 	}
@@ -116,7 +220,57 @@ func (s *Server) GetRoomsRoomCode(c *gin.Context, roomCode string) {
 	// Get current user if authenticated
 	user, _ := middleware.GetUser(c)
 
-	c.JSON(http.StatusOK, roomToAPI(&room, user, s.db))
+	// Private rooms are hidden from anyone who isn't already in them
+	if room.Visibility == models.RoomVisibilityPrivate {
+		isMember, err := s.isRoomMember(ctx, room, user)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "获取房间失败")
+			return
+		}
+		if !isMember {
+			respondError(c, http.StatusNotFound, "ROOM_NOT_FOUND", "房间不存在")
+			return
+		}
+	}
+
+	s.respondRoom(c, room, user)
+}
+
+// PatchRoomsRoomCode updates room settings; only the owner may change them
+// PATCH /rooms/{roomCode}
+func (s *Server) PatchRoomsRoomCode(c *gin.Context, roomCode string) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+		return
+	}
+
+	var req api.UpdateRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "请求参数错误")
+		return
+	}
+
+	ctx := c.Request.Context()
+	room, err := s.rooms.GetByCode(ctx, roomCode, true)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "ROOM_NOT_FOUND", "房间不存在")
+		return
+	}
+
+	if room.OwnerID != user.ID {
+		respondError(c, http.StatusForbidden, "FORBIDDEN", "只有房主可以修改房间设置")
+		return
+	}
+
+	// Turning guests off also invalidates their tokens, which are checked against this flag
+	update := repository.RoomUpdate{AllowGuests: req.AllowGuests}
+	if err := s.rooms.Update(ctx, room, update); err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "更新房间失败")
+		return
+	}
+
+	s.respondRoom(c, room, user)
 }
 
 // PostRoomsRoomCodeJoin allows a user to join a room
@@ -129,12 +283,28 @@ func (s *Server) PostRoomsRoomCodeJoin(c *gin.Context, roomCode string) {
 	}
 
 	// Find room
-	var room models.Room
-	if err := s.db.Preload("Owner").Where("code = ?", roomCode).First(&room).Error; err != nil {
+	ctx := c.Request.Context()
+	room, err := s.rooms.GetByCode(ctx, roomCode, false)
+	if err != nil {
 		respondError(c, http.StatusNotFound, "ROOM_NOT_FOUND", "房间不存在")
 		return
 	}
 
+	// Private rooms can only be re-entered by the owner and existing members
+	isMember, err := s.isRoomMember(ctx, room, user)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "加入房间失败")
+		return
+	}
+	if room.Visibility == models.RoomVisibilityPrivate && !isMember {
+		respondError(c, http.StatusForbidden, "ROOM_PRIVATE", "该房间为私密房间，仅限成员加入")
+		return
+	}
+	if !isMember && s.roomFull(room) {
+		respondError(c, http.StatusConflict, "ROOM_FULL", "房间已满")
+		return
+	}
+
 	// Check password if required
 	if room.HasPassword() {
 		var req api.PostRoomsRoomCodeJoinJSONRequestBody
@@ -148,35 +318,111 @@ func (s *Server) PostRoomsRoomCodeJoin(c *gin.Context, roomCode string) {
 			password = *req.Password
 		}
 
+		check := reserveAttempts(c, s.roomPasswordAttempts(c, room)...)
+		if check == nil {
+			return
+		}
+		defer check.done()
 		if !room.CheckPassword(password) {
+			check.fail()
 			respondError(c, http.StatusForbidden, "WRONG_PASSWORD", "密码错误")
 			return
 		}
 	}
 
 	// Add or update room membership
-	var member models.RoomMember
-	result := s.db.Where("room_id = ? AND user_id = ?", room.ID, user.ID).First(&member)
-	if result.Error != nil {
-		// Create new membership
-		member = models.RoomMember{
-			RoomID:        room.ID,
-			UserID:        user.ID,
-			LastVisitedAt: time.Now(),
-		}
-		s.db.Create(&member)
-	} else {
-		// Update last visited time
-		s.db.Model(&member).Update("last_visited_at", time.Now())
+	now := time.Now()
+	member, err := s.members.Visit(ctx, room.ID, user.ID, now)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "加入房间失败")
+		return
 	}
 
-	c.JSON(http.StatusOK, roomToAPI(&room, user, s.db))
+	// Keep the room from being expired by the idle cleanup job
+	if err := s.rooms.Touch(ctx, room.ID, now); err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "加入房间失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, s.roomToAPI(room, user, member))
 }
 
-// roomToAPI converts a models.Room to api.Room
-func roomToAPI(room *models.Room, currentUser *models.User, db *gorm.DB) api.Room {
+// roomPasswordAttempts are the limiter keys a room password check counts against
+func (s *Server) roomPasswordAttempts(c *gin.Context, room *models.Room) []attempt {
+	return []attempt{
+		{s.throttles.IP, c.ClientIP()},
+		{s.throttles.Room, room.ID},
+	}
+}
+
+// roomFull reports whether room has as many people online as it allows.
+// Existing members may always come back; the limit applies to newcomers.
+func (s *Server) roomFull(room *models.Room) bool {
+	return s.presence != nil && s.presence.GetClientCount(room.ID) >= room.MaxUsers
+}
+
+// isRoomMember reports whether user owns or has joined room
+func (s *Server) isRoomMember(ctx context.Context, room *models.Room, user *models.User) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+	if user.ID == room.OwnerID {
+		return true, nil
+	}
+	_, err := s.members.Get(ctx, room.ID, user.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// roomsToAPI converts a room list for currentUser, loading their memberships in one query
+func (s *Server) roomsToAPI(ctx context.Context, rooms []models.Room, currentUser *models.User) []api.Room {
+	var members map[string]*models.RoomMember
+	if currentUser != nil && len(rooms) > 0 {
+		roomIDs := make([]string, len(rooms))
+		for i := range rooms {
+			roomIDs[i] = rooms[i].ID
+		}
+		var err error
+		if members, err = s.members.ForUser(ctx, currentUser.ID, roomIDs); err != nil {
+			// Roles are decoration on a list; show the rooms without them rather than fail
+			logging.FromContext(ctx).Error("Failed to load room memberships", "user_id", currentUser.ID, "error", err)
+		}
+	}
+
+	result := make([]api.Room, len(rooms))
+	for i := range rooms {
+		result[i] = s.roomToAPI(&rooms[i], currentUser, members[rooms[i].ID])
+	}
+	return result
+}
+
+// respondRoom writes a single room for currentUser, looking up their membership
+func (s *Server) respondRoom(c *gin.Context, room *models.Room, currentUser *models.User) {
+	var member *models.RoomMember
+	if currentUser != nil && currentUser.ID != room.OwnerID {
+		var err error
+		member, err = s.members.Get(c.Request.Context(), room.ID, currentUser.ID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "获取房间失败")
+			return
+		}
+	}
+	c.JSON(http.StatusOK, s.roomToAPI(room, currentUser, member))
+}
+
+// roomToAPI converts a models.Room to api.Room. member is currentUser's membership of
+// the room, nil if they haven't joined.
+func (s *Server) roomToAPI(room *models.Room, currentUser *models.User, member *models.RoomMember) api.Room {
 	hasPassword := room.HasPassword()
-	userCount := 0 // TODO: Get actual online user count from WebSocket hub
+	visibility := api.RoomVisibility(room.Visibility)
+	userCount := 0
+	isPlaying := false
+	if s.presence != nil {
+		userCount = s.presence.GetClientCount(room.ID)
+		isPlaying = s.presence.IsPlaying(room.ID)
+	}
 
 	result := api.Room{
 		Id:          room.ID,
@@ -184,6 +430,9 @@ func roomToAPI(room *models.Room, currentUser *models.User, db *gorm.DB) api.Roo
 		Name:        room.Name,
 		OwnerId:     room.OwnerID,
 		IsActive:    room.IsActive,
+		Visibility:  &visibility,
+		AllowGuests: &room.AllowGuests,
+		IsPlaying:   &isPlaying,
 		HasPassword: &hasPassword,
 		MaxUsers:    &room.MaxUsers,
 		UserCount:   &userCount,
@@ -204,9 +453,7 @@ func roomToAPI(room *models.Room, currentUser *models.User, db *gorm.DB) api.Roo
 			result.CurrentUserHasControl = &hasControl
 		} else {
 			// User is a member, check for control permission
-			var member models.RoomMember
-			err := db.Where("room_id = ? AND user_id = ?", room.ID, currentUser.ID).First(&member).Error
-			if err == nil {
+			if member != nil {
 				// User is a member
 				role := api.Member
 				result.CurrentUserRole = &role
@@ -229,3 +476,19 @@ func roomToAPI(room *models.Room, currentUser *models.User, db *gorm.DB) api.Roo
 
 	return result
 }
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// paginate returns the page of rooms starting at offset
+func paginate(rooms []models.Room, limit, offset int) []models.Room {
+	if offset >= len(rooms) {
+		return []models.Room{}
+	}
+	end := offset + limit
+	if end > len(rooms) {
+		end = len(rooms)
+	}
+	return rooms[offset:end]
+}
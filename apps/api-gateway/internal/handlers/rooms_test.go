@@ -1,19 +1,24 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 
 	"github.com/yourusername/cowatch/api-gateway/internal/api"
 	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
 	"github.com/yourusername/cowatch/api-gateway/internal/models"
+	"github.com/yourusername/cowatch/api-gateway/internal/repository"
 )
 
 func TestGetRooms(t *testing.T) {
@@ -51,15 +56,114 @@ func TestGetRooms(t *testing.T) {
 	})
 }
 
+// fakePresence is a static RoomPresence for tests
+type fakePresence struct {
+	counts  map[string]int
+	playing map[string]bool
+}
+
+func (p fakePresence) GetClientCount(roomID string) int { return p.counts[roomID] }
+func (p fakePresence) IsPlaying(roomID string) bool     { return p.playing[roomID] }
+func (p fakePresence) OnlineCounts() map[string]int     { return p.counts }
+func (p fakePresence) DisconnectUser(string, string)    {}
+func (p fakePresence) CloseRoom(string, string)         {}
+
+func TestGetRoomsSearchAndFilters(t *testing.T) {
+	server, router := setupTestServer(t)
+	router.GET("/rooms", func(c *gin.Context) {
+		var params api.GetRoomsParams
+		require.NoError(t, c.BindQuery(&params))
+		server.GetRooms(c, params)
+	})
+
+	user := models.User{Username: "filterowner"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+
+	movie := models.Room{Name: "Movie Night", OwnerID: user.ID, IsActive: true, MaxUsers: 2}
+	anime := models.Room{Name: "Anime Club", OwnerID: user.ID, IsActive: true}
+	anime.SetPassword("secret")
+	hidden := models.Room{Name: "Movie Secret", OwnerID: user.ID, IsActive: true, Visibility: models.RoomVisibilityUnlisted}
+	private := models.Room{Name: "Movie Private", OwnerID: user.ID, IsActive: true, Visibility: models.RoomVisibilityPrivate}
+	quiet := models.Room{Name: "Quiet Room", OwnerID: user.ID, IsActive: true}
+	for i, room := range []*models.Room{&movie, &anime, &hidden, &private, &quiet} {
+		room.CreatedAt = time.Now().Add(time.Duration(i) * time.Minute)
+		server.db.Create(room)
+	}
+
+	server.presence = fakePresence{
+		counts:  map[string]int{movie.ID: 2, anime.ID: 1},
+		playing: map[string]bool{anime.ID: true},
+	}
+
+	list := func(t *testing.T, query string) []api.Room {
+		req := httptest.NewRequest("GET", "/rooms"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var rooms []api.Room
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rooms))
+		return rooms
+	}
+
+	names := func(rooms []api.Room) []string {
+		result := make([]string, len(rooms))
+		for i, room := range rooms {
+			result[i] = room.Name
+		}
+		return result
+	}
+
+	t.Run("only public rooms are listed", func(t *testing.T) {
+		assert.Equal(t, []string{"Quiet Room", "Anime Club", "Movie Night"}, names(list(t, "")))
+	})
+
+	t.Run("search by name", func(t *testing.T) {
+		assert.Equal(t, []string{"Movie Night"}, names(list(t, "?q=movie")))
+		assert.Empty(t, list(t, "?q=%25"))
+	})
+
+	t.Run("filter by password", func(t *testing.T) {
+		assert.Equal(t, []string{"Anime Club"}, names(list(t, "?hasPassword=true")))
+		assert.Equal(t, []string{"Quiet Room", "Movie Night"}, names(list(t, "?hasPassword=false")))
+	})
+
+	t.Run("filter by space and playing", func(t *testing.T) {
+		assert.Equal(t, []string{"Quiet Room", "Anime Club"}, names(list(t, "?hasSpace=true")))
+		assert.Equal(t, []string{"Anime Club"}, names(list(t, "?hasSpace=true&limit=1&offset=1")))
+		assert.Equal(t, []string{"Anime Club"}, names(list(t, "?isPlaying=true")))
+	})
+
+	t.Run("sort by online count", func(t *testing.T) {
+		rooms := list(t, "?sort=online")
+		assert.Equal(t, []string{"Movie Night", "Anime Club", "Quiet Room"}, names(rooms))
+		assert.Equal(t, 2, *rooms[0].UserCount)
+
+		assert.Equal(t, []string{"Anime Club"}, names(list(t, "?sort=online&limit=1&offset=1")))
+		assert.Equal(t, []string{"Anime Club", "Quiet Room"}, names(list(t, "?sort=online&limit=2&offset=1")))
+		assert.Equal(t, []string{"Quiet Room"}, names(list(t, "?sort=online&offset=2")))
+		assert.Equal(t, []string{"Movie Night", "Quiet Room"}, names(list(t, "?sort=online&hasPassword=false")))
+	})
+
+	t.Run("live filters need presence", func(t *testing.T) {
+		server.presence = nil
+		req := httptest.NewRequest("GET", "/rooms?sort=online", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
 func TestPostRooms(t *testing.T) {
 	server, router := setupTestServer(t)
-	router.POST("/rooms", middleware.AuthMiddleware(server.db, testJWTSecret), server.PostRooms)
+	router.POST("/rooms", middleware.AuthMiddleware(server.db, server.tokens), server.PostRooms)
 
 	// Create test user
 	user := models.User{Username: "roomcreator"}
 	user.SetPassword("password123")
 	server.db.Create(&user)
-	token, _ := middleware.GenerateToken(&user, testJWTSecret)
+	token := issueTestToken(t, server.db, &user)
 
 	t.Run("create room successfully", func(t *testing.T) {
 		body := `{"name":"My Test Room"}`
@@ -97,6 +201,35 @@ func TestPostRooms(t *testing.T) {
 		assert.True(t, *room.HasPassword)
 	})
 
+	t.Run("create unlisted room", func(t *testing.T) {
+		body := `{"name":"Quiet Room","visibility":"unlisted"}`
+		req := httptest.NewRequest("POST", "/rooms", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var room api.Room
+		err := json.Unmarshal(w.Body.Bytes(), &room)
+		require.NoError(t, err)
+		assert.Equal(t, api.Unlisted, *room.Visibility)
+	})
+
+	t.Run("create room with invalid visibility", func(t *testing.T) {
+		body := `{"name":"Odd Room","visibility":"secret"}`
+		req := httptest.NewRequest("POST", "/rooms", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
 	t.Run("create room without auth", func(t *testing.T) {
 		body := `{"name":"Unauthorized Room"}`
 		req := httptest.NewRequest("POST", "/rooms", strings.NewReader(body))
@@ -142,6 +275,23 @@ func TestGetRoomsRoomCode(t *testing.T) {
 		assert.Equal(t, "Findable Room", response.Name)
 	})
 
+	t.Run("private room is hidden from non-members", func(t *testing.T) {
+		private := models.Room{
+			Name:       "Hidden Room",
+			OwnerID:    user.ID,
+			IsActive:   true,
+			Visibility: models.RoomVisibilityPrivate,
+		}
+		server.db.Create(&private)
+
+		req := httptest.NewRequest("GET", "/rooms/"+private.Code, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
 	t.Run("get non-existent room", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/rooms/NOTFOUND", nil)
 		w := httptest.NewRecorder()
@@ -154,7 +304,7 @@ func TestGetRoomsRoomCode(t *testing.T) {
 
 func TestPostRoomsRoomCodeJoin(t *testing.T) {
 	server, router := setupTestServer(t)
-	router.POST("/rooms/:roomCode/join", middleware.AuthMiddleware(server.db, testJWTSecret), func(c *gin.Context) {
+	router.POST("/rooms/:roomCode/join", middleware.AuthMiddleware(server.db, server.tokens), func(c *gin.Context) {
 		server.PostRoomsRoomCodeJoin(c, c.Param("roomCode"))
 	})
 
@@ -166,7 +316,7 @@ func TestPostRoomsRoomCodeJoin(t *testing.T) {
 	joiner := models.User{Username: "roomjoiner"}
 	joiner.SetPassword("password123")
 	server.db.Create(&joiner)
-	token, _ := middleware.GenerateToken(&joiner, testJWTSecret)
+	token := issueTestToken(t, server.db, &joiner)
 
 	// Create public room
 	publicRoom := models.Room{
@@ -219,6 +369,41 @@ func TestPostRoomsRoomCodeJoin(t *testing.T) {
 		assert.Equal(t, http.StatusForbidden, w.Code)
 	})
 
+	t.Run("join private room as non-member", func(t *testing.T) {
+		privateOnly := models.Room{
+			Name:       "Members Only",
+			OwnerID:    owner.ID,
+			IsActive:   true,
+			Visibility: models.RoomVisibilityPrivate,
+		}
+		server.db.Create(&privateOnly)
+
+		req := httptest.NewRequest("POST", "/rooms/"+privateOnly.Code+"/join", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("join full room", func(t *testing.T) {
+		full := models.Room{Name: "Full Room", OwnerID: owner.ID, IsActive: true, MaxUsers: 2}
+		server.db.Create(&full)
+		server.presence = fakePresence{counts: map[string]int{full.ID: 2}}
+		defer func() { server.presence = nil }()
+
+		join := func(token string) int {
+			req := httptest.NewRequest("POST", "/rooms/"+full.Code+"/join", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+		assert.Equal(t, http.StatusConflict, join(token))
+		assert.Equal(t, http.StatusOK, join(issueTestToken(t, server.db, &owner)), "the owner is always let in")
+	})
+
 	t.Run("join non-existent room", func(t *testing.T) {
 		req := httptest.NewRequest("POST", "/rooms/NOTFOUND/join", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
@@ -229,3 +414,193 @@ func TestPostRoomsRoomCodeJoin(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
 }
+
+func TestGetRoomsLoadsMembershipsTogether(t *testing.T) {
+	server, router := setupTestServer(t)
+	router.GET("/rooms", middleware.OptionalAuthMiddleware(server.db, server.tokens), func(c *gin.Context) {
+		server.GetRooms(c, api.GetRoomsParams{})
+	})
+
+	owner := models.User{Username: "listowner"}
+	viewer := models.User{Username: "listviewer"}
+	server.db.Create(&owner)
+	server.db.Create(&viewer)
+	token := issueTestToken(t, server.db, &viewer)
+
+	queries := 0
+	server.db.Callback().Query().After("gorm:query").Register("test:count", func(*gorm.DB) { queries++ })
+
+	list := func() []api.Room {
+		req := httptest.NewRequest("GET", "/rooms", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		queries = 0
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var rooms []api.Room
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rooms))
+		return rooms
+	}
+
+	addRooms := func(n int) {
+		for i := 0; i < n; i++ {
+			room := models.Room{Name: "Room", OwnerID: owner.ID, IsActive: true}
+			server.db.Create(&room)
+			server.db.Create(&models.RoomMember{RoomID: room.ID, UserID: viewer.ID, HasControlPermission: i%2 == 0})
+		}
+	}
+
+	addRooms(2)
+	rooms := list()
+	require.Len(t, rooms, 2)
+	fewRoomsQueries := queries
+
+	addRooms(8)
+	rooms = list()
+	require.Len(t, rooms, 10)
+	assert.Equal(t, fewRoomsQueries, queries, "query count shouldn't grow with the number of rooms")
+
+	control := 0
+	for _, room := range rooms {
+		assert.Equal(t, api.Member, *room.CurrentUserRole)
+		if *room.CurrentUserHasControl {
+			control++
+		}
+	}
+	assert.Equal(t, 5, control)
+}
+
+// GET /rooms is public, but a signed-in caller still sees their role in each room
+func TestGetRoomsWithRouteAuth(t *testing.T) {
+	server, router := setupTestServer(t)
+	routeAuth, err := middleware.RouteAuth("/api/v1", server.db, server.tokens)
+	require.NoError(t, err)
+	api.RegisterHandlersWithOptions(router.Group("/api/v1"), server, api.GinServerOptions{
+		Middlewares: []api.MiddlewareFunc{api.MiddlewareFunc(routeAuth)},
+	})
+
+	owner := models.User{Username: "routeowner"}
+	viewer := models.User{Username: "routeviewer"}
+	server.db.Create(&owner)
+	server.db.Create(&viewer)
+	room := models.Room{Name: "Routed", OwnerID: owner.ID, IsActive: true}
+	server.db.Create(&room)
+	server.db.Create(&models.RoomMember{RoomID: room.ID, UserID: viewer.ID})
+
+	list := func(token string) api.Room {
+		req := httptest.NewRequest("GET", "/api/v1/rooms", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var rooms []api.Room
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rooms))
+		require.Len(t, rooms, 1)
+		return rooms[0]
+	}
+
+	assert.Equal(t, api.Member, *list(issueTestToken(t, server.db, &viewer)).CurrentUserRole)
+	assert.Equal(t, api.Host, *list(issueTestToken(t, server.db, &owner)).CurrentUserRole)
+	assert.Equal(t, api.Guest, *list("").CurrentUserRole)
+}
+
+func TestRoomHandlersWithMemoryRepositories(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repos := repository.NewMemory()
+	server := NewServer(nil, newTestTokenService(t), WithRepositories(repos))
+	ctx := context.Background()
+
+	owner := &models.User{Username: "memowner"}
+	joiner := &models.User{Username: "memjoiner"}
+	require.NoError(t, repos.Users.Create(ctx, owner))
+	require.NoError(t, repos.Users.Create(ctx, joiner))
+	room := &models.Room{Name: "In Memory", OwnerID: owner.ID, IsActive: true}
+	require.NoError(t, repos.Rooms.Create(ctx, room))
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set(middleware.UserContextKey, joiner) })
+	router.GET("/rooms", func(c *gin.Context) {
+		server.GetRooms(c, api.GetRoomsParams{})
+	})
+	router.POST("/rooms/:code/join", func(c *gin.Context) {
+		server.PostRoomsRoomCodeJoin(c, c.Param("code"))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/rooms/"+room.Code+"/join", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	_, err := repos.Members.Get(ctx, room.ID, joiner.ID)
+	assert.NoError(t, err, "joining records the membership")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/rooms", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var rooms []api.Room
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rooms))
+	require.Len(t, rooms, 1)
+	assert.Equal(t, "memowner", *rooms[0].OwnerName)
+	assert.Equal(t, api.Member, *rooms[0].CurrentUserRole)
+}
+
+var errUnavailable = errors.New("database unavailable")
+
+type failingUsers struct{ repository.UserRepository }
+
+func (failingUsers) UsernameTaken(context.Context, string, string) (bool, error) {
+	return false, errUnavailable
+}
+
+type failingMembers struct {
+	repository.MembershipRepository
+}
+
+func (failingMembers) Create(context.Context, *models.RoomMember) error { return errUnavailable }
+
+func (failingMembers) Get(context.Context, string, string) (*models.RoomMember, error) {
+	return nil, errUnavailable
+}
+
+func TestRepositoryErrorsAreNotSwallowed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repos := repository.NewMemory()
+	ctx := context.Background()
+
+	owner := &models.User{Username: "failowner"}
+	joiner := &models.User{Username: "failjoiner"}
+	require.NoError(t, repos.Users.Create(ctx, owner))
+	require.NoError(t, repos.Users.Create(ctx, joiner))
+	private := &models.Room{Name: "Private", OwnerID: owner.ID, IsActive: true, Visibility: models.RoomVisibilityPrivate}
+	require.NoError(t, repos.Rooms.Create(ctx, private))
+
+	repos.Users = failingUsers{repos.Users}
+	repos.Members = failingMembers{repos.Members}
+	server := NewServer(nil, newTestTokenService(t), WithRepositories(repos))
+
+	router := gin.New()
+	router.POST("/auth/register", server.PostAuthRegister)
+	authed := router.Group("/", func(c *gin.Context) { c.Set(middleware.UserContextKey, joiner) })
+	authed.PATCH("/users/me", server.PatchUsersMe)
+	authed.POST("/rooms", server.PostRooms)
+	authed.GET("/rooms/:code", func(c *gin.Context) { server.GetRoomsRoomCode(c, c.Param("code")) })
+	authed.POST("/rooms/:code/join", func(c *gin.Context) { server.PostRoomsRoomCodeJoin(c, c.Param("code")) })
+	authed.POST("/rooms/:code/ws-ticket", func(c *gin.Context) { server.PostRoomsRoomCodeWsTicket(c, c.Param("code")) })
+
+	for _, req := range []struct{ method, path, body string }{
+		{"POST", "/auth/register", `{"username":"newuser","password":"password123"}`},
+		{"PATCH", "/users/me", `{"username":"renamed"}`},
+		{"POST", "/rooms", `{"name":"New room"}`},
+		{"GET", "/rooms/" + private.Code, ""},
+		{"POST", "/rooms/" + private.Code + "/join", ""},
+		{"POST", "/rooms/" + private.Code + "/ws-ticket", ""},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+		assert.Equal(t, http.StatusInternalServerError, w.Code, "%s %s", req.method, req.path)
+	}
+}
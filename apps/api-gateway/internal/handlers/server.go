@@ -1,23 +1,116 @@
 package handlers
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 
 	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/auth"
+	"github.com/yourusername/cowatch/api-gateway/internal/jobs"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/oidc"
+	"github.com/yourusername/cowatch/api-gateway/internal/repository"
+	"github.com/yourusername/cowatch/api-gateway/internal/storage"
 )
 
+// RoomPresence reports live room state tracked by the WebSocket hub and ends
+// connections whose access is gone
+type RoomPresence interface {
+	GetClientCount(roomID string) int
+	IsPlaying(roomID string) bool
+	// OnlineCounts returns the client count of every room that has connections
+	OnlineCounts() map[string]int
+	// DisconnectUser closes every connection of a user; CloseRoom every connection to a room
+	DisconnectUser(userID, reason string)
+	CloseRoom(roomID, reason string)
+}
+
 // Server implements the api.ServerInterface
 type Server struct {
-	db        *gorm.DB
-	jwtSecret string
+	db         *gorm.DB
+	users      repository.UserRepository
+	rooms      repository.RoomRepository
+	members    repository.MembershipRepository
+	tokens     *auth.TokenService
+	refreshTTL time.Duration
+	scheduler  *jobs.Scheduler
+	presence   RoomPresence
+	storage    storage.Storage
+	throttles  *Throttles
+	providers  *oidc.Registry
+}
+
+// Option configures optional Server dependencies
+type Option func(*Server)
+
+// WithRefreshTTL overrides the default refresh token lifetime
+func WithRefreshTTL(refresh time.Duration) Option {
+	return func(s *Server) {
+		s.refreshTTL = refresh
+	}
+}
+
+// WithScheduler exposes the background job scheduler on admin endpoints
+func WithScheduler(scheduler *jobs.Scheduler) Option {
+	return func(s *Server) {
+		s.scheduler = scheduler
+	}
+}
+
+// WithPresence lets room responses include online counts and playback state
+func WithPresence(presence RoomPresence) Option {
+	return func(s *Server) {
+		s.presence = presence
+	}
+}
+
+// WithStorage sets where uploaded avatars are stored; uploads are disabled without it
+func WithStorage(store storage.Storage) Option {
+	return func(s *Server) {
+		s.storage = store
+	}
+}
+
+// WithThrottles replaces the default brute-force limits on password checks
+func WithThrottles(throttles *Throttles) Option {
+	return func(s *Server) {
+		s.throttles = throttles
+	}
+}
+
+// WithOIDC enables login through the given identity providers
+func WithOIDC(providers *oidc.Registry) Option {
+	return func(s *Server) {
+		s.providers = providers
+	}
+}
+
+// WithRepositories replaces the GORM-backed user, room and membership repositories
+func WithRepositories(repos *repository.Repositories) Option {
+	return func(s *Server) {
+		s.users = repos.Users
+		s.rooms = repos.Rooms
+		s.members = repos.Members
+	}
 }
 
 // NewServer creates a new Server instance
-func NewServer(db *gorm.DB, jwtSecret string) *Server {
-	return &Server{
-		db:        db,
-		jwtSecret: jwtSecret,
+func NewServer(db *gorm.DB, tokens *auth.TokenService, opts ...Option) *Server {
+	repos := repository.NewGorm(db)
+	s := &Server{
+		db:         db,
+		users:      repos.Users,
+		rooms:      repos.Rooms,
+		members:    repos.Members,
+		tokens:     tokens,
+		refreshTTL: middleware.RefreshTokenExpiry,
+		throttles:  DefaultThrottles(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // Ensure Server implements ServerInterface at compile time
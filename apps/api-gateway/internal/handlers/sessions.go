@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+var (
+	errRefreshTokenInvalid = errors.New("refresh token invalid")
+	errRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// startSession creates a new login session for user and returns its first token pair
+func (s *Server) startSession(user *models.User) (api.AuthResponse, error) {
+	var response api.AuthResponse
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		session := models.AuthSession{
+			UserID:    user.ID,
+			ExpiresAt: time.Now().Add(s.refreshTTL),
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		response, err = s.issueTokens(tx, user, &session)
+		return err
+	})
+	return response, err
+}
+
+// rotateRefreshToken exchanges a refresh token for a new token pair in the same session.
+// Presenting a token that was already used revokes the whole session, since it means
+// the token was copied by someone else.
+func (s *Server) rotateRefreshToken(token string) (api.AuthResponse, error) {
+	var response api.AuthResponse
+
+	var refresh models.RefreshToken
+	if err := s.db.Preload("Session").Preload("Session.User").
+		Where("token_hash = ?", models.HashOpaqueToken(token)).
+		First(&refresh).Error; err != nil || refresh.Session == nil || refresh.Session.User == nil {
+		return response, errRefreshTokenInvalid
+	}
+
+	now := time.Now()
+	if refresh.UsedAt != nil {
+		revokeSession(s.db, refresh.SessionID)
+		return response, errRefreshTokenReused
+	}
+	if !now.Before(refresh.ExpiresAt) || !refresh.Session.IsActive(now) {
+		return response, errRefreshTokenInvalid
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Mark used atomically so two concurrent refreshes can't both succeed
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", refresh.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		var err error
+		response, err = s.issueTokens(tx, refresh.Session.User, refresh.Session)
+		return err
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		revokeSession(s.db, refresh.SessionID)
+	}
+	return response, err
+}
+
+// issueTokens signs an access token and stores a new refresh token for session
+func (s *Server) issueTokens(tx *gorm.DB, user *models.User, session *models.AuthSession) (api.AuthResponse, error) {
+	accessToken, err := s.tokens.Issue(user, session.ID)
+	if err != nil {
+		return api.AuthResponse{}, err
+	}
+
+	refreshToken, hash, err := models.NewOpaqueToken()
+	if err != nil {
+		return api.AuthResponse{}, err
+	}
+	if err := tx.Create(&models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hash,
+		ExpiresAt: session.ExpiresAt,
+	}).Error; err != nil {
+		return api.AuthResponse{}, err
+	}
+
+	return api.AuthResponse{
+		User: api.User{
+			Id:        user.ID,
+			Username:  user.Username,
+			AvatarUrl: user.AvatarURL,
+		},
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.tokens.AccessTTL().Seconds()),
+	}, nil
+}
+
+// revokeUserSessions invalidates all of a user's sessions except keepSessionID
+func revokeUserSessions(db *gorm.DB, userID, keepSessionID string) error {
+	return db.Model(&models.AuthSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeSession invalidates a session and every token issued for it
+func revokeSession(db *gorm.DB, sessionID string) error {
+	return db.Model(&models.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
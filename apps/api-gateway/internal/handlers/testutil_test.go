@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"github.com/yourusername/cowatch/api-gateway/internal/auth"
 	"github.com/yourusername/cowatch/api-gateway/internal/models"
 )
 
@@ -23,6 +25,14 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&models.User{},
 		&models.Room{},
 		&models.RoomMember{},
+		&models.ChatMessage{},
+		&models.RoomInvite{},
+		&models.AuthSession{},
+		&models.RefreshToken{},
+		&models.WSTicket{},
+		&models.RoomGuest{},
+		&models.UserIdentity{},
+		&models.OIDCState{},
 	); err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
 	}
@@ -30,10 +40,41 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	return db
 }
 
+func newTestTokenService(t *testing.T) *auth.TokenService {
+	tokens, err := auth.NewTokenService(auth.Config{
+		Secrets:     map[string]string{"test": testJWTSecret},
+		ActiveKeyID: "test",
+		Issuer:      "cowatch-test",
+		Audience:    "cowatch",
+		AccessTTL:   15 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create token service: %v", err)
+	}
+	return tokens
+}
+
 func setupTestServer(t *testing.T) (*Server, *gin.Engine) {
 	gin.SetMode(gin.TestMode)
 	db := setupTestDB(t)
-	server := NewServer(db, testJWTSecret)
+	server := NewServer(db, newTestTokenService(t))
 	router := gin.New()
 	return server, router
 }
+
+// issueTestToken starts an auth session for user and returns a signed access token
+func issueTestToken(t *testing.T, db *gorm.DB, user *models.User) string {
+	session := models.AuthSession{
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatalf("Failed to create test session: %v", err)
+	}
+
+	token, err := newTestTokenService(t).Issue(user, session.ID)
+	if err != nil {
+		t.Fatalf("Failed to generate test token: %v", err)
+	}
+	return token
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/ratelimit"
+)
+
+// Throttles limit password guessing on login, account password checks and password-protected rooms
+type Throttles struct {
+	IP *ratelimit.Limiter // every password failure from a client IP
+
+	// Username slows guessing one account's password from many IPs. It only backs off and
+	// never locks, so failing on purpose can't lock the owner out of their account.
+	Username *ratelimit.Limiter
+	// Account locks out one client IP that keeps failing an account's password
+	Account *ratelimit.Limiter
+
+	Room *ratelimit.Limiter // room password failures per room
+}
+
+// DefaultThrottles returns the limits used unless WithThrottles overrides them
+func DefaultThrottles() *Throttles {
+	return &Throttles{
+		IP: ratelimit.New(ratelimit.Policy{
+			Window:           15 * time.Minute,
+			FreeAttempts:     10,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: 50,
+			LockoutDuration:  15 * time.Minute,
+		}),
+		Username: ratelimit.New(ratelimit.Policy{
+			Window:       15 * time.Minute,
+			FreeAttempts: 5,
+			BaseDelay:    time.Second,
+			MaxDelay:     10 * time.Second,
+		}),
+		Account: ratelimit.New(ratelimit.Policy{
+			Window:           15 * time.Minute,
+			FreeAttempts:     3,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: 10,
+			LockoutDuration:  15 * time.Minute,
+		}),
+		Room: ratelimit.New(ratelimit.Policy{
+			Window:           15 * time.Minute,
+			FreeAttempts:     5,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: 20,
+			LockoutDuration:  15 * time.Minute,
+		}),
+	}
+}
+
+// attempt is a limiter key a password check counts against
+type attempt struct {
+	limiter *ratelimit.Limiter
+	key     string
+}
+
+// passwordCheck holds the attempts reserved for one password check until its outcome is known
+type passwordCheck struct {
+	attempts []attempt
+	failed   bool
+}
+
+// reserveAttempts reserves an attempt against every key before a password is checked.
+// If any key is still waiting it responds 429 with Retry-After and returns nil; otherwise
+// the caller must call done once the check is over, and fail first if the password was wrong.
+func reserveAttempts(c *gin.Context, attempts ...attempt) *passwordCheck {
+	var wait time.Duration
+	reserved := make([]attempt, 0, len(attempts))
+	for _, a := range attempts {
+		w, ok := a.limiter.Reserve(a.key)
+		if !ok {
+			wait = max(wait, w)
+			continue
+		}
+		reserved = append(reserved, a)
+	}
+	if wait == 0 {
+		return &passwordCheck{attempts: reserved}
+	}
+
+	for _, a := range reserved {
+		a.limiter.Release(a.key, false)
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	respondError(c, http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "尝试次数过多，请稍后再试")
+	return nil
+}
+
+// fail counts the check as a failure against every key when it is done
+func (p *passwordCheck) fail() {
+	p.failed = true
+}
+
+// done releases the reserved attempts
+func (p *passwordCheck) done() {
+	for _, a := range p.attempts {
+		a.limiter.Release(a.key, p.failed)
+	}
+}
+
+// accountAttempts are the limiter keys a check of username's password counts against
+func (s *Server) accountAttempts(c *gin.Context, username string) []attempt {
+	return []attempt{
+		{s.throttles.IP, c.ClientIP()},
+		{s.throttles.Username, username},
+		{s.throttles.Account, username + "|" + c.ClientIP()},
+	}
+}
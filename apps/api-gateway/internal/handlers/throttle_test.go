@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+	"github.com/yourusername/cowatch/api-gateway/internal/ratelimit"
+)
+
+// strictThrottles blocks a key after its first failure
+func strictThrottles() *Throttles {
+	policy := ratelimit.Policy{
+		Window:           time.Minute,
+		FreeAttempts:     0,
+		BaseDelay:        30 * time.Second,
+		MaxDelay:         30 * time.Second,
+		LockoutThreshold: 5,
+		LockoutDuration:  time.Hour,
+	}
+	loose := policy
+	loose.FreeAttempts = 100
+	loose.LockoutThreshold = 0
+	return &Throttles{
+		IP:       ratelimit.New(loose),
+		Username: ratelimit.New(loose),
+		Account:  ratelimit.New(policy),
+		Room:     ratelimit.New(policy),
+	}
+}
+
+func TestLoginThrottling(t *testing.T) {
+	server, router := setupTestServer(t)
+	server.throttles = strictThrottles()
+	router.POST("/auth/login", server.PostAuthLogin)
+
+	user := models.User{Username: "throttleuser"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+
+	login := func(username, password, ip string) *httptest.ResponseRecorder {
+		body := `{"username":"` + username + `","password":"` + password + `"}`
+		req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := login("throttleuser", "wrongpassword", "10.0.0.1")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	t.Run("blocked after a failure, even with the right password", func(t *testing.T) {
+		w := login("throttleuser", "password123", "10.0.0.1")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+		retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		require.NoError(t, err)
+		assert.InDelta(t, 30, retryAfter, 1)
+	})
+
+	t.Run("failures from one IP don't lock the account elsewhere", func(t *testing.T) {
+		w := login("throttleuser", "password123", "10.0.0.2")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("other accounts are unaffected", func(t *testing.T) {
+		w := login("someoneelse", "password123", "10.0.0.1")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestLoginUsernameBackoff(t *testing.T) {
+	server, router := setupTestServer(t)
+	server.throttles = strictThrottles()
+	server.throttles.Username = ratelimit.New(ratelimit.Policy{
+		Window:    time.Minute,
+		BaseDelay: 5 * time.Second,
+		MaxDelay:  5 * time.Second,
+	})
+	router.POST("/auth/login", server.PostAuthLogin)
+
+	user := models.User{Username: "spreaduser"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+
+	login := func(password, ip string) *httptest.ResponseRecorder {
+		body := `{"username":"spreaduser","password":"` + password + `"}`
+		req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Guesses spread over IPs still slow down, but only by the backoff
+	assert.Equal(t, http.StatusUnauthorized, login("wrongpassword", "10.0.1.1").Code)
+	w := login("wrongpassword", "10.0.1.2")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+}
+
+func TestAccountPasswordThrottling(t *testing.T) {
+	server, router := setupTestServer(t)
+	server.throttles = strictThrottles()
+	auth := middleware.AuthMiddleware(server.db, server.tokens)
+	router.PUT("/users/me/password", auth, server.PutUsersMePassword)
+	router.DELETE("/users/me", auth, server.DeleteUsersMe)
+
+	user := models.User{Username: "stolentoken"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+	token := issueTestToken(t, server.db, &user)
+
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, do("PUT", "/users/me/password", `{"oldPassword":"guess1","newPassword":"newpassword456"}`))
+	assert.Equal(t, http.StatusTooManyRequests, do("PUT", "/users/me/password", `{"oldPassword":"password123","newPassword":"newpassword456"}`))
+	assert.Equal(t, http.StatusTooManyRequests, do("DELETE", "/users/me", `{"password":"password123"}`))
+}
+
+func TestLoginThrottleResetsOnSuccess(t *testing.T) {
+	server, router := setupTestServer(t)
+	router.POST("/auth/login", server.PostAuthLogin)
+
+	user := models.User{Username: "resetuser"}
+	user.SetPassword("password123")
+	server.db.Create(&user)
+
+	login := func(password string) int {
+		body := `{"username":"resetuser","password":"` + password + `"}`
+		req := httptest.NewRequest("POST", "/auth/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The default policy allows a few mistakes without delay
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login("wrongpassword"))
+	}
+	assert.Equal(t, http.StatusOK, login("password123"))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login("wrongpassword"))
+	}
+	assert.Equal(t, http.StatusOK, login("password123"))
+}
+
+func TestRoomPasswordThrottling(t *testing.T) {
+	server, router := setupTestServer(t)
+	server.throttles = strictThrottles()
+	router.POST("/rooms/:roomCode/join", middleware.AuthMiddleware(server.db, server.tokens), func(c *gin.Context) {
+		server.PostRoomsRoomCodeJoin(c, c.Param("roomCode"))
+	})
+
+	owner := models.User{Username: "throttleowner"}
+	owner.SetPassword("password123")
+	server.db.Create(&owner)
+
+	room := models.Room{Name: "Locked Room", OwnerID: owner.ID, IsActive: true}
+	room.SetPassword("roompassword")
+	server.db.Create(&room)
+	other := models.Room{Name: "Other Room", OwnerID: owner.ID, IsActive: true}
+	other.SetPassword("roompassword")
+	server.db.Create(&other)
+
+	join := func(user *models.User, code, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rooms/"+code+"/join", strings.NewReader(`{"password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+issueTestToken(t, server.db, user))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	attacker := models.User{Username: "guesser"}
+	attacker.SetPassword("password123")
+	server.db.Create(&attacker)
+	assert.Equal(t, http.StatusForbidden, join(&attacker, room.Code, "guess").Code)
+
+	w := join(&attacker, room.Code, "roompassword")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// The throttle is per room
+	assert.Equal(t, http.StatusOK, join(&attacker, other.Code, "roompassword").Code)
+}
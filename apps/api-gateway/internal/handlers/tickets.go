@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+	"github.com/yourusername/cowatch/api-gateway/internal/repository"
+)
+
+// wsTicketTTL is how long a WebSocket ticket stays valid; clients fetch one right before connecting
+const wsTicketTTL = 30 * time.Second
+
+// PostRoomsRoomCodeWsTicket issues a single-use ticket for connecting to a room's WebSocket
+// POST /rooms/{roomCode}/ws-ticket
+func (s *Server) PostRoomsRoomCodeWsTicket(c *gin.Context, roomCode string) {
+	ctx := c.Request.Context()
+	room, err := s.rooms.GetByCode(ctx, roomCode, true)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "ROOM_NOT_FOUND", "房间不存在")
+		return
+	}
+
+	record := models.WSTicket{
+		RoomID:    room.ID,
+		ExpiresAt: time.Now().Add(wsTicketTTL),
+	}
+
+	// Same rule as the WebSocket handshake: only members, or guests of this room, can connect
+	if guest, ok := middleware.GetGuest(c); ok {
+		if guest.RoomID != room.ID {
+			respondError(c, http.StatusForbidden, "NOT_MEMBER", "你不是该房间的成员")
+			return
+		}
+		record.UserID = guest.ID
+		record.IsGuest = true
+	} else {
+		user, ok := middleware.GetUser(c)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "未登录")
+			return
+		}
+
+		if _, err := s.members.Get(ctx, room.ID, user.ID); errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusForbidden, "NOT_MEMBER", "你不是该房间的成员")
+			return
+		} else if err != nil {
+			respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "生成票据失败")
+			return
+		}
+		record.UserID = user.ID
+	}
+
+	ticket, hash, err := models.NewOpaqueToken()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "生成票据失败")
+		return
+	}
+
+	record.TokenHash = hash
+	if err := s.db.Create(&record).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "生成票据失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, api.WSTicket{
+		Ticket:    ticket,
+		ExpiresAt: record.ExpiresAt,
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+func TestPostRoomsRoomCodeWsTicket(t *testing.T) {
+	server, router := setupTestServer(t)
+	router.POST("/rooms/:roomCode/ws-ticket", middleware.AuthMiddleware(server.db, server.tokens), func(c *gin.Context) {
+		server.PostRoomsRoomCodeWsTicket(c, c.Param("roomCode"))
+	})
+
+	owner := models.User{Username: "ticketowner"}
+	owner.SetPassword("password123")
+	server.db.Create(&owner)
+	ownerToken := issueTestToken(t, server.db, &owner)
+
+	stranger := models.User{Username: "ticketstranger"}
+	stranger.SetPassword("password123")
+	server.db.Create(&stranger)
+	strangerToken := issueTestToken(t, server.db, &stranger)
+
+	room := models.Room{Name: "Ticket Room", OwnerID: owner.ID, IsActive: true}
+	server.db.Create(&room)
+	server.db.Create(&models.RoomMember{RoomID: room.ID, UserID: owner.ID})
+
+	t.Run("member gets a short-lived ticket", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/rooms/"+room.Code+"/ws-ticket", nil)
+		req.Header.Set("Authorization", "Bearer "+ownerToken)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var ticket api.WSTicket
+		err := json.Unmarshal(w.Body.Bytes(), &ticket)
+		require.NoError(t, err)
+		assert.NotEmpty(t, ticket.Ticket)
+		assert.WithinDuration(t, time.Now().Add(wsTicketTTL), ticket.ExpiresAt, 5*time.Second)
+
+		var stored models.WSTicket
+		require.NoError(t, server.db.First(&stored, "token_hash = ?", models.HashOpaqueToken(ticket.Ticket)).Error)
+		assert.Equal(t, owner.ID, stored.UserID)
+		assert.Equal(t, room.ID, stored.RoomID)
+	})
+
+	t.Run("non-member is forbidden", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/rooms/"+room.Code+"/ws-ticket", nil)
+		req.Header.Set("Authorization", "Bearer "+strangerToken)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/yourusername/cowatch/api-gateway/internal/api"
 	"github.com/yourusername/cowatch/api-gateway/internal/middleware"
-	"github.com/yourusername/cowatch/api-gateway/internal/models"
 )
 
 // GetUsersMeRecentRooms returns the current user's recent rooms
@@ -24,23 +23,19 @@ func (s *Server) GetUsersMeRecentRooms(c *gin.Context, params api.GetUsersMeRece
 		limit = *params.Limit
 	}
 
-	var members []models.RoomMember
-	if err := s.db.Preload("Room").Preload("Room.Owner").
-		Where("user_id = ?", user.ID).
-		Order("last_visited_at DESC").
-		Limit(limit).
-		Find(&members).Error; err != nil {
+	members, err := s.members.Recent(c.Request.Context(), user.ID, limit)
+	if err != nil {
 		respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "获取最近房间失败")
 		return
 	}
 
 	result := make([]api.RecentRoom, 0, len(members))
-	for _, member := range members {
+	for i, member := range members {
 		if member.Room == nil {
 			continue
 		}
 		result = append(result, api.RecentRoom{
-			Room:                  roomToAPI(member.Room, user, s.db),
+			Room:                  s.roomToAPI(member.Room, user, &members[i]),
 			LastVisited:           member.LastVisitedAt,
 			LastWatchedVideoTitle: member.LastWatchedVideoTitle,
 		})
@@ -18,7 +18,7 @@ import (
 
 func TestGetUsersMeRecentRooms(t *testing.T) {
 	server, router := setupTestServer(t)
-	router.GET("/users/me/recent-rooms", middleware.AuthMiddleware(server.db, testJWTSecret), func(c *gin.Context) {
+	router.GET("/users/me/recent-rooms", middleware.AuthMiddleware(server.db, server.tokens), func(c *gin.Context) {
 		server.GetUsersMeRecentRooms(c, api.GetUsersMeRecentRoomsParams{})
 	})
 
@@ -26,7 +26,7 @@ func TestGetUsersMeRecentRooms(t *testing.T) {
 	user := models.User{Username: "recentroomuser"}
 	user.SetPassword("password123")
 	server.db.Create(&user)
-	token, _ := middleware.GenerateToken(&user, testJWTSecret)
+	token := issueTestToken(t, server.db, &user)
 
 	// Create rooms owned by another user
 	owner := models.User{Username: "recentroomowner"}
@@ -0,0 +1,131 @@
+// Package health serves liveness and readiness probes. Liveness only says the process is
+// serving HTTP; readiness also checks the gateway's dependencies and turns false once
+// shutdown starts, so load balancers stop sending new connections before the server stops.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/logging"
+)
+
+// DefaultTimeout bounds each readiness check
+const DefaultTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+	// optional checks are reported without affecting readiness
+	optional bool
+}
+
+// Checker runs readiness checks. It is safe for concurrent use once checks are added.
+type Checker struct {
+	// Timeout bounds each check; zero means DefaultTimeout
+	Timeout time.Duration
+
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// New returns a Checker with no checks
+func New() *Checker {
+	return &Checker{Timeout: DefaultTimeout}
+}
+
+// Add registers a readiness check under name. Add all checks before serving probes.
+func (h *Checker) Add(name string, check Check) {
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// AddOptional registers a check that is reported by /readyz but doesn't fail it, for
+// dependencies the gateway can serve without
+func (h *Checker) AddOptional(name string, check Check) {
+	h.checks = append(h.checks, namedCheck{name: name, check: check, optional: true})
+}
+
+// Drain marks the gateway as shutting down; readiness fails from then on
+func (h *Checker) Drain() {
+	h.draining.Store(true)
+}
+
+// Draining reports whether Drain has been called
+func (h *Checker) Draining() bool {
+	return h.draining.Load()
+}
+
+// Run runs every check concurrently and returns the failures by check name
+func (h *Checker) Run(ctx context.Context) map[string]error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures = make(map[string]error)
+	)
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.check(ctx); err != nil {
+				mu.Lock()
+				failures[c.name] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return failures
+}
+
+// Live handles GET /livez. It doesn't look at dependencies: restarting the gateway
+// wouldn't fix a database outage.
+func (h *Checker) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready handles GET /readyz with the result of every check
+func (h *Checker) Ready(c *gin.Context) {
+	if h.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+
+	failures := h.Run(c.Request.Context())
+	checks := make(map[string]string, len(h.checks))
+	ready := true
+	for _, check := range h.checks {
+		err, failed := failures[check.name]
+		if !failed {
+			checks[check.name] = "ok"
+			continue
+		}
+		checks[check.name] = err.Error()
+		ready = ready && check.optional
+		logging.FromContext(c.Request.Context()).Warn("Readiness check failed",
+			"check", check.name, "optional", check.optional, "error", err)
+	}
+
+	switch {
+	case !ready:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+	case len(failures) > 0:
+		c.JSON(http.StatusOK, gin.H{"status": "degraded", "checks": checks})
+	default:
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+	}
+}
@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type probeResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func probe(t *testing.T, checker *Checker, path string) (int, probeResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/livez", checker.Live)
+	router.GET("/readyz", checker.Ready)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var body probeResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w.Code, body
+}
+
+func TestReady(t *testing.T) {
+	dbErr := error(nil)
+	checker := New()
+	checker.Add("database", func(context.Context) error { return dbErr })
+	checker.Add("hub", func(context.Context) error { return nil })
+
+	code, body := probe(t, checker, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]string{"database": "ok", "hub": "ok"}, body.Checks)
+
+	dbErr = errors.New("connection refused")
+	code, body = probe(t, checker, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", body.Status)
+	assert.Equal(t, "connection refused", body.Checks["database"])
+	assert.Equal(t, "ok", body.Checks["hub"])
+
+	code, _ = probe(t, checker, "/livez")
+	assert.Equal(t, http.StatusOK, code, "liveness ignores dependencies")
+}
+
+func TestReadyOptionalChecks(t *testing.T) {
+	checker := New()
+	checker.Add("database", func(context.Context) error { return nil })
+	checker.AddOptional("media_service", func(context.Context) error { return errors.New("connection refused") })
+
+	code, body := probe(t, checker, "/readyz")
+	assert.Equal(t, http.StatusOK, code, "an optional dependency being down doesn't take the gateway out of rotation")
+	assert.Equal(t, "degraded", body.Status)
+	assert.Equal(t, "connection refused", body.Checks["media_service"])
+	assert.Equal(t, "ok", body.Checks["database"])
+}
+
+func TestReadyTimesOut(t *testing.T) {
+	checker := New()
+	checker.Timeout = 20 * time.Millisecond
+	checker.Add("hub", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	code, body := probe(t, checker, "/readyz")
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body.Checks["hub"], "deadline exceeded")
+}
+
+func TestDrain(t *testing.T) {
+	checker := New()
+	checker.Add("database", func(context.Context) error { return nil })
+	checker.Drain()
+
+	code, body := probe(t, checker, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining", body.Status)
+
+	code, _ = probe(t, checker, "/livez")
+	assert.Equal(t, http.StatusOK, code, "a draining gateway must not be restarted")
+}
@@ -0,0 +1,198 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+// Presence reports how many clients are currently connected to a room
+type Presence interface {
+	GetClientCount(roomID string) int
+}
+
+// RoomExpiryJob deactivates rooms with no online clients and no recent visits. Rooms
+// that predate activity tracking count as last active when they were created.
+type RoomExpiryJob struct {
+	DB          *gorm.DB
+	Presence    Presence
+	Clock       Clock
+	IdleTimeout time.Duration
+}
+
+func (j *RoomExpiryJob) Name() string { return "room-expiry" }
+
+func (j *RoomExpiryJob) Run(ctx context.Context) error {
+	cutoff := j.Clock.Now().Add(-j.IdleTimeout)
+
+	var rooms []models.Room
+	if err := j.DB.WithContext(ctx).
+		Select("id").
+		Where("is_active = ? AND COALESCE(last_active_at, created_at) < ?", true, cutoff).
+		Find(&rooms).Error; err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(rooms))
+	for _, room := range rooms {
+		if j.Presence != nil && j.Presence.GetClientCount(room.ID) > 0 {
+			continue
+		}
+		ids = append(ids, room.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := j.DB.WithContext(ctx).
+		Model(&models.Room{}).
+		Where("id IN ?", ids).
+		Update("is_active", false).Error; err != nil {
+		return err
+	}
+
+	slog.Info("Deactivated idle rooms", "count", len(ids))
+	return nil
+}
+
+// MembershipPruneJob removes memberships that have not been visited within the retention period.
+// Room owners keep their membership regardless of age.
+type MembershipPruneJob struct {
+	DB        *gorm.DB
+	Clock     Clock
+	Retention time.Duration
+}
+
+func (j *MembershipPruneJob) Name() string { return "membership-prune" }
+
+func (j *MembershipPruneJob) Run(ctx context.Context) error {
+	cutoff := j.Clock.Now().Add(-j.Retention)
+
+	result := j.DB.WithContext(ctx).
+		Where("last_visited_at < ?", cutoff).
+		Where("user_id NOT IN (?)", j.DB.Model(&models.Room{}).Select("owner_id").Where("rooms.id = room_members.room_id")).
+		Delete(&models.RoomMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		slog.Info("Pruned stale memberships", "count", result.RowsAffected)
+	}
+	return nil
+}
+
+// ChatPruneJob removes chat messages older than the retention period
+type ChatPruneJob struct {
+	DB        *gorm.DB
+	Clock     Clock
+	Retention time.Duration
+}
+
+func (j *ChatPruneJob) Name() string { return "chat-prune" }
+
+func (j *ChatPruneJob) Run(ctx context.Context) error {
+	cutoff := j.Clock.Now().Add(-j.Retention)
+
+	result := j.DB.WithContext(ctx).
+		Where("created_at < ?", cutoff).
+		Delete(&models.ChatMessage{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		slog.Info("Pruned chat messages", "count", result.RowsAffected)
+	}
+	return nil
+}
+
+// SessionPruneJob removes expired login sessions and refresh tokens
+type SessionPruneJob struct {
+	DB    *gorm.DB
+	Clock Clock
+}
+
+func (j *SessionPruneJob) Name() string { return "session-prune" }
+
+func (j *SessionPruneJob) Run(ctx context.Context) error {
+	now := j.Clock.Now()
+
+	if err := j.DB.WithContext(ctx).
+		Where("expires_at < ?", now).
+		Delete(&models.RefreshToken{}).Error; err != nil {
+		return err
+	}
+
+	result := j.DB.WithContext(ctx).
+		Where("expires_at < ?", now).
+		Delete(&models.AuthSession{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		slog.Info("Pruned expired sessions", "count", result.RowsAffected)
+	}
+	return nil
+}
+
+// TicketPruneJob removes expired WebSocket tickets
+type TicketPruneJob struct {
+	DB    *gorm.DB
+	Clock Clock
+}
+
+func (j *TicketPruneJob) Name() string { return "ws-ticket-prune" }
+
+func (j *TicketPruneJob) Run(ctx context.Context) error {
+	return j.DB.WithContext(ctx).
+		Where("expires_at < ?", j.Clock.Now()).
+		Delete(&models.WSTicket{}).Error
+}
+
+// GuestPruneJob removes expired room guests
+type GuestPruneJob struct {
+	DB    *gorm.DB
+	Clock Clock
+}
+
+func (j *GuestPruneJob) Name() string { return "guest-prune" }
+
+func (j *GuestPruneJob) Run(ctx context.Context) error {
+	return j.DB.WithContext(ctx).
+		Where("expires_at < ?", j.Clock.Now()).
+		Delete(&models.RoomGuest{}).Error
+}
+
+// InvitePruneJob removes invite links that have expired or been used up
+type InvitePruneJob struct {
+	DB    *gorm.DB
+	Clock Clock
+}
+
+func (j *InvitePruneJob) Name() string { return "invite-prune" }
+
+func (j *InvitePruneJob) Run(ctx context.Context) error {
+	return j.DB.WithContext(ctx).
+		Where("expires_at < ? OR uses >= max_uses", j.Clock.Now()).
+		Delete(&models.RoomInvite{}).Error
+}
+
+// OIDCStatePruneJob removes identity provider logins that were started but never completed
+type OIDCStatePruneJob struct {
+	DB    *gorm.DB
+	Clock Clock
+}
+
+func (j *OIDCStatePruneJob) Name() string { return "oidc-state-prune" }
+
+func (j *OIDCStatePruneJob) Run(ctx context.Context) error {
+	return j.DB.WithContext(ctx).
+		Where("expires_at < ?", j.Clock.Now()).
+		Delete(&models.OIDCState{}).Error
+}
@@ -0,0 +1,148 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+type fakePresence map[string]int
+
+func (p fakePresence) GetClientCount(roomID string) int { return p[roomID] }
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&models.User{},
+		&models.Room{},
+		&models.RoomMember{},
+		&models.ChatMessage{},
+		&models.RoomInvite{},
+	))
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB, username string) models.User {
+	user := models.User{Username: username, PasswordHash: "x"}
+	require.NoError(t, db.Create(&user).Error)
+	return user
+}
+
+func TestRoomExpiryJob(t *testing.T) {
+	db := setupTestDB(t)
+	clock := newFakeClock()
+	owner := createUser(t, db, "owner")
+
+	idle := models.Room{Name: "Idle", OwnerID: owner.ID, IsActive: true, LastActiveAt: clock.Now().Add(-48 * time.Hour)}
+	occupied := models.Room{Name: "Occupied", OwnerID: owner.ID, IsActive: true, LastActiveAt: clock.Now().Add(-48 * time.Hour)}
+	recent := models.Room{Name: "Recent", OwnerID: owner.ID, IsActive: true, LastActiveAt: clock.Now().Add(-time.Hour)}
+	untracked := models.Room{Name: "Untracked", OwnerID: owner.ID, IsActive: true, CreatedAt: clock.Now().Add(-48 * time.Hour)}
+	for _, room := range []*models.Room{&idle, &occupied, &recent, &untracked} {
+		require.NoError(t, db.Create(room).Error)
+	}
+	// Rooms created before activity tracking have no last_active_at
+	require.NoError(t, db.Model(&untracked).Update("last_active_at", nil).Error)
+
+	job := &RoomExpiryJob{
+		DB:          db,
+		Presence:    fakePresence{occupied.ID: 2},
+		Clock:       clock,
+		IdleTimeout: 24 * time.Hour,
+	}
+	require.NoError(t, job.Run(context.Background()))
+
+	isActive := func(id string) bool {
+		var room models.Room
+		require.NoError(t, db.First(&room, "id = ?", id).Error)
+		return room.IsActive
+	}
+	assert.False(t, isActive(idle.ID))
+	assert.True(t, isActive(occupied.ID))
+	assert.True(t, isActive(recent.ID))
+	assert.False(t, isActive(untracked.ID), "falls back to created_at")
+}
+
+func TestMembershipPruneJob(t *testing.T) {
+	db := setupTestDB(t)
+	clock := newFakeClock()
+	owner := createUser(t, db, "owner")
+	stale := createUser(t, db, "stale")
+	fresh := createUser(t, db, "fresh")
+
+	room := models.Room{Name: "Room", OwnerID: owner.ID, IsActive: true}
+	require.NoError(t, db.Create(&room).Error)
+
+	old := clock.Now().Add(-100 * 24 * time.Hour)
+	for _, m := range []models.RoomMember{
+		{RoomID: room.ID, UserID: owner.ID, LastVisitedAt: old},
+		{RoomID: room.ID, UserID: stale.ID, LastVisitedAt: old},
+		{RoomID: room.ID, UserID: fresh.ID, LastVisitedAt: clock.Now()},
+	} {
+		require.NoError(t, db.Create(&m).Error)
+	}
+
+	job := &MembershipPruneJob{DB: db, Clock: clock, Retention: 90 * 24 * time.Hour}
+	require.NoError(t, job.Run(context.Background()))
+
+	var userIDs []string
+	db.Model(&models.RoomMember{}).Order("user_id").Pluck("user_id", &userIDs)
+	assert.ElementsMatch(t, []string{owner.ID, fresh.ID}, userIDs)
+}
+
+func TestChatPruneJob(t *testing.T) {
+	db := setupTestDB(t)
+	clock := newFakeClock()
+	owner := createUser(t, db, "owner")
+
+	room := models.Room{Name: "Room", OwnerID: owner.ID, IsActive: true}
+	require.NoError(t, db.Create(&room).Error)
+
+	oldMessage := models.ChatMessage{RoomID: room.ID, UserID: owner.ID, Username: "owner", Content: "old", CreatedAt: clock.Now().Add(-31 * 24 * time.Hour)}
+	newMessage := models.ChatMessage{RoomID: room.ID, UserID: owner.ID, Username: "owner", Content: "new", CreatedAt: clock.Now().Add(-time.Hour)}
+	require.NoError(t, db.Create(&oldMessage).Error)
+	require.NoError(t, db.Create(&newMessage).Error)
+
+	job := &ChatPruneJob{DB: db, Clock: clock, Retention: 30 * 24 * time.Hour}
+	require.NoError(t, job.Run(context.Background()))
+
+	var contents []string
+	db.Model(&models.ChatMessage{}).Pluck("content", &contents)
+	assert.Equal(t, []string{"new"}, contents)
+}
+
+func TestInvitePruneJob(t *testing.T) {
+	db := setupTestDB(t)
+	clock := newFakeClock()
+	owner := createUser(t, db, "owner")
+
+	room := models.Room{Name: "Room", OwnerID: owner.ID, IsActive: true}
+	require.NoError(t, db.Create(&room).Error)
+
+	invites := map[string]models.RoomInvite{
+		"expired":   {MaxUses: 5, ExpiresAt: clock.Now().Add(-time.Minute)},
+		"used-up":   {MaxUses: 2, Uses: 2, ExpiresAt: clock.Now().Add(time.Hour)},
+		"usable":    {MaxUses: 2, Uses: 1, ExpiresAt: clock.Now().Add(time.Hour)},
+		"untouched": {MaxUses: 1, ExpiresAt: clock.Now().Add(time.Hour)},
+	}
+	for hash, invite := range invites {
+		invite.RoomID = room.ID
+		invite.CreatedByID = owner.ID
+		invite.TokenHash = hash
+		require.NoError(t, db.Create(&invite).Error)
+	}
+
+	job := &InvitePruneJob{DB: db, Clock: clock}
+	require.NoError(t, job.Run(context.Background()))
+
+	var hashes []string
+	db.Model(&models.RoomInvite{}).Pluck("token_hash", &hashes)
+	assert.ElementsMatch(t, []string{"usable", "untouched"}, hashes)
+}
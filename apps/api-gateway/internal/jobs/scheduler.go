@@ -0,0 +1,175 @@
+// Package jobs provides a small in-process scheduler for periodic background work
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock abstracts the current time so jobs can be driven deterministically in tests
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// RealClock returns a Clock backed by time.Now
+func RealClock() Clock {
+	return realClock{}
+}
+
+// Job is a unit of periodic background work
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// Status is a snapshot of a registered job's execution state
+type Status struct {
+	Name         string
+	Interval     time.Duration
+	Running      bool
+	RunCount     int
+	LastRunAt    *time.Time
+	LastDuration time.Duration
+	LastError    string
+	NextRunAt    time.Time
+}
+
+type entry struct {
+	job      Job
+	interval time.Duration
+	status   Status
+}
+
+// Scheduler runs registered jobs at fixed intervals
+type Scheduler struct {
+	clock   Clock
+	mu      sync.Mutex
+	entries []*entry
+}
+
+// NewScheduler creates a new Scheduler using the given clock
+func NewScheduler(clock Clock) *Scheduler {
+	if clock == nil {
+		clock = RealClock()
+	}
+	return &Scheduler{clock: clock}
+}
+
+// Register adds a job that runs every interval, starting one interval from now
+func (s *Scheduler) Register(job Job, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, &entry{
+		job:      job,
+		interval: interval,
+		status: Status{
+			Name:      job.Name(),
+			Interval:  interval,
+			NextRunAt: s.clock.Now().Add(interval),
+		},
+	})
+}
+
+// Start polls for due jobs every tick until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunDue(ctx)
+		}
+	}
+}
+
+// RunDue runs every job whose next run time has passed
+func (s *Scheduler) RunDue(ctx context.Context) {
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	var due []*entry
+	for _, e := range s.entries {
+		if !e.status.Running && !now.Before(e.status.NextRunAt) {
+			e.status.Running = true
+			due = append(due, e)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, e := range due {
+		s.run(ctx, e)
+	}
+}
+
+// RunNow runs the named job immediately regardless of its schedule
+func (s *Scheduler) RunNow(ctx context.Context, name string) error {
+	s.mu.Lock()
+	var target *entry
+	for _, e := range s.entries {
+		if e.job.Name() == name {
+			target = e
+			break
+		}
+	}
+	if target == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("job %q not found", name)
+	}
+	if target.status.Running {
+		s.mu.Unlock()
+		return fmt.Errorf("job %q is already running", name)
+	}
+	target.status.Running = true
+	s.mu.Unlock()
+
+	return s.run(ctx, target)
+}
+
+func (s *Scheduler) run(ctx context.Context, e *entry) error {
+	start := s.clock.Now()
+	err := e.job.Run(ctx)
+	finished := s.clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.status.Running = false
+	e.status.RunCount++
+	e.status.LastRunAt = &start
+	e.status.LastDuration = finished.Sub(start)
+	e.status.NextRunAt = start.Add(e.interval)
+	e.status.LastError = ""
+	if err != nil {
+		e.status.LastError = err.Error()
+		slog.Error("Job failed", "job", e.job.Name(), "error", err)
+	}
+
+	return err
+}
+
+// Statuses returns a snapshot of all registered jobs sorted by name
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Status, len(s.entries))
+	for i, e := range s.entries {
+		result[i] = e.status
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
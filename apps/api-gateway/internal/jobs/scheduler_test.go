@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced Clock for tests
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type countingJob struct {
+	name string
+	runs int
+	err  error
+}
+
+func (j *countingJob) Name() string { return j.name }
+
+func (j *countingJob) Run(ctx context.Context) error {
+	j.runs++
+	return j.err
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	clock := newFakeClock()
+	scheduler := NewScheduler(clock)
+	job := &countingJob{name: "counting"}
+	scheduler.Register(job, time.Hour)
+
+	t.Run("not due before interval", func(t *testing.T) {
+		clock.Advance(30 * time.Minute)
+		scheduler.RunDue(context.Background())
+		assert.Equal(t, 0, job.runs)
+	})
+
+	t.Run("runs once interval has passed", func(t *testing.T) {
+		clock.Advance(30 * time.Minute)
+		scheduler.RunDue(context.Background())
+		assert.Equal(t, 1, job.runs)
+
+		// Running again at the same instant does nothing
+		scheduler.RunDue(context.Background())
+		assert.Equal(t, 1, job.runs)
+	})
+
+	t.Run("status reflects last run", func(t *testing.T) {
+		statuses := scheduler.Statuses()
+		require.Len(t, statuses, 1)
+		assert.Equal(t, "counting", statuses[0].Name)
+		assert.Equal(t, 1, statuses[0].RunCount)
+		require.NotNil(t, statuses[0].LastRunAt)
+		assert.Equal(t, clock.Now(), *statuses[0].LastRunAt)
+		assert.Equal(t, clock.Now().Add(time.Hour), statuses[0].NextRunAt)
+		assert.Empty(t, statuses[0].LastError)
+	})
+}
+
+func TestSchedulerRunNow(t *testing.T) {
+	scheduler := NewScheduler(newFakeClock())
+	job := &countingJob{name: "failing", err: errors.New("boom")}
+	scheduler.Register(job, time.Hour)
+
+	err := scheduler.RunNow(context.Background(), "failing")
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, 1, job.runs)
+	assert.Equal(t, "boom", scheduler.Statuses()[0].LastError)
+
+	assert.Error(t, scheduler.RunNow(context.Background(), "missing"))
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// ParseGormLevel converts "silent", "error", "warn" or "info" to a GORM log level
+func ParseGormLevel(name string) (gormlogger.LogLevel, error) {
+	switch strings.ToLower(name) {
+	case "silent":
+		return gormlogger.Silent, nil
+	case "error":
+		return gormlogger.Error, nil
+	case "warn", "warning", "":
+		return gormlogger.Warn, nil
+	case "info":
+		return gormlogger.Info, nil
+	}
+	return 0, fmt.Errorf("logging: unknown database log level %q", name)
+}
+
+// GormLogger sends GORM's logs to slog. At Warn it reports failed and slow queries, at Info
+// every statement. Queries run WithContext carry the request ID of the request that ran them.
+type GormLogger struct {
+	Level gormlogger.LogLevel
+	// SlowThreshold marks queries taking longer as slow; zero disables slow query logging
+	SlowThreshold time.Duration
+}
+
+// NewGormLogger returns a GORM logger at level
+func NewGormLogger(level gormlogger.LogLevel, slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{Level: level, SlowThreshold: slowThreshold}
+}
+
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.Level = level
+	return &copied
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.Level >= gormlogger.Info {
+		FromContext(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.Level >= gormlogger.Warn {
+		FromContext(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.Level >= gormlogger.Error {
+		FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.Level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	query := func(level slog.Level, msg string, extra ...any) {
+		sql, rows := fc()
+		args := append([]any{"sql", sql, "rows", rows, "duration", elapsed}, extra...)
+		FromContext(ctx).Log(ctx, level, msg, args...)
+	}
+
+	switch {
+	// A missing record is an answer, not a failure; callers handle it
+	case err != nil && l.Level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		query(slog.LevelError, "Query failed", "error", err)
+	case l.SlowThreshold > 0 && elapsed > l.SlowThreshold && l.Level >= gormlogger.Warn:
+		query(slog.LevelWarn, "Slow query", "threshold", l.SlowThreshold)
+	case l.Level >= gormlogger.Info:
+		query(slog.LevelInfo, "Query")
+	}
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestGormLogger(t *testing.T) {
+	buf := captureLogs(t, "info")
+
+	type widget struct {
+		ID   uint
+		Name string
+	}
+	open := func(level gormlogger.LogLevel, slow time.Duration) *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: NewGormLogger(level, slow)})
+		require.NoError(t, err)
+		require.NoError(t, db.Session(&gorm.Session{Logger: gormlogger.Discard}).AutoMigrate(&widget{}))
+		return db
+	}
+	ctx := WithRequestID(context.Background(), "req-42")
+
+	t.Run("warn logs failures but not missing records", func(t *testing.T) {
+		buf.Reset()
+		db := open(gormlogger.Warn, time.Hour)
+		db.WithContext(ctx).Create(&widget{Name: "a"})
+		db.WithContext(ctx).First(&widget{}, "name = ?", "missing")
+		db.WithContext(ctx).Exec("SELECT * FROM no_such_table")
+
+		entries := lines(t, buf)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "Query failed", entries[0]["msg"])
+		assert.Equal(t, "ERROR", entries[0]["level"])
+		assert.Equal(t, "req-42", entries[0]["request_id"])
+		assert.Contains(t, entries[0]["sql"], "no_such_table")
+	})
+
+	t.Run("slow queries", func(t *testing.T) {
+		buf.Reset()
+		db := open(gormlogger.Warn, time.Nanosecond)
+		db.WithContext(ctx).Create(&widget{Name: "b"})
+
+		entries := lines(t, buf)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "Slow query", entries[0]["msg"])
+		assert.Equal(t, "WARN", entries[0]["level"])
+	})
+
+	t.Run("info logs every query", func(t *testing.T) {
+		buf.Reset()
+		db := open(gormlogger.Info, 0)
+		db.WithContext(ctx).Find(&[]widget{})
+
+		entries := lines(t, buf)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "Query", entries[0]["msg"])
+		assert.Contains(t, entries[0]["sql"], "widgets")
+	})
+
+	t.Run("silent", func(t *testing.T) {
+		buf.Reset()
+		db := open(gormlogger.Silent, time.Nanosecond)
+		db.Exec("SELECT * FROM no_such_table")
+		assert.Empty(t, buf.String())
+	})
+}
@@ -0,0 +1,89 @@
+// Package logging sets up structured logging with log/slog and carries a request ID
+// through contexts so every line about a request can be correlated.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options configures Setup
+type Options struct {
+	// Level is "debug", "info", "warn" or "error"
+	Level string
+	// Format is "json" or "text"
+	Format string
+}
+
+// ParseLevel converts a level name to a slog.Level
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("logging: unknown level %q", name)
+}
+
+// New builds a logger writing to w
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	switch opts.Format {
+	case "json", "":
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	}
+	return nil, fmt.Errorf("logging: unknown format %q", opts.Format)
+}
+
+// Setup makes a logger writing to w the default, which also routes the standard log package through it
+func Setup(w io.Writer, opts Options) error {
+	logger, err := New(w, opts)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID in ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns the default logger, tagged with the request ID and trace ID in ctx
+// if there are any
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		logger = logger.With("trace_id", span.TraceID().String())
+	}
+	return logger
+}
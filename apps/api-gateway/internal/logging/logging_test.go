@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// captureLogs makes a JSON logger writing to the returned buffer the default for the test
+func captureLogs(t *testing.T, level string) *bytes.Buffer {
+	t.Helper()
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var buf bytes.Buffer
+	require.NoError(t, Setup(&buf, Options{Level: level, Format: "json"}))
+	return &buf
+}
+
+// lines decodes each JSON log line
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var result []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		result = append(result, entry)
+	}
+	return result
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, Options{Level: "loud"})
+	assert.Error(t, err)
+	_, err = New(&bytes.Buffer{}, Options{Format: "xml"})
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Level: "warn", Format: "text"})
+	require.NoError(t, err)
+	logger.Info("hidden")
+	logger.Warn("shown")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "msg=shown")
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	buf := captureLogs(t, "info")
+
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), AccessLog(), Recovery())
+	router.GET("/rooms/:roomCode", func(c *gin.Context) {
+		FromContext(c.Request.Context()).Info("Handling")
+		c.Status(http.StatusOK)
+	})
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	send := func(path, requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if requestID != "" {
+			req.Header.Set(RequestIDHeader, requestID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("generated and logged", func(t *testing.T) {
+		buf.Reset()
+		w := send("/rooms/ABC", "")
+		id := w.Header().Get(RequestIDHeader)
+		require.NotEmpty(t, id)
+
+		entries := lines(t, buf)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "Handling", entries[0]["msg"])
+		assert.Equal(t, id, entries[0]["request_id"])
+		assert.Equal(t, "Request", entries[1]["msg"])
+		assert.Equal(t, id, entries[1]["request_id"])
+		assert.Equal(t, "/rooms/:roomCode", entries[1]["route"])
+		assert.EqualValues(t, http.StatusOK, entries[1]["status"])
+	})
+
+	t.Run("caller's ID is kept", func(t *testing.T) {
+		w := send("/rooms/ABC", "lb-1234.abcd")
+		assert.Equal(t, "lb-1234.abcd", w.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("unsafe ID is replaced", func(t *testing.T) {
+		w := send("/rooms/ABC", "evil\nINFO forged line")
+		assert.NotContains(t, w.Header().Get(RequestIDHeader), "evil")
+	})
+
+	t.Run("panics are logged with the request", func(t *testing.T) {
+		buf.Reset()
+		w := send("/panic", "panic-req")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		entries := lines(t, buf)
+		require.NotEmpty(t, entries)
+		assert.Equal(t, "Panic recovered", entries[0]["msg"])
+		assert.Equal(t, "panic-req", entries[0]["request_id"])
+		assert.Equal(t, "ERROR", entries[len(entries)-1]["level"], "the access log line reports the 500")
+	})
+}
+
+func TestFromContext(t *testing.T) {
+	buf := captureLogs(t, "info")
+
+	FromContext(context.Background()).Info("plain")
+	FromContext(WithRequestID(context.Background(), "req-1")).Info("tagged")
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	traced := trace.ContextWithSpanContext(context.Background(),
+		trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	FromContext(traced).Info("traced")
+
+	entries := lines(t, buf)
+	require.Len(t, entries, 3)
+	assert.NotContains(t, entries[0], "request_id")
+	assert.NotContains(t, entries[0], "trace_id")
+	assert.Equal(t, "req-1", entries[1]["request_id"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entries[2]["trace_id"])
+}
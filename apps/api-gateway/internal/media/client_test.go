@@ -0,0 +1,30 @@
+package media
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	healthy := true
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer service.Close()
+
+	client := NewClient(service.URL, time.Second)
+	assert.NoError(t, client.Ping(context.Background()))
+
+	healthy = false
+	assert.ErrorContains(t, client.Ping(context.Background()), "503")
+
+	service.Close()
+	assert.Error(t, client.Ping(context.Background()))
+}
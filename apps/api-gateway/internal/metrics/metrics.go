@@ -0,0 +1,128 @@
+// Package metrics exposes Prometheus metrics for HTTP requests, WebSocket traffic, the
+// room hub and database queries at /metrics, served on the metrics port.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cowatch"
+
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// WebSocketMessages counts messages by direction ("in" from clients, "out" to clients) and event type
+	WebSocketMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "messages_total",
+		Help:      "WebSocket messages by direction and event type.",
+	}, []string{"direction", "type"})
+
+	// WebSocketEventDuration times client events by type and outcome ("ok" or the error code sent back)
+	WebSocketEventDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "event_duration_seconds",
+		Help:      "Time to handle a client event by type and outcome.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"type", "outcome"})
+
+	// WebSocketFrameMessages is how many messages each outgoing frame carries; more than one
+	// means a batching client had a backlog
+	WebSocketFrameMessages = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "frame_messages",
+		Help:      "Messages per outgoing WebSocket frame.",
+		Buckets:   []float64{1, 2, 4, 8, 16, 32, 64},
+	})
+
+	// WebSocketEvictions counts clients dropped because their send queue was full
+	WebSocketEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "slow_consumer_evictions_total",
+		Help:      "Clients disconnected because they could not keep up with broadcasts.",
+	})
+
+	// WebSocketSendQueueDepth is each client's queued message count, observed as a message is queued
+	WebSocketSendQueueDepth = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "send_queue_depth",
+		Help:      "Messages waiting in a client's send queue when another is added.",
+		Buckets:   []float64{0, 1, 2, 4, 8, 16, 32, 64, 128, 256},
+	})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Database query latency by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+)
+
+// HubStats is a snapshot of the WebSocket hub
+type HubStats struct {
+	Connections int
+	Rooms       int
+}
+
+// WatchHub reports the hub's open connections and active rooms, read at scrape time so
+// they can't drift from the hub's own bookkeeping
+func WatchHub(stats func() HubStats) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "connections",
+		Help:      "Open WebSocket connections.",
+	}, func() float64 { return float64(stats().Connections) })
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "rooms",
+		Help:      "Rooms with at least one open connection.",
+	}, func() float64 { return float64(stats().Rooms) })
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// HTTPMiddleware records request latency by route template, so /rooms/ABC and /rooms/XYZ
+// share a series. WebSocket upgrades are skipped; their duration is the connection's lifetime.
+func HTTPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.IsWebsocket() {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HTTPRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(HTTPMiddleware())
+	router.GET("/rooms/:roomCode", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	router.GET("/metrics", gin.WrapH(Handler()))
+
+	for _, path := range []string{"/rooms/ABC", "/rooms/XYZ", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	// Both room codes share the route template's series
+	assert.Contains(t, body, `cowatch_http_request_duration_seconds_count{method="GET",route="/rooms/:roomCode",status="404"} 2`)
+	assert.Contains(t, body, `route="unmatched"`)
+	assert.NotContains(t, body, "/rooms/ABC")
+}
+
+func TestGormPlugin(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(GormPlugin{}))
+
+	type widget struct {
+		ID   uint
+		Name string
+	}
+	require.NoError(t, db.AutoMigrate(&widget{}))
+	require.NoError(t, db.Create(&widget{Name: "a"}).Error)
+	var found []widget
+	require.NoError(t, db.Where("name = ?", "a").Find(&found).Error)
+	require.NoError(t, db.Model(&widget{}).Where("id = ?", found[0].ID).Update("name", "b").Error)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, operation := range []string{"create", "query", "update"} {
+		assert.True(t, strings.Contains(body,
+			`cowatch_db_query_duration_seconds_count{operation="`+operation+`",table="widgets"} 1`), operation)
+	}
+}
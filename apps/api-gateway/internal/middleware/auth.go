@@ -6,26 +6,23 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 
 	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/auth"
 	"github.com/yourusername/cowatch/api-gateway/internal/models"
 )
 
 const (
-	UserContextKey = "user"
-	TokenExpiry    = 24 * time.Hour * 7 // 7 days
-)
+	UserContextKey    = "user"
+	SessionContextKey = "session"
+	GuestContextKey   = "guest"
 
-type Claims struct {
-	UserID   string `json:"userId"`
-	Username string `json:"username"`
-	jwt.RegisteredClaims
-}
+	RefreshTokenExpiry = 30 * 24 * time.Hour // 30 days
+)
 
 // AuthMiddleware creates a middleware that validates JWT tokens
-func AuthMiddleware(db *gorm.DB, jwtSecret string) gin.HandlerFunc {
+func AuthMiddleware(db *gorm.DB, tokens *auth.TokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -40,15 +37,21 @@ func AuthMiddleware(db *gorm.DB, jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		tokenString := parts[1]
-		claims := &Claims{}
+		claims, err := tokens.Parse(parts[1])
+		if err != nil {
+			respondError(c, http.StatusUnauthorized, "INVALID_TOKEN", "无效的认证令牌")
+			return
+		}
 
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
+		// Guest tokens only work on the few endpoints wrapped in GuestAuthMiddleware
+		if claims.Guest {
+			respondError(c, http.StatusForbidden, "GUEST_FORBIDDEN", "访客无权执行此操作")
+			return
+		}
 
-		if err != nil || !token.Valid {
-			respondError(c, http.StatusUnauthorized, "INVALID_TOKEN", "无效的认证令牌")
+		session, err := auth.LoadActiveSession(db, claims)
+		if err != nil {
+			respondError(c, http.StatusUnauthorized, "TOKEN_REVOKED", "登录已失效，请重新登录")
 			return
 		}
 
@@ -61,13 +64,14 @@ func AuthMiddleware(db *gorm.DB, jwtSecret string) gin.HandlerFunc {
 
 		// Set user in context
 		c.Set(UserContextKey, &user)
+		c.Set(SessionContextKey, session)
 		c.Next()
 	}
 }
 
 // OptionalAuthMiddleware creates a middleware that validates JWT tokens if present
 // but allows requests without authentication to pass through
-func OptionalAuthMiddleware(db *gorm.DB, jwtSecret string) gin.HandlerFunc {
+func OptionalAuthMiddleware(db *gorm.DB, tokens *auth.TokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -81,14 +85,14 @@ func OptionalAuthMiddleware(db *gorm.DB, jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		tokenString := parts[1]
-		claims := &Claims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
+		claims, err := tokens.Parse(parts[1])
+		if err != nil {
+			c.Next()
+			return
+		}
 
-		if err != nil || !token.Valid {
+		session, err := auth.LoadActiveSession(db, claims)
+		if err != nil {
 			c.Next()
 			return
 		}
@@ -96,12 +100,45 @@ func OptionalAuthMiddleware(db *gorm.DB, jwtSecret string) gin.HandlerFunc {
 		var user models.User
 		if err := db.First(&user, "id = ?", claims.UserID).Error; err == nil {
 			c.Set(UserContextKey, &user)
+			c.Set(SessionContextKey, session)
 		}
 
 		c.Next()
 	}
 }
 
+// GuestAuthMiddleware accepts a room guest token as well as a user token.
+// Guests are stored under GuestContextKey; user tokens are handled exactly like AuthMiddleware.
+func GuestAuthMiddleware(db *gorm.DB, tokens *auth.TokenService) gin.HandlerFunc {
+	userAuth := AuthMiddleware(db, tokens)
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+			if claims, err := tokens.Parse(parts[1]); err == nil && claims.Guest {
+				guest, err := auth.LoadActiveGuest(db, claims)
+				if err != nil {
+					respondError(c, http.StatusUnauthorized, "TOKEN_REVOKED", "访客身份已失效")
+					return
+				}
+				c.Set(GuestContextKey, guest)
+				c.Next()
+				return
+			}
+		}
+
+		userAuth(c)
+	}
+}
+
+// GetGuest retrieves the authenticated room guest from context
+func GetGuest(c *gin.Context) (*models.RoomGuest, bool) {
+	guest, exists := c.Get(GuestContextKey)
+	if !exists {
+		return nil, false
+	}
+	return guest.(*models.RoomGuest), true
+}
+
 // GetUser retrieves the authenticated user from context
 func GetUser(c *gin.Context) (*models.User, bool) {
 	user, exists := c.Get(UserContextKey)
@@ -111,19 +148,13 @@ func GetUser(c *gin.Context) (*models.User, bool) {
 	return user.(*models.User), true
 }
 
-// GenerateToken creates a JWT token for a user
-func GenerateToken(user *models.User, jwtSecret string) (string, error) {
-	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExpiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// GetSession retrieves the authenticated session from context
+func GetSession(c *gin.Context) (*models.AuthSession, bool) {
+	session, exists := c.Get(SessionContextKey)
+	if !exists {
+		return nil, false
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtSecret))
+	return session.(*models.AuthSession), true
 }
 
 func respondError(c *gin.Context, status int, code, message string) {
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/auth"
+)
+
+// Policy is how a route authenticates requests
+type Policy string
+
+const (
+	// PolicyPublic serves everyone and ignores tokens
+	PolicyPublic Policy = "public"
+	// PolicyOptional identifies users who send a valid token and serves everyone else anonymously
+	PolicyOptional Policy = "optional"
+	// PolicyRequired needs a signed-in user (AuthMiddleware)
+	PolicyRequired Policy = "required"
+	// PolicyUserOrGuest needs a signed-in user or a room guest (GuestAuthMiddleware)
+	PolicyUserOrGuest Policy = "user_or_guest"
+)
+
+// Security scheme names from the OpenAPI spec
+const (
+	bearerScheme = "bearerAuth"
+	guestScheme  = "guestAuth"
+)
+
+// PolicyFor maps an operation's OpenAPI security alternatives to a Policy:
+//
+//	(none)                    public
+//	{} or bearerAuth          optional
+//	bearerAuth                required
+//	bearerAuth or guestAuth   user or guest
+func PolicyFor(security [][]string) (Policy, error) {
+	var anonymous, bearer, guest bool
+	for _, alt := range security {
+		switch {
+		case len(alt) == 0:
+			anonymous = true
+		case slices.Equal(alt, []string{bearerScheme}):
+			bearer = true
+		case slices.Equal(alt, []string{guestScheme}):
+			guest = true
+		default:
+			return "", fmt.Errorf("unsupported security requirement %v", alt)
+		}
+	}
+
+	switch {
+	case len(security) == 0 || (anonymous && !bearer && !guest):
+		return PolicyPublic, nil
+	case anonymous && bearer && !guest:
+		return PolicyOptional, nil
+	case !anonymous && bearer && !guest:
+		return PolicyRequired, nil
+	case !anonymous && bearer && guest:
+		return PolicyUserOrGuest, nil
+	default:
+		return "", fmt.Errorf("unsupported combination of security requirements %v", security)
+	}
+}
+
+// RouteAuth authenticates each generated API operation as its OpenAPI security section
+// declares. basePath is the prefix the API is mounted under, e.g. "/api/v1". Routes
+// missing from the spec require a signed-in user.
+func RouteAuth(basePath string, db *gorm.DB, tokens *auth.TokenService) (gin.HandlerFunc, error) {
+	handlers := map[Policy]gin.HandlerFunc{
+		PolicyPublic:      func(c *gin.Context) { c.Next() },
+		PolicyOptional:    OptionalAuthMiddleware(db, tokens),
+		PolicyRequired:    AuthMiddleware(db, tokens),
+		PolicyUserOrGuest: GuestAuthMiddleware(db, tokens),
+	}
+
+	routes := make(map[string]gin.HandlerFunc, len(api.OperationSecurity))
+	for route, security := range api.OperationSecurity {
+		policy, err := PolicyFor(security)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", route, err)
+		}
+		routes[route] = handlers[policy]
+	}
+
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + strings.TrimPrefix(c.FullPath(), basePath)
+		handler, ok := routes[route]
+		if !ok {
+			handler = handlers[PolicyRequired]
+		}
+		handler(c)
+	}, nil
+}
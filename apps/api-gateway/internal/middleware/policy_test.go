@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/auth"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+func TestPolicyFor(t *testing.T) {
+	tests := []struct {
+		security [][]string
+		want     Policy
+	}{
+		{nil, PolicyPublic},
+		{[][]string{{}}, PolicyPublic},
+		{[][]string{{}, {"bearerAuth"}}, PolicyOptional},
+		{[][]string{{"bearerAuth"}}, PolicyRequired},
+		{[][]string{{"bearerAuth"}, {"guestAuth"}}, PolicyUserOrGuest},
+	}
+	for _, tt := range tests {
+		got, err := PolicyFor(tt.security)
+		require.NoError(t, err, "%v", tt.security)
+		assert.Equal(t, tt.want, got, "%v", tt.security)
+	}
+
+	for _, security := range [][][]string{
+		{{"apiKey"}},
+		{{"bearerAuth", "guestAuth"}},
+		{{"guestAuth"}},
+		{{}, {"bearerAuth"}, {"guestAuth"}},
+	} {
+		_, err := PolicyFor(security)
+		assert.Error(t, err, "%v", security)
+	}
+}
+
+// Every generated route must have a policy from the spec, or it would silently fall
+// back to requiring a user
+func TestEveryOperationHasPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api.RegisterHandlers(router, nil)
+
+	routes := router.Routes()
+	require.NotEmpty(t, routes)
+	assert.Len(t, api.OperationSecurity, len(routes), "api.OperationSecurity is out of date; run make generate-api")
+	for _, route := range routes {
+		security, ok := api.OperationSecurity[route.Method+" "+route.Path]
+		if !assert.True(t, ok, "no security for %s %s", route.Method, route.Path) {
+			continue
+		}
+		_, err := PolicyFor(security)
+		assert.NoError(t, err, "%s %s", route.Method, route.Path)
+	}
+}
+
+func TestRouteAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Room{}, &models.AuthSession{}, &models.RoomGuest{}))
+
+	tokens, err := auth.NewTokenService(auth.Config{
+		Secrets:     map[string]string{"test": "policy-test-secret"},
+		ActiveKeyID: "test",
+		Issuer:      "cowatch-test",
+		Audience:    "cowatch",
+		AccessTTL:   time.Minute,
+	})
+	require.NoError(t, err)
+
+	user := models.User{Username: "policyuser", PasswordHash: "x"}
+	require.NoError(t, db.Create(&user).Error)
+	session := models.AuthSession{UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, db.Create(&session).Error)
+	userToken, err := tokens.Issue(&user, session.ID)
+	require.NoError(t, err)
+
+	room := models.Room{Name: "Policy Room", OwnerID: user.ID, IsActive: true, AllowGuests: true}
+	require.NoError(t, db.Create(&room).Error)
+	guest := models.RoomGuest{RoomID: room.ID, Nickname: "visitor", ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, db.Create(&guest).Error)
+	guestToken, err := tokens.IssueGuest(&guest)
+	require.NoError(t, err)
+
+	routeAuth, err := RouteAuth("/api/v1", db, tokens)
+	require.NoError(t, err)
+
+	// Handlers report who the policy let through
+	whoami := func(c *gin.Context) {
+		if user, ok := GetUser(c); ok {
+			c.String(http.StatusOK, "user:"+user.Username)
+			return
+		}
+		if guest, ok := GetGuest(c); ok {
+			c.String(http.StatusOK, "guest:"+guest.Nickname)
+			return
+		}
+		c.String(http.StatusOK, "anonymous")
+	}
+	router := gin.New()
+	group := router.Group("/api/v1")
+	group.GET("/auth/oidc/providers", routeAuth, whoami)
+	group.GET("/rooms/:roomCode", routeAuth, whoami)
+	group.PATCH("/rooms/:roomCode", routeAuth, whoami)
+	group.POST("/rooms/:roomCode/ws-ticket", routeAuth, whoami)
+	group.GET("/not-in-spec", routeAuth, whoami)
+
+	send := func(method, path, token string) (int, string) {
+		req := httptest.NewRequest(method, "/api/v1"+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	tests := []struct {
+		name         string
+		method, path string
+		token        string
+		wantCode     int
+		wantBody     string
+	}{
+		{"public ignores tokens", http.MethodGet, "/auth/oidc/providers", userToken, http.StatusOK, "anonymous"},
+		{"optional without token", http.MethodGet, "/rooms/ABCD1234", "", http.StatusOK, "anonymous"},
+		{"optional with token", http.MethodGet, "/rooms/ABCD1234", userToken, http.StatusOK, "user:policyuser"},
+		{"optional with bad token", http.MethodGet, "/rooms/ABCD1234", "garbage", http.StatusOK, "anonymous"},
+		{"required without token", http.MethodPatch, "/rooms/ABCD1234", "", http.StatusUnauthorized, ""},
+		{"required with token", http.MethodPatch, "/rooms/ABCD1234", userToken, http.StatusOK, "user:policyuser"},
+		{"required refuses guests", http.MethodPatch, "/rooms/ABCD1234", guestToken, http.StatusForbidden, ""},
+		{"guest route with guest", http.MethodPost, "/rooms/ABCD1234/ws-ticket", guestToken, http.StatusOK, "guest:visitor"},
+		{"guest route with user", http.MethodPost, "/rooms/ABCD1234/ws-ticket", userToken, http.StatusOK, "user:policyuser"},
+		{"guest route without token", http.MethodPost, "/rooms/ABCD1234/ws-ticket", "", http.StatusUnauthorized, ""},
+		{"unknown route needs a user", http.MethodGet, "/not-in-spec", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := send(tt.method, tt.path, tt.token)
+			assert.Equal(t, tt.wantCode, code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, body)
+			}
+		})
+	}
+}
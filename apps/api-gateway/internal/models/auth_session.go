@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuthSession is a login session; access tokens carry its ID and stop working once it is revoked
+type AuthSession struct {
+	ID        string     `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    string     `gorm:"type:uuid;not null;index" json:"userId"`
+	User      *User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"user,omitempty"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (s *AuthSession) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName sets the table name for auth sessions
+func (AuthSession) TableName() string {
+	return "auth_sessions"
+}
+
+// IsActive reports whether the session is neither revoked nor expired at time now
+func (s *AuthSession) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RefreshToken is a single-use token that can be exchanged for a new access token.
+// Each exchange marks the token used and issues a successor in the same session.
+type RefreshToken struct {
+	ID        string       `gorm:"type:uuid;primaryKey" json:"id"`
+	SessionID string       `gorm:"type:uuid;not null;index" json:"sessionId"`
+	Session   *AuthSession `gorm:"foreignKey:SessionID;constraint:OnDelete:CASCADE" json:"session,omitempty"`
+	TokenHash string       `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time    `gorm:"not null;index" json:"expiresAt"`
+	UsedAt    *time.Time   `json:"usedAt,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+func (rt *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+	if rt.ID == "" {
+		rt.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName sets the table name for refresh tokens
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
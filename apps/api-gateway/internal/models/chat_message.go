@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ChatMessage is a persisted chat message sent in a room
+type ChatMessage struct {
+	ID        string    `gorm:"type:uuid;primaryKey" json:"id"`
+	RoomID    string    `gorm:"type:uuid;not null;index:idx_chat_room_created" json:"roomId"`
+	Room      *Room     `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE" json:"room,omitempty"`
+	UserID    string    `gorm:"size:64;index" json:"userId"`
+	Username  string    `gorm:"size:20;not null" json:"username"`
+	Content   string    `gorm:"size:1000;not null" json:"content"`
+	CreatedAt time.Time `gorm:"index:idx_chat_room_created" json:"createdAt"`
+}
+
+func (m *ChatMessage) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName sets the table name for chat messages
+func (ChatMessage) TableName() string {
+	return "chat_messages"
+}
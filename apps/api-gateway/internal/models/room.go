@@ -12,6 +12,13 @@ import (
 
 const codeChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
+// Room visibility levels
+const (
+	RoomVisibilityPublic   = "public"   // Listed in GET /rooms
+	RoomVisibilityUnlisted = "unlisted" // Reachable by code but not listed
+	RoomVisibilityPrivate  = "private"  // Only visible to the owner and members
+)
+
 type Room struct {
 	ID           string    `gorm:"type:uuid;primaryKey" json:"id"`
 	Code         string    `gorm:"size:8;uniqueIndex;not null" json:"code"`
@@ -21,6 +28,9 @@ type Room struct {
 	PasswordHash *string   `gorm:"size:255" json:"-"`
 	MaxUsers     int       `gorm:"default:20" json:"maxUsers"`
 	IsActive     bool      `gorm:"default:true;index" json:"isActive"`
+	Visibility   string    `gorm:"size:10;default:public;index" json:"visibility"`
+	AllowGuests  bool      `gorm:"default:false" json:"allowGuests"`
+	LastActiveAt time.Time `gorm:"index" json:"lastActiveAt"`
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
 }
@@ -35,6 +45,12 @@ func (r *Room) BeforeCreate(tx *gorm.DB) error {
 	if r.MaxUsers == 0 {
 		r.MaxUsers = 20
 	}
+	if r.Visibility == "" {
+		r.Visibility = RoomVisibilityPublic
+	}
+	if r.LastActiveAt.IsZero() {
+		r.LastActiveAt = time.Now()
+	}
 	return nil
 }
 
@@ -76,3 +92,12 @@ func (r *Room) CheckPassword(password string) bool {
 func (r *Room) HasPassword() bool {
 	return r.PasswordHash != nil
 }
+
+// IsValidVisibility reports whether v is a known room visibility level
+func IsValidVisibility(v string) bool {
+	switch v {
+	case RoomVisibilityPublic, RoomVisibilityUnlisted, RoomVisibilityPrivate:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RoomGuest is an anonymous participant admitted to a single room without an account.
+// Guests are never room members: they can't get control permission and don't show up in recent rooms.
+type RoomGuest struct {
+	ID        string    `gorm:"type:uuid;primaryKey" json:"id"`
+	RoomID    string    `gorm:"type:uuid;not null;index" json:"roomId"`
+	Room      *Room     `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE" json:"room,omitempty"`
+	Nickname  string    `gorm:"size:20;not null" json:"nickname"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (g *RoomGuest) BeforeCreate(tx *gorm.DB) error {
+	if g.ID == "" {
+		g.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName sets the table name for room guests
+func (RoomGuest) TableName() string {
+	return "room_guests"
+}
+
+// IsExpired reports whether the guest's access has run out at now
+func (g *RoomGuest) IsExpired(now time.Time) bool {
+	return !now.Before(g.ExpiresAt)
+}
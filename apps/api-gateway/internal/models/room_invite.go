@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RoomInvite is a shareable link that lets users join a room without its password
+type RoomInvite struct {
+	ID           string    `gorm:"type:uuid;primaryKey" json:"id"`
+	RoomID       string    `gorm:"type:uuid;not null;index" json:"roomId"`
+	Room         *Room     `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE" json:"room,omitempty"`
+	CreatedByID  string    `gorm:"type:uuid;not null" json:"createdById"`
+	TokenHash    string    `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	MaxUses      int       `gorm:"not null;default:1" json:"maxUses"`
+	Uses         int       `gorm:"not null;default:0" json:"uses"`
+	GrantControl bool      `gorm:"default:false" json:"grantControl"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expiresAt"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (ri *RoomInvite) BeforeCreate(tx *gorm.DB) error {
+	if ri.ID == "" {
+		ri.ID = uuid.New().String()
+	}
+	if ri.MaxUses == 0 {
+		ri.MaxUses = 1
+	}
+	return nil
+}
+
+// TableName sets the table name for room invites
+func (RoomInvite) TableName() string {
+	return "room_invites"
+}
+
+// IsExpired reports whether the invite can no longer be used at time now
+func (ri *RoomInvite) IsExpired(now time.Time) bool {
+	return !now.Before(ri.ExpiresAt)
+}
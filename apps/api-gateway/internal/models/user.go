@@ -8,11 +8,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// DeletedUsername replaces the author name on chat messages of deleted accounts
+const DeletedUsername = "已注销用户"
+
 type User struct {
 	ID           string    `gorm:"type:uuid;primaryKey" json:"id"`
 	Username     string    `gorm:"size:20;uniqueIndex;not null" json:"username"`
 	PasswordHash string    `gorm:"size:255;not null" json:"-"`
 	AvatarURL    *string   `gorm:"size:500" json:"avatarUrl,omitempty"`
+	AvatarKey    *string   `gorm:"size:255" json:"-"` // storage key of an uploaded avatar, nil for the generated default
+	IsAdmin      bool      `gorm:"default:false" json:"isAdmin"`
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
 }
@@ -39,6 +44,12 @@ func (u *User) SetPassword(password string) error {
 	return nil
 }
 
+// HasPassword reports whether the user can sign in with a password.
+// Accounts created through an identity provider start without one.
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
 // CheckPassword verifies the provided password against the stored hash
 func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect provider
+type UserIdentity struct {
+	ID        string    `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    string    `gorm:"type:uuid;not null;uniqueIndex:idx_identity_user_provider" json:"userId"`
+	User      *User     `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"user,omitempty"`
+	Provider  string    `gorm:"size:50;not null;uniqueIndex:idx_identity_subject;uniqueIndex:idx_identity_user_provider" json:"provider"`
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_identity_subject" json:"subject"` // the provider's stable user ID ("sub")
+	Email     *string   `gorm:"size:255" json:"email,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (i *UserIdentity) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName sets the table name for user identities
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// OIDCState tracks an authorization request between the redirect to the provider and the callback.
+// LinkUserID is set when a signed-in user is attaching the provider to their account.
+type OIDCState struct {
+	ID           string    `gorm:"type:uuid;primaryKey" json:"id"`
+	StateHash    string    `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Provider     string    `gorm:"size:50;not null" json:"provider"`
+	Nonce        string    `gorm:"size:64;not null" json:"-"`
+	CodeVerifier string    `gorm:"size:128;not null" json:"-"`
+	LinkUserID   *string   `gorm:"type:uuid" json:"linkUserId,omitempty"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expiresAt"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (s *OIDCState) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName sets the table name for pending OIDC logins
+func (OIDCState) TableName() string {
+	return "oidc_states"
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WSTicket is a short-lived, single-use credential for opening a room WebSocket.
+// It keeps long-lived tokens out of URLs, which end up in proxy and access logs.
+type WSTicket struct {
+	ID        string     `gorm:"type:uuid;primaryKey" json:"id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	UserID    string     `gorm:"type:uuid;not null" json:"userId"` // RoomGuest ID when IsGuest is set
+	IsGuest   bool       `gorm:"default:false" json:"isGuest"`
+	RoomID    string     `gorm:"type:uuid;not null" json:"roomId"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (t *WSTicket) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName sets the table name for WebSocket tickets
+func (WSTicket) TableName() string {
+	return "ws_tickets"
+}
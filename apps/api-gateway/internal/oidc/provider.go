@@ -0,0 +1,174 @@
+// Package oidc implements the OpenID Connect authorization-code flow (with PKCE) against
+// configurable identity providers.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUnknownProvider = errors.New("oidc: unknown provider")
+	ErrNonceMismatch   = errors.New("oidc: nonce mismatch")
+	ErrMissingIDToken  = errors.New("oidc: token response has no id_token")
+)
+
+// ProviderConfig describes a registered identity provider
+type ProviderConfig struct {
+	Name         string // URL-safe identifier, e.g. "google"
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string   // the frontend callback page registered with the provider
+	Scopes       []string // defaults to openid, profile and email
+}
+
+// Identity is what the provider asserted about the user in a verified ID token
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// Provider is a discovered identity provider ready to start and complete logins
+type Provider struct {
+	Config   ProviderConfig
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// AuthCodeURL returns the provider URL the user is sent to. The nonce is echoed in the
+// ID token and the verifier's S256 challenge binds the code to this login attempt.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange redeems an authorization code and returns the verified identity
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, ErrMissingIDToken
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc: decode claims: %w", err)
+	}
+
+	return &Identity{
+		Subject:           idToken.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// Registry holds the configured providers. Discovery runs on first use, so an
+// unreachable provider doesn't stop the server from starting.
+type Registry struct {
+	configs map[string]ProviderConfig
+
+	mu        sync.Mutex
+	providers map[string]*Provider
+}
+
+// NewRegistry creates a registry for the given providers
+func NewRegistry(configs []ProviderConfig) *Registry {
+	r := &Registry{
+		configs:   make(map[string]ProviderConfig, len(configs)),
+		providers: make(map[string]*Provider),
+	}
+	for _, cfg := range configs {
+		r.configs[cfg.Name] = cfg
+	}
+	return r
+}
+
+// List returns the configured providers sorted by name
+func (r *Registry) List() []ProviderConfig {
+	list := make([]ProviderConfig, 0, len(r.configs))
+	for _, cfg := range r.configs {
+		list = append(list, cfg)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Get returns the named provider, running discovery against its issuer if needed
+func (r *Registry) Get(ctx context.Context, name string) (*Provider, error) {
+	cfg, ok := r.configs[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	r.mu.Lock()
+	p, ok := r.providers[name]
+	r.mu.Unlock()
+	if ok {
+		return p, nil
+	}
+
+	// Discovery is a network call, so it runs without the lock: a slow provider mustn't
+	// hold up requests for the others. Concurrent first uses may both discover; the first
+	// result is kept.
+	discovered, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discover %s: %w", cfg.Issuer, err)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+
+	p = &Provider{
+		Config: cfg,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     discovered.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: discovered.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.providers[name]; ok {
+		return existing, nil
+	}
+	r.providers[name] = p
+	return p, nil
+}
+
+// NewVerifier returns a random PKCE code verifier for AuthCodeURL and Exchange
+func NewVerifier() string {
+	return oauth2.GenerateVerifier()
+}
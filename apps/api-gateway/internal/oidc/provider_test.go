@@ -0,0 +1,114 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/oidc"
+	"github.com/yourusername/cowatch/api-gateway/internal/oidc/oidctest"
+)
+
+func newTestProvider(t *testing.T) (*oidc.Provider, *oidctest.Server) {
+	idp := oidctest.NewServer(t, "cowatch", "secret")
+	registry := oidc.NewRegistry([]oidc.ProviderConfig{{
+		Name:         "corp",
+		Issuer:       idp.URL,
+		ClientID:     "cowatch",
+		ClientSecret: "secret",
+		RedirectURL:  "http://app.example.com/auth/callback/corp",
+	}})
+
+	provider, err := registry.Get(context.Background(), "corp")
+	require.NoError(t, err)
+	return provider, idp
+}
+
+func TestProviderExchange(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	idp.SetUser(oidctest.User{Subject: "sub-1", Email: "alice@example.com", PreferredUsername: "alice"})
+
+	verifier := oauth2.GenerateVerifier()
+	authURL := provider.AuthCodeURL("state-1", "nonce-1", verifier)
+
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+
+	code, state := idp.Authorize(t, authURL)
+	assert.Equal(t, "state-1", state)
+
+	identity, err := provider.Exchange(context.Background(), code, verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "sub-1", identity.Subject)
+	assert.Equal(t, "alice@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, "alice", identity.PreferredUsername)
+}
+
+func TestProviderExchangeRejects(t *testing.T) {
+	provider, idp := newTestProvider(t)
+	idp.SetUser(oidctest.User{Subject: "sub-1"})
+
+	t.Run("wrong nonce", func(t *testing.T) {
+		verifier := oauth2.GenerateVerifier()
+		code, _ := idp.Authorize(t, provider.AuthCodeURL("state", "nonce-1", verifier))
+
+		_, err := provider.Exchange(context.Background(), code, verifier, "other-nonce")
+		assert.ErrorIs(t, err, oidc.ErrNonceMismatch)
+	})
+
+	t.Run("wrong PKCE verifier", func(t *testing.T) {
+		code, _ := idp.Authorize(t, provider.AuthCodeURL("state", "nonce", oauth2.GenerateVerifier()))
+
+		_, err := provider.Exchange(context.Background(), code, oauth2.GenerateVerifier(), "nonce")
+		assert.Error(t, err)
+	})
+
+	t.Run("code reuse", func(t *testing.T) {
+		verifier := oauth2.GenerateVerifier()
+		code, _ := idp.Authorize(t, provider.AuthCodeURL("state", "nonce", verifier))
+
+		_, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+		require.NoError(t, err)
+		_, err = provider.Exchange(context.Background(), code, verifier, "nonce")
+		assert.Error(t, err)
+	})
+}
+
+func TestRegistryUnknownProvider(t *testing.T) {
+	registry := oidc.NewRegistry(nil)
+	_, err := registry.Get(context.Background(), "nope")
+	assert.ErrorIs(t, err, oidc.ErrUnknownProvider)
+}
+
+func TestRegistrySlowDiscoveryDoesNotBlockOthers(t *testing.T) {
+	idp := oidctest.NewServer(t, "cowatch", "secret")
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+
+	registry := oidc.NewRegistry([]oidc.ProviderConfig{
+		{Name: "slow", Issuer: slow.URL, ClientID: "cowatch"},
+		{Name: "corp", Issuer: idp.URL, ClientID: "cowatch"},
+	})
+
+	go registry.Get(context.Background(), "slow")
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := registry.Get(ctx, "corp")
+	assert.NoError(t, err)
+}
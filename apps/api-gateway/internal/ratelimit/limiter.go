@@ -0,0 +1,185 @@
+// Package ratelimit slows down password guessing by tracking failed attempts per key
+// (client IP, username, room) in a sliding window.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy controls how failures for a key are penalized
+type Policy struct {
+	// Window is how long a failure counts against a key
+	Window time.Duration
+
+	// FreeAttempts failures are allowed within Window before any delay is imposed
+	FreeAttempts int
+
+	// Each failure beyond FreeAttempts doubles the wait before the next attempt,
+	// starting at BaseDelay and capped at MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// LockoutThreshold failures within Window block the key for LockoutDuration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+}
+
+type state struct {
+	failures    []time.Time
+	nextAllowed time.Time
+	pending     int // attempts reserved but not yet released
+}
+
+// Limiter tracks failed attempts in memory. It is safe for concurrent use.
+type Limiter struct {
+	policy Policy
+	now    func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*state
+	lastSweep time.Time
+}
+
+// New creates a Limiter enforcing policy
+func New(policy Policy) *Limiter {
+	return &Limiter{
+		policy: policy,
+		now:    time.Now,
+		keys:   make(map[string]*state),
+	}
+}
+
+// Allow reports whether key may attempt now, and if not, how long until it may
+func (l *Limiter) Allow(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	st, ok := l.keys[key]
+	if !ok || !now.Before(st.nextAllowed) {
+		return 0, true
+	}
+	return st.nextAllowed.Sub(now), false
+}
+
+// Reserve is Allow for a check whose outcome isn't known yet: if key may attempt now, one
+// of its attempts is held until Release. Once the free attempts are used up only one reserved
+// attempt may be in flight, so concurrent requests can't all get past a check before any
+// of them has failed.
+func (l *Limiter) Reserve(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	st, ok := l.keys[key]
+	if !ok {
+		st = &state{}
+		l.keys[key] = st
+	}
+	if now.Before(st.nextAllowed) {
+		return st.nextAllowed.Sub(now), false
+	}
+	st.failures = prune(st.failures, now.Add(-l.policy.Window))
+	if st.pending > 0 && len(st.failures)+st.pending >= l.policy.FreeAttempts {
+		return l.backoff(1), false
+	}
+	st.pending++
+	return 0, true
+}
+
+// Release ends an attempt held by Reserve, recording it as a failure if failed, and
+// returns how long the key must now wait
+func (l *Limiter) Release(key string, failed bool) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if st, ok := l.keys[key]; ok && st.pending > 0 {
+		st.pending--
+	}
+	if !failed {
+		return 0
+	}
+	return l.failure(key)
+}
+
+// Failure records a failed attempt for key and returns how long the key must now wait
+func (l *Limiter) Failure(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.failure(key)
+}
+
+// failure implements Failure; callers hold mu
+func (l *Limiter) failure(key string) time.Duration {
+	now := l.now()
+	st, ok := l.keys[key]
+	if !ok {
+		st = &state{}
+		l.keys[key] = st
+	}
+	st.failures = append(prune(st.failures, now.Add(-l.policy.Window)), now)
+
+	n := len(st.failures)
+	switch {
+	case l.policy.LockoutThreshold > 0 && n >= l.policy.LockoutThreshold:
+		// The lockout is the penalty for these failures; start counting afresh once it ends
+		st.nextAllowed = now.Add(l.policy.LockoutDuration)
+		st.failures = st.failures[:0]
+	case n > l.policy.FreeAttempts:
+		st.nextAllowed = now.Add(l.backoff(n - l.policy.FreeAttempts))
+	}
+
+	if now.Before(st.nextAllowed) {
+		return st.nextAllowed.Sub(now)
+	}
+	return 0
+}
+
+// Reset forgets all failures for key, e.g. after a successful login
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.keys, key)
+}
+
+// backoff returns the delay after the nth penalized failure
+func (l *Limiter) backoff(n int) time.Duration {
+	delay := l.policy.BaseDelay
+	for i := 1; i < n && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.policy.MaxDelay {
+		delay = l.policy.MaxDelay
+	}
+	return delay
+}
+
+// sweep drops keys with no recent failures, reservations or pending wait, at most once per Window
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.policy.Window {
+		return
+	}
+	l.lastSweep = now
+
+	cutoff := now.Add(-l.policy.Window)
+	for key, st := range l.keys {
+		st.failures = prune(st.failures, cutoff)
+		if len(st.failures) == 0 && st.pending == 0 && !now.Before(st.nextAllowed) {
+			delete(l.keys, key)
+		}
+	}
+}
+
+// prune removes failures at or before cutoff; failures are kept in time order
+func prune(failures []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(failures) && !failures[i].After(cutoff) {
+		i++
+	}
+	return failures[i:]
+}
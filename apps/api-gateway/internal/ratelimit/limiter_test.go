@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter() (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := New(Policy{
+		Window:           10 * time.Minute,
+		FreeAttempts:     2,
+		BaseDelay:        time.Second,
+		MaxDelay:         8 * time.Second,
+		LockoutThreshold: 8,
+		LockoutDuration:  15 * time.Minute,
+	})
+	l.now = clock.Now
+	return l, clock
+}
+
+func TestLimiterBackoff(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	// Free attempts impose no delay
+	assert.Zero(t, l.Failure("alice"))
+	assert.Zero(t, l.Failure("alice"))
+	_, ok := l.Allow("alice")
+	assert.True(t, ok)
+
+	// Then each failure doubles the wait, up to MaxDelay
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		assert.Equal(t, want, l.Failure("alice"))
+
+		wait, ok := l.Allow("alice")
+		assert.False(t, ok)
+		assert.Equal(t, want, wait)
+
+		clock.Advance(want)
+		_, ok = l.Allow("alice")
+		assert.True(t, ok)
+	}
+
+	// Other keys are unaffected
+	_, ok = l.Allow("bob")
+	assert.True(t, ok)
+}
+
+func TestLimiterReserve(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	// Concurrent checks may use the free attempts, but no more
+	_, ok := l.Reserve("alice")
+	require.True(t, ok)
+	_, ok = l.Reserve("alice")
+	require.True(t, ok)
+	wait, ok := l.Reserve("alice")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	assert.Zero(t, l.Release("alice", true))
+	assert.Zero(t, l.Release("alice", true))
+
+	// Past the free attempts, one check at a time
+	_, ok = l.Reserve("alice")
+	require.True(t, ok)
+	_, ok = l.Reserve("alice")
+	assert.False(t, ok)
+
+	// A successful check doesn't count
+	assert.Zero(t, l.Release("alice", false))
+	_, ok = l.Reserve("alice")
+	require.True(t, ok)
+	assert.Equal(t, time.Second, l.Release("alice", true))
+	_, ok = l.Reserve("alice")
+	assert.False(t, ok)
+
+	// Keys with checks in flight survive a sweep
+	_, ok = l.Reserve("bob")
+	require.True(t, ok)
+	clock.Advance(11 * time.Minute)
+	l.Allow("carol")
+	assert.Contains(t, l.keys, "bob")
+}
+
+func TestLimiterLockout(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	for i := 0; i < 7; i++ {
+		l.Failure("alice")
+		clock.Advance(10 * time.Second)
+	}
+	assert.Equal(t, 15*time.Minute, l.Failure("alice"))
+
+	clock.Advance(14 * time.Minute)
+	wait, ok := l.Allow("alice")
+	assert.False(t, ok)
+	assert.Equal(t, time.Minute, wait)
+
+	clock.Advance(time.Minute)
+	_, ok = l.Allow("alice")
+	assert.True(t, ok)
+
+	// Counting starts afresh after a lockout
+	assert.Zero(t, l.Failure("alice"))
+}
+
+func TestLimiterSlidingWindow(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	l.Failure("alice")
+	l.Failure("alice")
+	clock.Advance(11 * time.Minute)
+
+	// The earlier failures have left the window, so this one is free again
+	assert.Zero(t, l.Failure("alice"))
+}
+
+func TestLimiterReset(t *testing.T) {
+	l, _ := newTestLimiter()
+
+	for i := 0; i < 4; i++ {
+		l.Failure("alice")
+	}
+	_, ok := l.Allow("alice")
+	assert.False(t, ok)
+
+	l.Reset("alice")
+	_, ok = l.Allow("alice")
+	assert.True(t, ok)
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l, clock := newTestLimiter()
+
+	l.Failure("alice")
+	clock.Advance(11 * time.Minute)
+	l.Allow("bob")
+
+	assert.Empty(t, l.keys)
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+// NewGorm returns repositories backed by db
+func NewGorm(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:   &gormUsers{db: db},
+		Rooms:   &gormRooms{db: db},
+		Members: &gormMembers{db: db},
+	}
+}
+
+// notFound maps GORM's missing-record error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// duplicate maps the driver's unique-violation error to ErrDuplicate
+func duplicate(db *gorm.DB, err error) error {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok && errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r *gormUsers) GetByID(ctx context.Context, id string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "username = ?", username).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) UsernameTaken(ctx context.Context, username, exceptUserID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("username = ? AND id <> ?", username, exceptUserID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormUsers) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *gormUsers) SetUsername(ctx context.Context, user *models.User, username string) error {
+	if err := r.db.WithContext(ctx).Model(user).Update("username", username).Error; err != nil {
+		return duplicate(r.db, err)
+	}
+	return nil
+}
+
+type gormRooms struct {
+	db *gorm.DB
+}
+
+func (r *gormRooms) GetByCode(ctx context.Context, code string, activeOnly bool) (*models.Room, error) {
+	query := r.db.WithContext(ctx).Preload("Owner").Where("code = ?", code)
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var room models.Room
+	if err := query.First(&room).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &room, nil
+}
+
+func (r *gormRooms) List(ctx context.Context, filter RoomFilter) ([]models.Room, error) {
+	query := r.db.WithContext(ctx).Preload("Owner").
+		Where("is_active = ? AND visibility = ?", true, models.RoomVisibilityPublic)
+
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		pattern := "%" + escapeLike(strings.ToLower(q)) + "%"
+		query = query.Where("LOWER(name) LIKE ? ESCAPE '\\'", pattern)
+	}
+	if filter.HasPassword != nil {
+		if *filter.HasPassword {
+			query = query.Where("password_hash IS NOT NULL")
+		} else {
+			query = query.Where("password_hash IS NULL")
+		}
+	}
+
+	if filter.IDs != nil {
+		if len(filter.IDs) == 0 {
+			return []models.Room{}, nil
+		}
+		query = query.Where("id IN ?", filter.IDs)
+	}
+	if len(filter.ExcludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", filter.ExcludeIDs)
+	}
+
+	switch filter.Sort {
+	case SortActivity:
+		query = query.Order("last_active_at DESC")
+	default:
+		query = query.Order("created_at DESC")
+	}
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit).Offset(filter.Offset)
+	}
+
+	var rooms []models.Room
+	if err := query.Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (r *gormRooms) Create(ctx context.Context, room *models.Room) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Create(room).Error; err != nil {
+		return err
+	}
+	return db.Preload("Owner").First(room, "id = ?", room.ID).Error
+}
+
+func (r *gormRooms) Update(ctx context.Context, room *models.Room, update RoomUpdate) error {
+	updates := map[string]interface{}{}
+	if update.AllowGuests != nil {
+		updates["allow_guests"] = *update.AllowGuests
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Room{}).Where("id = ?", room.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	if update.AllowGuests != nil {
+		room.AllowGuests = *update.AllowGuests
+	}
+	return nil
+}
+
+func (r *gormRooms) Touch(ctx context.Context, roomID string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Room{}).Where("id = ?", roomID).Update("last_active_at", at).Error
+}
+
+type gormMembers struct {
+	db *gorm.DB
+}
+
+func (r *gormMembers) Get(ctx context.Context, roomID, userID string) (*models.RoomMember, error) {
+	var member models.RoomMember
+	if err := r.db.WithContext(ctx).First(&member, "room_id = ? AND user_id = ?", roomID, userID).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &member, nil
+}
+
+func (r *gormMembers) Create(ctx context.Context, member *models.RoomMember) error {
+	return r.db.WithContext(ctx).Create(member).Error
+}
+
+func (r *gormMembers) ForUser(ctx context.Context, userID string, roomIDs []string) (map[string]*models.RoomMember, error) {
+	result := make(map[string]*models.RoomMember, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return result, nil
+	}
+
+	var members []models.RoomMember
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND room_id IN ?", userID, roomIDs).
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for i := range members {
+		result[members[i].RoomID] = &members[i]
+	}
+	return result, nil
+}
+
+func (r *gormMembers) Visit(ctx context.Context, roomID, userID string, at time.Time) (*models.RoomMember, error) {
+	db := r.db.WithContext(ctx)
+
+	member, err := r.Get(ctx, roomID, userID)
+	if errors.Is(err, ErrNotFound) {
+		member = &models.RoomMember{
+			RoomID:        roomID,
+			UserID:        userID,
+			LastVisitedAt: at,
+		}
+		if err = db.Create(member).Error; err == nil {
+			return member, nil
+		}
+		// Lost a race with a concurrent visit; the unique index kept the other row
+		member, err = r.Get(ctx, roomID, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(member).Update("last_visited_at", at).Error; err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func (r *gormMembers) ListByRoom(ctx context.Context, roomID string) ([]models.RoomMember, error) {
+	var members []models.RoomMember
+	if err := r.db.WithContext(ctx).Preload("User").Where("room_id = ?", roomID).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *gormMembers) Recent(ctx context.Context, userID string, limit int) ([]models.RoomMember, error) {
+	var members []models.RoomMember
+	if err := r.db.WithContext(ctx).Preload("Room").Preload("Room.Owner").
+		Where("user_id = ?", userID).
+		Order("last_visited_at DESC").
+		Limit(limit).
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,316 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+// NewMemory returns in-memory repositories for tests. They share one store, so related
+// records (room owners, member users and rooms) are filled in like the GORM versions do.
+func NewMemory() *Repositories {
+	store := &memoryStore{
+		users:   make(map[string]models.User),
+		rooms:   make(map[string]models.Room),
+		members: make(map[memberKey]models.RoomMember),
+	}
+	return &Repositories{
+		Users:   &memoryUsers{store},
+		Rooms:   &memoryRooms{store},
+		Members: &memoryMembers{store},
+	}
+}
+
+type memberKey struct {
+	roomID string
+	userID string
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	users   map[string]models.User
+	rooms   map[string]models.Room
+	members map[memberKey]models.RoomMember
+}
+
+// room returns a copy of the room with its owner loaded; callers hold mu
+func (s *memoryStore) room(room models.Room) models.Room {
+	if owner, ok := s.users[room.OwnerID]; ok {
+		room.Owner = &owner
+	} else {
+		room.Owner = nil
+	}
+	return room
+}
+
+type memoryUsers struct {
+	*memoryStore
+}
+
+func (r *memoryUsers) GetByID(ctx context.Context, id string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUsers) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Username == username {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUsers) UsernameTaken(ctx context.Context, username, exceptUserID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Username == username && user.ID != exceptUserID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUsers) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.users {
+		if existing.Username == user.Username {
+			return ErrDuplicate
+		}
+	}
+	user.BeforeCreate(nil)
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUsers) SetUsername(ctx context.Context, user *models.User, username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	for _, existing := range r.users {
+		if existing.Username == username && existing.ID != user.ID {
+			return ErrDuplicate
+		}
+	}
+	stored.Username = username
+	stored.UpdatedAt = time.Now()
+	r.users[user.ID] = stored
+	user.Username = username
+	return nil
+}
+
+type memoryRooms struct {
+	*memoryStore
+}
+
+func (r *memoryRooms) GetByCode(ctx context.Context, code string, activeOnly bool) (*models.Room, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, room := range r.rooms {
+		if room.Code == code && (!activeOnly || room.IsActive) {
+			room = r.room(room)
+			return &room, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryRooms) List(ctx context.Context, filter RoomFilter) ([]models.Room, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	query := strings.ToLower(strings.TrimSpace(filter.Query))
+	var rooms []models.Room
+	for _, room := range r.rooms {
+		if !room.IsActive || room.Visibility != models.RoomVisibilityPublic {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(room.Name), query) {
+			continue
+		}
+		if filter.HasPassword != nil && room.HasPassword() != *filter.HasPassword {
+			continue
+		}
+		if filter.IDs != nil && !slices.Contains(filter.IDs, room.ID) {
+			continue
+		}
+		if slices.Contains(filter.ExcludeIDs, room.ID) {
+			continue
+		}
+		rooms = append(rooms, r.room(room))
+	}
+
+	slices.SortFunc(rooms, func(a, b models.Room) int {
+		if filter.Sort == SortActivity {
+			return b.LastActiveAt.Compare(a.LastActiveAt)
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	if filter.Limit > 0 {
+		start := min(filter.Offset, len(rooms))
+		rooms = rooms[start:min(start+filter.Limit, len(rooms))]
+	}
+	return rooms, nil
+}
+
+func (r *memoryRooms) Create(ctx context.Context, room *models.Room) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	room.BeforeCreate(nil)
+	for _, existing := range r.rooms {
+		if existing.Code == room.Code {
+			return ErrDuplicate
+		}
+	}
+	now := time.Now()
+	if room.CreatedAt.IsZero() {
+		room.CreatedAt = now
+	}
+	room.UpdatedAt = now
+	room.Owner = nil
+	r.rooms[room.ID] = *room
+	*room = r.room(*room)
+	return nil
+}
+
+func (r *memoryRooms) Update(ctx context.Context, room *models.Room, update RoomUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.rooms[room.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if update.AllowGuests != nil {
+		stored.AllowGuests = *update.AllowGuests
+		room.AllowGuests = *update.AllowGuests
+	}
+	stored.UpdatedAt = time.Now()
+	r.rooms[room.ID] = stored
+	return nil
+}
+
+func (r *memoryRooms) Touch(ctx context.Context, roomID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if room, ok := r.rooms[roomID]; ok {
+		room.LastActiveAt = at
+		r.rooms[roomID] = room
+	}
+	return nil
+}
+
+type memoryMembers struct {
+	*memoryStore
+}
+
+func (r *memoryMembers) Get(ctx context.Context, roomID, userID string) (*models.RoomMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	member, ok := r.members[memberKey{roomID, userID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &member, nil
+}
+
+func (r *memoryMembers) Create(ctx context.Context, member *models.RoomMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := memberKey{member.RoomID, member.UserID}
+	if _, ok := r.members[key]; ok {
+		return ErrDuplicate
+	}
+	member.BeforeCreate(nil)
+	if member.CreatedAt.IsZero() {
+		member.CreatedAt = time.Now()
+	}
+	r.members[key] = *member
+	return nil
+}
+
+func (r *memoryMembers) ForUser(ctx context.Context, userID string, roomIDs []string) (map[string]*models.RoomMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make(map[string]*models.RoomMember, len(roomIDs))
+	for _, roomID := range roomIDs {
+		if member, ok := r.members[memberKey{roomID, userID}]; ok {
+			result[roomID] = &member
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryMembers) Visit(ctx context.Context, roomID, userID string, at time.Time) (*models.RoomMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := memberKey{roomID, userID}
+	member, ok := r.members[key]
+	if !ok {
+		member = models.RoomMember{RoomID: roomID, UserID: userID, CreatedAt: at}
+		member.BeforeCreate(nil)
+	}
+	member.LastVisitedAt = at
+	r.members[key] = member
+	return &member, nil
+}
+
+func (r *memoryMembers) ListByRoom(ctx context.Context, roomID string) ([]models.RoomMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var members []models.RoomMember
+	for key, member := range r.members {
+		if key.roomID != roomID {
+			continue
+		}
+		if user, ok := r.users[member.UserID]; ok {
+			member.User = &user
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (r *memoryMembers) Recent(ctx context.Context, userID string, limit int) ([]models.RoomMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var members []models.RoomMember
+	for key, member := range r.members {
+		if key.userID != userID {
+			continue
+		}
+		if room, ok := r.rooms[member.RoomID]; ok {
+			room = r.room(room)
+			member.Room = &room
+		}
+		members = append(members, member)
+	}
+	slices.SortFunc(members, func(a, b models.RoomMember) int {
+		return b.LastVisitedAt.Compare(a.LastVisitedAt)
+	})
+	if limit > 0 && len(members) > limit {
+		members = members[:limit]
+	}
+	return members, nil
+}
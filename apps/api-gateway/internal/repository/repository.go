@@ -0,0 +1,90 @@
+// Package repository puts data access for users, rooms and room memberships behind
+// interfaces, with GORM implementations for the server and in-memory fakes for tests.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+var (
+	ErrNotFound = errors.New("repository: not found")
+
+	// ErrDuplicate is returned by the in-memory fakes where the database would reject a
+	// unique key; the GORM versions return the driver's error except where noted
+	ErrDuplicate = errors.New("repository: duplicate")
+)
+
+// RoomSort orders room listings
+type RoomSort string
+
+const (
+	SortNewest   RoomSort = "newest"   // most recently created first
+	SortActivity RoomSort = "activity" // most recently active first
+)
+
+// RoomFilter selects rooms for the public room list. Only active, public rooms are listed.
+type RoomFilter struct {
+	Query       string // case-insensitive substring of the room name
+	HasPassword *bool
+	Sort        RoomSort
+	Limit       int // 0 returns every match
+	Offset      int
+
+	// IDs restricts the list to these rooms when not nil; ExcludeIDs leaves rooms out
+	IDs        []string
+	ExcludeIDs []string
+}
+
+// RoomUpdate lists the room settings to change; nil fields are left as they are
+type RoomUpdate struct {
+	AllowGuests *bool
+}
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	// UsernameTaken reports whether a user other than exceptUserID has username
+	UsernameTaken(ctx context.Context, username, exceptUserID string) (bool, error)
+	Create(ctx context.Context, user *models.User) error
+	// SetUsername renames user, returning ErrDuplicate if another user has username.
+	// The unique index decides, so two users racing for a name can't both get it.
+	SetUsername(ctx context.Context, user *models.User, username string) error
+}
+
+// RoomRepository stores rooms. Returned rooms have their Owner loaded.
+type RoomRepository interface {
+	// GetByCode finds a room by its code; activeOnly skips closed rooms
+	GetByCode(ctx context.Context, code string, activeOnly bool) (*models.Room, error)
+	List(ctx context.Context, filter RoomFilter) ([]models.Room, error)
+	Create(ctx context.Context, room *models.Room) error
+	Update(ctx context.Context, room *models.Room, update RoomUpdate) error
+	// Touch records activity so the idle cleanup job keeps the room
+	Touch(ctx context.Context, roomID string, at time.Time) error
+}
+
+// MembershipRepository stores which users have joined which rooms
+type MembershipRepository interface {
+	Get(ctx context.Context, roomID, userID string) (*models.RoomMember, error)
+	Create(ctx context.Context, member *models.RoomMember) error
+	// ForUser returns userID's memberships among roomIDs keyed by room ID, loaded together
+	// so room lists don't need a query per room
+	ForUser(ctx context.Context, userID string, roomIDs []string) (map[string]*models.RoomMember, error)
+	// Visit records a visit at the given time, creating the membership if needed
+	Visit(ctx context.Context, roomID, userID string, at time.Time) (*models.RoomMember, error)
+	// ListByRoom returns a room's members with their User loaded
+	ListByRoom(ctx context.Context, roomID string) ([]models.RoomMember, error)
+	// Recent returns userID's most recently visited memberships with Room and Room.Owner loaded
+	Recent(ctx context.Context, userID string, limit int) ([]models.RoomMember, error)
+}
+
+// Repositories bundles the repositories handlers depend on
+type Repositories struct {
+	Users   UserRepository
+	Rooms   RoomRepository
+	Members MembershipRepository
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+// Both implementations must pass the same tests, so the fakes stay faithful
+func TestRepositories(t *testing.T) {
+	t.Run("gorm", func(t *testing.T) {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&models.User{}, &models.Room{}, &models.RoomMember{}))
+		testRepositories(t, NewGorm(db))
+	})
+	t.Run("memory", func(t *testing.T) {
+		testRepositories(t, NewMemory())
+	})
+}
+
+func testRepositories(t *testing.T, repos *Repositories) {
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	alice := &models.User{Username: "alice"}
+	bob := &models.User{Username: "bob"}
+	require.NoError(t, repos.Users.Create(ctx, alice))
+	require.NoError(t, repos.Users.Create(ctx, bob))
+
+	t.Run("users", func(t *testing.T) {
+		user, err := repos.Users.GetByID(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", user.Username)
+
+		user, err = repos.Users.GetByUsername(ctx, "bob")
+		require.NoError(t, err)
+		assert.Equal(t, bob.ID, user.ID)
+
+		_, err = repos.Users.GetByUsername(ctx, "carol")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		taken, err := repos.Users.UsernameTaken(ctx, "alice", "")
+		require.NoError(t, err)
+		assert.True(t, taken)
+		taken, err = repos.Users.UsernameTaken(ctx, "alice", alice.ID)
+		require.NoError(t, err)
+		assert.False(t, taken, "a user's own name isn't taken for them")
+
+		assert.Error(t, repos.Users.Create(ctx, &models.User{Username: "alice"}))
+
+		assert.ErrorIs(t, repos.Users.SetUsername(ctx, bob, "alice"), ErrDuplicate)
+		require.NoError(t, repos.Users.SetUsername(ctx, bob, "bobby"))
+		assert.Equal(t, "bobby", bob.Username)
+		user, err = repos.Users.GetByID(ctx, bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "bobby", user.Username)
+		require.NoError(t, repos.Users.SetUsername(ctx, bob, "bob"))
+	})
+
+	newRoom := func(name string, age time.Duration, mutate func(*models.Room)) *models.Room {
+		room := &models.Room{
+			Name:         name,
+			OwnerID:      alice.ID,
+			IsActive:     true,
+			Visibility:   models.RoomVisibilityPublic,
+			CreatedAt:    base.Add(-age),
+			LastActiveAt: base.Add(age),
+		}
+		if mutate != nil {
+			mutate(room)
+		}
+		require.NoError(t, repos.Rooms.Create(ctx, room))
+		return room
+	}
+	movie := newRoom("Movie Night", 3*time.Minute, nil)
+	percent := newRoom("100% Fun", 2*time.Minute, nil)
+	locked := newRoom("Locked movie", time.Minute, func(r *models.Room) { r.SetPassword("secret") })
+	hidden := newRoom("Hidden movie", 0, func(r *models.Room) { r.Visibility = models.RoomVisibilityPrivate })
+
+	t.Run("rooms", func(t *testing.T) {
+		require.NotNil(t, movie.Owner, "Create loads the owner")
+		assert.Equal(t, "alice", movie.Owner.Username)
+
+		room, err := repos.Rooms.GetByCode(ctx, hidden.Code, true)
+		require.NoError(t, err)
+		assert.Equal(t, hidden.ID, room.ID)
+		require.NotNil(t, room.Owner)
+
+		_, err = repos.Rooms.GetByCode(ctx, "NOPE0000", false)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		names := func(filter RoomFilter) []string {
+			rooms, err := repos.Rooms.List(ctx, filter)
+			require.NoError(t, err)
+			result := make([]string, len(rooms))
+			for i, r := range rooms {
+				require.NotNil(t, r.Owner)
+				result[i] = r.Name
+			}
+			return result
+		}
+		yes := true
+		no := false
+
+		assert.Equal(t, []string{"Locked movie", "100% Fun", "Movie Night"}, names(RoomFilter{}))
+		assert.Equal(t, []string{"Movie Night", "100% Fun", "Locked movie"}, names(RoomFilter{Sort: SortActivity}))
+		assert.Equal(t, []string{"Locked movie", "Movie Night"}, names(RoomFilter{Query: " MOVIE "}))
+		assert.Equal(t, []string{"100% Fun"}, names(RoomFilter{Query: "%"}), "wildcards match literally")
+		assert.Equal(t, []string{"Locked movie"}, names(RoomFilter{HasPassword: &yes}))
+		assert.Equal(t, []string{"100% Fun", "Movie Night"}, names(RoomFilter{HasPassword: &no}))
+		assert.Equal(t, []string{"100% Fun"}, names(RoomFilter{Limit: 1, Offset: 1}))
+		assert.Empty(t, names(RoomFilter{Limit: 5, Offset: 10}))
+		assert.Equal(t, []string{"Locked movie", "Movie Night"}, names(RoomFilter{IDs: []string{movie.ID, locked.ID, hidden.ID}}))
+		assert.Empty(t, names(RoomFilter{IDs: []string{}}))
+		assert.Equal(t, []string{"100% Fun"}, names(RoomFilter{ExcludeIDs: []string{movie.ID, locked.ID}}))
+
+		allow := true
+		require.NoError(t, repos.Rooms.Update(ctx, locked, RoomUpdate{AllowGuests: &allow}))
+		assert.True(t, locked.AllowGuests)
+		room, err = repos.Rooms.GetByCode(ctx, locked.Code, true)
+		require.NoError(t, err)
+		assert.True(t, room.AllowGuests)
+
+		touched := base.Add(time.Hour)
+		require.NoError(t, repos.Rooms.Touch(ctx, percent.ID, touched))
+		room, err = repos.Rooms.GetByCode(ctx, percent.Code, true)
+		require.NoError(t, err)
+		assert.WithinDuration(t, touched, room.LastActiveAt, time.Second)
+	})
+
+	t.Run("memberships", func(t *testing.T) {
+		require.NoError(t, repos.Members.Create(ctx, &models.RoomMember{
+			RoomID: movie.ID, UserID: bob.ID, HasControlPermission: true, LastVisitedAt: base,
+		}))
+		require.NoError(t, repos.Members.Create(ctx, &models.RoomMember{
+			RoomID: percent.ID, UserID: bob.ID, LastVisitedAt: base.Add(time.Minute),
+		}))
+		assert.Error(t, repos.Members.Create(ctx, &models.RoomMember{RoomID: movie.ID, UserID: bob.ID}))
+
+		member, err := repos.Members.Get(ctx, movie.ID, bob.ID)
+		require.NoError(t, err)
+		assert.True(t, member.HasControlPermission)
+		_, err = repos.Members.Get(ctx, movie.ID, alice.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		byRoom, err := repos.Members.ForUser(ctx, bob.ID, []string{movie.ID, percent.ID, locked.ID})
+		require.NoError(t, err)
+		assert.Len(t, byRoom, 2)
+		assert.True(t, byRoom[movie.ID].HasControlPermission)
+		assert.False(t, byRoom[percent.ID].HasControlPermission)
+		assert.Nil(t, byRoom[locked.ID])
+
+		byRoom, err = repos.Members.ForUser(ctx, bob.ID, nil)
+		require.NoError(t, err)
+		assert.Empty(t, byRoom)
+
+		// Visiting keeps existing permissions and creates missing memberships
+		visited := base.Add(2 * time.Hour)
+		member, err = repos.Members.Visit(ctx, movie.ID, bob.ID, visited)
+		require.NoError(t, err)
+		assert.True(t, member.HasControlPermission)
+		member, err = repos.Members.Visit(ctx, locked.ID, bob.ID, visited.Add(time.Minute))
+		require.NoError(t, err)
+		assert.False(t, member.HasControlPermission)
+		assert.NotEmpty(t, member.ID)
+
+		recent, err := repos.Members.Recent(ctx, bob.ID, 2)
+		require.NoError(t, err)
+		require.Len(t, recent, 2)
+		assert.Equal(t, locked.ID, recent[0].RoomID)
+		assert.Equal(t, movie.ID, recent[1].RoomID)
+		require.NotNil(t, recent[0].Room)
+		require.NotNil(t, recent[0].Room.Owner)
+		assert.Equal(t, "alice", recent[0].Room.Owner.Username)
+
+		members, err := repos.Members.ListByRoom(ctx, movie.ID)
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		require.NotNil(t, members[0].User)
+		assert.Equal(t, "bob", members[0].User.Username)
+	})
+}
@@ -0,0 +1,35 @@
+// Package storage stores uploaded files on local disk or S3-compatible object storage
+package storage
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys that are empty or try to escape the storage root
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// Storage is a place to keep uploaded files that are served publicly by URL
+type Storage interface {
+	// Put stores data under key, replacing any existing object, and returns its public URL
+	Put(ctx context.Context, key, contentType string, data []byte) (string, error)
+
+	// Delete removes key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// cleanKey normalizes a slash-separated key and rejects anything outside the root
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// joinURL appends key to a base URL
+func joinURL(base, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + key
+}
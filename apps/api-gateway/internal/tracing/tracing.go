@@ -0,0 +1,99 @@
+// Package tracing sets up OpenTelemetry tracing and provides the spans the gateway
+// records: HTTP routes, GORM queries, WebSocket events and calls to other services.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation names the gateway's tracer
+const instrumentation = "github.com/yourusername/cowatch/api-gateway"
+
+// Options configures Setup
+type Options struct {
+	// Exporter is "none", "stdout" (pretty-printed spans for local runs) or "otlp"
+	Exporter string
+	// Endpoint is the OTLP/HTTP collector, e.g. localhost:4318; empty uses the
+	// OTEL_EXPORTER_OTLP_* environment variables
+	Endpoint string
+	// Insecure sends OTLP over plain HTTP
+	Insecure bool
+	// SampleRatio is the fraction of new traces recorded; traces started upstream keep their decision
+	SampleRatio float64
+
+	ServiceName    string
+	ServiceVersion string
+
+	// Stdout receives spans for the stdout exporter; nil means os.Stdout
+	Stdout io.Writer
+}
+
+// Setup installs the global tracer provider and W3C trace context propagation.
+// The returned function flushes buffered spans and should run at shutdown.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	// Propagate trace context even when not exporting, so upstream traces pass through
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case "none", "":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		stdoutOpts := []stdouttrace.Option{stdouttrace.WithPrettyPrint()}
+		if opts.Stdout != nil {
+			stdoutOpts = append(stdoutOpts, stdouttrace.WithWriter(opts.Stdout))
+		}
+		exporter, err = stdouttrace.New(stdoutOpts...)
+	case "otlp":
+		var otlpOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			otlpOpts = append(otlpOpts, otlptracehttp.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			otlpOpts = append(otlpOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, otlpOpts...)
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+		semconv.ServiceVersion(opts.ServiceVersion),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the gateway's tracer from the global provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
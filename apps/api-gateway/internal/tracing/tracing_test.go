@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// record installs a provider that samples everything and returns its finished spans
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := record(t)
+
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/rooms/:roomCode", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/broken", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+
+	// The caller's trace continues through the gateway
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/rooms/ABC123", nil)
+	req.Header.Set("traceparent", parent)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/broken", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	room := spans[0]
+	assert.Equal(t, "GET /rooms/:roomCode", room.Name(), "named by route, not by room code")
+	assert.Equal(t, trace.SpanKindServer, room.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", room.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", room.Parent().SpanID().String())
+	assert.Equal(t, int64(200), attr(room, "http.response.status_code").AsInt64())
+	assert.Equal(t, codes.Unset, room.Status().Code)
+
+	broken := spans[1]
+	assert.False(t, broken.Parent().IsValid(), "no traceparent starts a new trace")
+	assert.Equal(t, codes.Error, broken.Status().Code)
+}
+
+func TestTransport(t *testing.T) {
+	recorder := record(t)
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer upstream.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "GET /readyz")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL+"/health", nil)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	parent.End()
+
+	assert.Empty(t, req.Header.Get("traceparent"), "the caller's request is left alone")
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	client := spans[0]
+	assert.Equal(t, trace.SpanKindClient, client.SpanKind())
+	assert.Equal(t, parent.SpanContext().SpanID(), client.Parent().SpanID())
+	assert.Equal(t, codes.Error, client.Status().Code)
+
+	propagated := propagation.TraceContext{}.Extract(context.Background(),
+		propagation.HeaderCarrier{"Traceparent": []string{traceparent}})
+	assert.Equal(t, client.SpanContext().SpanID(), trace.SpanContextFromContext(propagated).SpanID(),
+		"the upstream service continues from the client span")
+}
+
+func TestGormPlugin(t *testing.T) {
+	recorder := record(t)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(GormPlugin{}))
+
+	type Video struct {
+		ID    uint
+		Title string
+	}
+	require.NoError(t, db.AutoMigrate(&Video{}))
+	require.NoError(t, db.Create(&Video{Title: "untraced"}).Error)
+	assert.Empty(t, recorder.Ended(), "queries outside a trace record nothing")
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "POST /rooms")
+	require.NoError(t, db.WithContext(ctx).Create(&Video{Title: "secret title"}).Error)
+	err = db.WithContext(ctx).First(&Video{}, 999).Error
+	require.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	create := spans[0]
+	assert.Equal(t, "db.create videos", create.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), create.Parent().SpanID())
+	query := attr(create, "db.query.text").AsString()
+	assert.Contains(t, query, "INSERT INTO")
+	assert.NotContains(t, query, "secret title", "bound values stay out of spans")
+
+	assert.Equal(t, "db.query videos", spans[1].Name())
+	assert.Equal(t, codes.Unset, spans[1].Status().Code, "a missing row is not an error")
+}
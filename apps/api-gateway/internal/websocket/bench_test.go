@@ -0,0 +1,273 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+// joinBenchRoom connects size members to a new room with dialer and returns their
+// connections, the host's first
+func joinBenchRoom(b *testing.B, handler *HTTPHandler, server *httptest.Server, size int, dialer *websocket.Dialer) []*websocket.Conn {
+	db := handler.DB
+	owner := models.User{Username: "bench-owner", PasswordHash: "x"}
+	require.NoError(b, db.Create(&owner).Error)
+	room := models.Room{Name: "Bench Room", OwnerID: owner.ID, IsActive: true}
+	require.NoError(b, db.Create(&room).Error)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/rooms/" + room.Code + "?ticket="
+	conns := make([]*websocket.Conn, size)
+	for i := range conns {
+		user := owner
+		if i > 0 {
+			user = models.User{Username: fmt.Sprintf("bench-%d", i), PasswordHash: "x"}
+			require.NoError(b, db.Create(&user).Error)
+		}
+		require.NoError(b, db.Create(&models.RoomMember{RoomID: room.ID, UserID: user.ID}).Error)
+		conn, _, err := dialer.Dial(url+createTicket(b, db, user.ID, room.ID, time.Now().Add(time.Minute)), nil)
+		require.NoError(b, err)
+		b.Cleanup(func() { conn.Close() })
+		conns[i] = conn
+	}
+	require.Eventually(b, func() bool { return handler.Hub.Stats().Connections == size }, 5*time.Second, 10*time.Millisecond)
+	return conns
+}
+
+// leaveBenchRoom closes the connections and waits for their readers to stop
+func leaveBenchRoom(conns []*websocket.Conn, readers *sync.WaitGroup) {
+	for _, conn := range conns {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		conn.Close()
+	}
+	readers.Wait()
+}
+
+// decodeFrame returns the messages in a frame, which is an array on batching connections
+func decodeFrame(codec Codec, data []byte) []ClientMessage {
+	var raws [][]byte
+	switch {
+	case codec == JSON && data[0] == '[':
+		var batch []json.RawMessage
+		if json.Unmarshal(data, &batch) != nil {
+			return nil
+		}
+		for _, raw := range batch {
+			raws = append(raws, raw)
+		}
+	case codec == Msgpack && (data[0]&0xf0 == 0x90 || data[0] == 0xdc || data[0] == 0xdd):
+		var batch []msgpack.RawMessage
+		if msgpack.Unmarshal(data, &batch) != nil {
+			return nil
+		}
+		for _, raw := range batch {
+			raws = append(raws, raw)
+		}
+	default:
+		raws = [][]byte{data}
+	}
+
+	msgs := make([]ClientMessage, 0, len(raws))
+	for _, raw := range raws {
+		var msg ClientMessage
+		if codec.Decode(raw, &msg) == nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// BenchmarkRoomBroadcast measures how fast a room of 50 clients relays video:sync from one
+// client as video:state to the other 49, including decoding on the receiving side
+func BenchmarkRoomBroadcast(b *testing.B) {
+	const (
+		roomSize = 50
+		window   = 64 // frames in flight, well under the send buffer
+	)
+
+	for _, subprotocol := range []string{SubprotocolJSON, SubprotocolMsgpack} {
+		b.Run(subprotocol, func(b *testing.B) {
+			handler, server := setupTestHandler(b)
+			handler.Limits.EventRate = 0
+			conns := joinBenchRoom(b, handler, server, roomSize, &websocket.Dialer{Subprotocols: []string{subprotocol}})
+
+			var (
+				received  atomic.Int64
+				bytesRead atomic.Int64
+				readers   sync.WaitGroup
+			)
+			codec := CodecFor(subprotocol)
+			for _, conn := range conns[1:] {
+				readers.Add(1)
+				go func() {
+					defer readers.Done()
+					for {
+						_, data, err := conn.ReadMessage()
+						if err != nil {
+							return
+						}
+						var msg ClientMessage
+						var state VideoStatePayload
+						if codec.Decode(data, &msg) != nil || msg.Type != EventVideoState || codec.DecodePayload(msg.Payload, &state) != nil {
+							continue
+						}
+						bytesRead.Add(int64(len(data)))
+						received.Add(1)
+					}
+				}()
+			}
+
+			sender := conns[0]
+			frameType := codec.FrameType()
+			frame := encodeClientFrame(b, subprotocol, EventVideoSync, map[string]any{"currentTime": 1234.567, "isPlaying": true, "playbackRate": 1})
+			// caughtUp reports whether every receiver has the first sent frames
+			caughtUp := func(sent int) bool {
+				return received.Load() >= int64(sent*(roomSize-1))
+			}
+
+			b.ResetTimer()
+			for i := 1; i <= b.N; i++ {
+				require.NoError(b, sender.WriteMessage(frameType, frame))
+				for i > window && !caughtUp(i-window) {
+					time.Sleep(10 * time.Microsecond)
+				}
+			}
+			for !caughtUp(b.N) {
+				time.Sleep(10 * time.Microsecond)
+			}
+			b.StopTimer()
+
+			frames := float64(b.N * (roomSize - 1))
+			b.ReportMetric(frames/b.Elapsed().Seconds(), "frames/s")
+			b.ReportMetric(float64(bytesRead.Load())/frames, "B/frame")
+			leaveBenchRoom(conns, &readers)
+		})
+	}
+}
+
+// chatLines are typical chat messages during a watch party
+var chatLines = []string{
+	"哈哈哈哈哈哈",
+	"前方高能！！！",
+	"这段太好笑了",
+	"+1",
+	"等一下，我暂停一下",
+	"有人知道这首背景音乐叫什么吗？",
+	"who is that actor again?",
+	"字幕是不是慢了半秒",
+	"我先去倒杯水，马上回来",
+	"lol",
+	"这个反转我是真没想到",
+	"下一集继续吗",
+}
+
+// BenchmarkChatBandwidth measures the bytes on the wire per delivered message in a room of
+// 20 where 5 members chat as fast as the server accepts, with and without compression
+// and batching. Compression uses DefaultCompression.
+func BenchmarkChatBandwidth(b *testing.B) {
+	const (
+		roomSize = 20
+		senders  = 5
+		window   = 32 // messages in flight per sender
+	)
+
+	for _, subprotocol := range []string{SubprotocolJSON, SubprotocolMsgpack} {
+		for _, mode := range []struct {
+			name     string
+			compress bool
+			batch    bool
+		}{
+			{"plain", false, false},
+			{"deflate", true, false},
+			{"batch", false, true},
+			{"deflate+batch", true, true},
+		} {
+			b.Run(subprotocol+"/"+mode.name, func(b *testing.B) {
+				handler, server := setupTestHandler(b)
+				handler.Limits.EventRate = 0
+				handler.Compression.Enabled = mode.compress
+
+				offer := subprotocol
+				if mode.batch {
+					offer += BatchSuffix
+				}
+				var wire atomic.Int64
+				conns := joinBenchRoom(b, handler, server, roomSize, countingDialer(&wire, offer))
+
+				var (
+					received atomic.Int64
+					frames   atomic.Int64
+					readers  sync.WaitGroup
+				)
+				codec := CodecFor(subprotocol)
+				for _, conn := range conns {
+					readers.Add(1)
+					go func() {
+						defer readers.Done()
+						for {
+							_, data, err := conn.ReadMessage()
+							if err != nil {
+								return
+							}
+							frames.Add(1)
+							for _, msg := range decodeFrame(codec, data) {
+								if msg.Type == EventChatBroadcast {
+									received.Add(1)
+								}
+							}
+						}
+					}()
+				}
+
+				// Each sender waits until every member has all but its last window messages
+				var sent atomic.Int64
+				caughtUp := func(upTo int64) bool {
+					return received.Load() >= upTo*roomSize
+				}
+
+				b.ResetTimer()
+				wire.Store(0)
+				frames.Store(0)
+				var wg sync.WaitGroup
+				for s := range senders {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						conn := conns[s]
+						for i := s; i < b.N; i += senders {
+							line := chatLines[i%len(chatLines)]
+							frame := encodeClientFrame(b, subprotocol, EventChatMessage, map[string]any{"message": line})
+							if err := conn.WriteMessage(codec.FrameType(), frame); err != nil {
+								b.Error(err)
+								return
+							}
+							for n := sent.Add(1); n > senders*window && !caughtUp(n-senders*window); {
+								time.Sleep(10 * time.Microsecond)
+							}
+						}
+					}()
+				}
+				wg.Wait()
+				for !caughtUp(int64(b.N)) {
+					time.Sleep(10 * time.Microsecond)
+				}
+				b.StopTimer()
+
+				delivered := float64(b.N * roomSize)
+				b.ReportMetric(float64(wire.Load())/delivered, "wire-B/msg")
+				b.ReportMetric(delivered/float64(frames.Load()), "msgs/frame")
+				leaveBenchRoom(conns, &readers)
+			})
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Subprotocols a client may request in Sec-WebSocket-Protocol. Connections that don't ask
+// for one use JSON.
+const (
+	SubprotocolMsgpack = "msgpack"
+	SubprotocolJSON    = "json"
+)
+
+// BatchSuffix marks a subprotocol whose frames may carry several messages, e.g.
+// "msgpack.batch". Such a frame is an array of messages instead of a single message.
+const BatchSuffix = ".batch"
+
+// Subprotocols lists the supported subprotocols in order of preference
+var Subprotocols = []string{
+	SubprotocolMsgpack + BatchSuffix,
+	SubprotocolMsgpack,
+	SubprotocolJSON + BatchSuffix,
+	SubprotocolJSON,
+}
+
+// Codec encodes a connection's frames. Both codecs use the JSON field names, so payloads
+// look the same whichever a client picks.
+type Codec interface {
+	// FrameType is the WebSocket message type frames are sent as
+	FrameType() int
+	Encode(msg *WSMessage) ([]byte, error)
+	// EncodeBatch encodes messages as one array, for subprotocols with BatchSuffix
+	EncodeBatch(msgs []*WSMessage) ([]byte, error)
+	// Decode reads a client frame, leaving its payload encoded for DecodePayload
+	Decode(data []byte, msg *ClientMessage) error
+	DecodePayload(data []byte, v any) error
+}
+
+var (
+	// JSON sends text frames of JSON; it is the default
+	JSON Codec = jsonCodec{}
+	// Msgpack sends binary frames of MessagePack
+	Msgpack Codec = msgpackCodec{}
+)
+
+// CodecFor returns the codec for a negotiated subprotocol
+func CodecFor(subprotocol string) Codec {
+	if strings.TrimSuffix(subprotocol, BatchSuffix) == SubprotocolMsgpack {
+		return Msgpack
+	}
+	return JSON
+}
+
+// Batches reports whether a negotiated subprotocol accepts batched frames
+func Batches(subprotocol string) bool {
+	return strings.HasSuffix(subprotocol, BatchSuffix)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+func (jsonCodec) Encode(msg *WSMessage) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonCodec) EncodeBatch(msgs []*WSMessage) ([]byte, error) {
+	return json.Marshal(msgs)
+}
+
+func (jsonCodec) Decode(data []byte, msg *ClientMessage) error {
+	var frame struct {
+		Type      string          `json:"type"`
+		Payload   json.RawMessage `json:"payload"`
+		Timestamp int64           `json:"timestamp"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return err
+	}
+	*msg = ClientMessage{Type: frame.Type, Payload: frame.Payload, Timestamp: frame.Timestamp}
+	return nil
+}
+
+func (jsonCodec) DecodePayload(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (c msgpackCodec) Encode(msg *WSMessage) ([]byte, error) {
+	return c.encode(msg)
+}
+
+func (c msgpackCodec) EncodeBatch(msgs []*WSMessage) ([]byte, error) {
+	return c.encode(msgs)
+}
+
+func (msgpackCodec) encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+
+	// Reset clears the options, so set them afterwards
+	enc.Reset(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c msgpackCodec) Decode(data []byte, msg *ClientMessage) error {
+	var frame struct {
+		Type      string             `json:"type"`
+		Payload   msgpack.RawMessage `json:"payload"`
+		Timestamp int64              `json:"timestamp"`
+	}
+	if err := c.DecodePayload(data, &frame); err != nil {
+		return err
+	}
+	*msg = ClientMessage{Type: frame.Type, Payload: frame.Payload, Timestamp: frame.Timestamp}
+	return nil
+}
+
+func (msgpackCodec) DecodePayload(data []byte, v any) error {
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+
+	dec.Reset(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
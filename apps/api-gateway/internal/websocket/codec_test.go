@@ -0,0 +1,156 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+var codecs = map[string]Codec{SubprotocolJSON: JSON, SubprotocolMsgpack: Msgpack}
+
+// encodeClientFrame builds a frame as a browser client would send it, without the
+// gateway's codecs
+func encodeClientFrame(t testing.TB, subprotocol, eventType string, payload map[string]any) []byte {
+	frame := map[string]any{"type": eventType, "payload": payload, "timestamp": time.Now().UnixMilli()}
+	var (
+		data []byte
+		err  error
+	)
+	if subprotocol == SubprotocolMsgpack {
+		data, err = msgpack.Marshal(frame)
+	} else {
+		data, err = json.Marshal(frame)
+	}
+	require.NoError(t, err)
+	return data
+}
+
+func TestCodecs(t *testing.T) {
+	sizes := make(map[string]int)
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.Encode(NewVideoStateEvent(12.5, true, 1, "user-1"))
+			require.NoError(t, err)
+			sizes[name] = len(data)
+
+			var sent struct {
+				Type    string            `json:"type"`
+				Payload VideoStatePayload `json:"payload"`
+			}
+			require.NoError(t, codec.DecodePayload(data, &sent), "frames use the JSON field names")
+			assert.Equal(t, EventVideoState, sent.Type)
+			assert.Equal(t, VideoStatePayload{CurrentTime: 12.5, IsPlaying: true, PlaybackRate: 1, TriggeredBy: "user-1"}, sent.Payload)
+
+			var msg ClientMessage
+			frame := encodeClientFrame(t, name, EventVideoSync, map[string]any{"currentTime": 3, "isPlaying": true, "playbackRate": 1.5})
+			require.NoError(t, codec.Decode(frame, &msg))
+			assert.Equal(t, EventVideoSync, msg.Type)
+
+			var payload VideoSyncPayload
+			require.NoError(t, codec.DecodePayload(msg.Payload, &payload))
+			assert.Equal(t, VideoSyncPayload{CurrentTime: 3, IsPlaying: true, PlaybackRate: 1.5}, payload, "integers decode into float fields")
+
+			assert.Error(t, codec.Decode([]byte("\xc1 not a frame"), &msg))
+		})
+	}
+	assert.Less(t, sizes[SubprotocolMsgpack], sizes[SubprotocolJSON])
+}
+
+func TestHandleWebSocketSubprotocols(t *testing.T) {
+	handler, server := setupTestHandler(t)
+	db := handler.DB
+
+	user := models.User{Username: "codecuser", PasswordHash: "x"}
+	require.NoError(t, db.Create(&user).Error)
+	room := models.Room{Name: "Codec Room", OwnerID: user.ID, IsActive: true}
+	require.NoError(t, db.Create(&room).Error)
+	require.NoError(t, db.Create(&models.RoomMember{RoomID: room.ID, UserID: user.ID}).Error)
+
+	connect := func(t *testing.T, subprotocols ...string) *websocket.Conn {
+		ticket := createTicket(t, db, user.ID, room.ID, time.Now().Add(30*time.Second))
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/rooms/" + room.Code + "?ticket=" + ticket
+		conn, _, err := (&websocket.Dialer{Subprotocols: subprotocols}).Dial(url, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	// next returns the next frame of the given event type
+	next := func(t *testing.T, conn *websocket.Conn, eventType string) (int, []byte) {
+		codec := CodecFor(conn.Subprotocol())
+		for {
+			frameType, data, err := conn.ReadMessage()
+			require.NoError(t, err)
+			var msg ClientMessage
+			require.NoError(t, codec.Decode(data, &msg))
+			if msg.Type == eventType {
+				return frameType, msg.Payload
+			}
+		}
+	}
+
+	t.Run("msgpack", func(t *testing.T) {
+		conn := connect(t, "v2.cowatch", SubprotocolMsgpack)
+		assert.Equal(t, SubprotocolMsgpack, conn.Subprotocol())
+
+		frameType, _ := next(t, conn, EventRoomInit)
+		assert.Equal(t, websocket.BinaryMessage, frameType)
+
+		frame := encodeClientFrame(t, SubprotocolMsgpack, EventChatMessage, map[string]any{"message": "你好"})
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, frame))
+		_, data := next(t, conn, EventChatBroadcast)
+		var chat ChatMessageBroadcastPayload
+		require.NoError(t, Msgpack.DecodePayload(data, &chat))
+		assert.Equal(t, "你好", chat.Message)
+	})
+
+	t.Run("JSON without a subprotocol", func(t *testing.T) {
+		conn := connect(t)
+		assert.Empty(t, conn.Subprotocol())
+
+		frameType, _ := next(t, conn, EventRoomInit)
+		assert.Equal(t, websocket.TextMessage, frameType)
+	})
+}
+
+// BenchmarkCodecs compares encoding a server event and decoding a client event
+func BenchmarkCodecs(b *testing.B) {
+	state := NewVideoStateEvent(1234.567, true, 1, "6f1c2a1e-8d4b-4c55-9a77-0e1b2c3d4e5f")
+	for _, subprotocol := range []string{SubprotocolJSON, SubprotocolMsgpack} {
+		codec := CodecFor(subprotocol)
+		b.Run(subprotocol+"/encode", func(b *testing.B) {
+			b.ReportAllocs()
+			var size int
+			for range b.N {
+				data, err := codec.Encode(state)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "B/frame")
+		})
+		b.Run(subprotocol+"/decode", func(b *testing.B) {
+			frame := encodeClientFrame(b, subprotocol, EventVideoSync, map[string]any{"currentTime": 1234.567, "isPlaying": true, "playbackRate": 1})
+			b.ReportAllocs()
+			for range b.N {
+				var msg ClientMessage
+				var payload VideoSyncPayload
+				if err := codec.Decode(frame, &msg); err != nil {
+					b.Fatal(err)
+				}
+				if err := codec.DecodePayload(msg.Payload, &payload); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
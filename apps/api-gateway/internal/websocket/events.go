@@ -0,0 +1,172 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/metrics"
+	"github.com/yourusername/cowatch/api-gateway/internal/tracing"
+)
+
+// MaxPlaybackRate is the fastest playback rate clients may sync
+const MaxPlaybackRate = 16
+
+// events routes every client event
+var events = newEventRouter()
+
+func newEventRouter() *Router {
+	r := NewRouter()
+	r.Use(traceEvents, measureEvents, logEvents, limitEvents)
+
+	Register(r, EventDef[VideoControlPayload]{
+		Type:       EventVideoPlay,
+		Permission: PermissionControl,
+		Handle:     handleVideoControl(true),
+	})
+	Register(r, EventDef[VideoControlPayload]{
+		Type:       EventVideoPause,
+		Permission: PermissionControl,
+		Handle:     handleVideoControl(false),
+	})
+	Register(r, EventDef[VideoSeekPayload]{
+		Type:       EventVideoSeek,
+		Permission: PermissionControl,
+		Validate: func(p *VideoSeekPayload) error {
+			return validPlaybackTime(p.CurrentTime)
+		},
+		Handle: handleVideoSeek,
+	})
+	Register(r, EventDef[VideoSyncPayload]{
+		Type: EventVideoSync,
+		Validate: func(p *VideoSyncPayload) error {
+			if p.PlaybackRate <= 0 || p.PlaybackRate > MaxPlaybackRate {
+				return invalidPayload("播放速率无效")
+			}
+			return validPlaybackTime(p.CurrentTime)
+		},
+		Handle: handleVideoSync,
+	})
+	Register(r, EventDef[ChatMessagePayload]{
+		Type: EventChatMessage,
+		Validate: func(p *ChatMessagePayload) error {
+			p.Message = strings.TrimSpace(p.Message)
+			if p.Message == "" || utf8.RuneCountInString(p.Message) > MaxChatMessageLength {
+				return invalidPayload("消息长度必须在1-1000个字符之间")
+			}
+			return nil
+		},
+		Handle: handleChatMessage,
+	})
+	Register(r, EventDef[VideoChangePayload]{
+		Type:       EventVideoChange,
+		Permission: PermissionControl,
+		Validate: func(p *VideoChangePayload) error {
+			p.VideoID = strings.TrimSpace(p.VideoID)
+			if p.VideoID == "" {
+				return invalidPayload("视频 ID 不能为空")
+			}
+			return nil
+		},
+		Handle: handleVideoChange,
+	})
+
+	return r
+}
+
+// validPlaybackTime rejects negative and non-finite positions
+func validPlaybackTime(t float64) error {
+	if t < 0 || math.IsNaN(t) || math.IsInf(t, 0) {
+		return invalidPayload("播放进度无效")
+	}
+	return nil
+}
+
+// errorCode is the code an event error is reported with, or "ok"
+func errorCode(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	var clientErr *ClientError
+	if errors.As(err, &clientErr) {
+		return clientErr.Code
+	}
+	return "INTERNAL_ERROR"
+}
+
+// traceEvents makes each event its own trace, linked to the connection's handshake
+func traceEvents(next Handler) Handler {
+	return func(ctx context.Context, ev *Event) error {
+		c := ev.Client
+		ctx, span := tracing.Tracer().Start(ctx, "ws "+ev.Label(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithLinks(trace.Link{SpanContext: c.handshake}),
+			trace.WithAttributes(
+				attribute.String("ws.event", ev.Type),
+				attribute.String("room.id", c.RoomID),
+				attribute.String("user.id", c.UserID),
+				attribute.String("client.id", c.ID),
+			),
+		)
+		defer span.End()
+
+		err := next(ctx, ev)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, errorCode(err))
+		}
+		return err
+	}
+}
+
+// measureEvents counts incoming events and times their handling
+func measureEvents(next Handler) Handler {
+	return func(ctx context.Context, ev *Event) error {
+		label := ev.Label()
+		metrics.WebSocketMessages.WithLabelValues("in", label).Inc()
+
+		start := time.Now()
+		err := next(ctx, ev)
+		metrics.WebSocketEventDuration.WithLabelValues(label, errorCode(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// logEvents logs each event at debug level
+func logEvents(next Handler) Handler {
+	return func(ctx context.Context, ev *Event) error {
+		start := time.Now()
+		err := next(ctx, ev)
+		ev.Client.logger().Debug("Handled event",
+			"type", ev.Label(),
+			"outcome", errorCode(err),
+			"duration", time.Since(start),
+		)
+		return err
+	}
+}
+
+// limitEvents drops events from clients sending faster than Limits.EventRate
+func limitEvents(next Handler) Handler {
+	return func(ctx context.Context, ev *Event) error {
+		c := ev.Client
+		if c.Limits.EventRate > 0 {
+			// Only the client's read loop dispatches its events, so no locking is needed
+			if c.eventLimiter == nil {
+				c.eventLimiter = rate.NewLimiter(rate.Limit(c.Limits.EventRate), c.Limits.EventBurst)
+			}
+			if !c.eventLimiter.Allow() {
+				return errRateLimited
+			}
+		}
+		return next(ctx, ev)
+	}
+}
@@ -0,0 +1,273 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/metrics"
+)
+
+func TestHubEvictsSlowConsumers(t *testing.T) {
+	hub := NewHub()
+	fast := &Client{ID: "fast", RoomID: "room-1", Send: make(chan *WSMessage, 4)}
+	slow := &Client{ID: "slow", RoomID: "room-1", Send: make(chan *WSMessage, 1)}
+	other := &Client{ID: "other", RoomID: "room-2", Send: make(chan *WSMessage, 4)}
+	hub.rooms["room-1"] = map[*Client]bool{fast: true, slow: true}
+	hub.rooms["room-2"] = map[*Client]bool{other: true}
+
+	assert.Equal(t, metrics.HubStats{Connections: 3, Rooms: 2}, hub.Stats())
+
+	evictions := testutil.ToFloat64(metrics.WebSocketEvictions)
+	msg := &WSMessage{Type: EventVideoState}
+	hub.broadcastToRoom(&BroadcastMessage{RoomID: "room-1", Message: msg})
+	hub.broadcastToRoom(&BroadcastMessage{RoomID: "room-1", Message: msg})
+
+	assert.Equal(t, evictions+1, testutil.ToFloat64(metrics.WebSocketEvictions))
+	assert.Equal(t, metrics.HubStats{Connections: 2, Rooms: 2}, hub.Stats())
+	assert.Len(t, fast.Send, 2)
+
+	// The evicted client's queue is closed after what it already received
+	<-slow.Send
+	_, open := <-slow.Send
+	require.False(t, open)
+
+	// Replies still in flight, like a room init or an error, are dropped rather than panicking
+	assert.NotPanics(t, func() { slow.sendError("TEST", "evicted") })
+	assert.False(t, slow.queue(msg))
+}
+
+func TestHubEvictionEmptiesRoom(t *testing.T) {
+	hub := NewHub()
+	hub.updateVideoState("room-1", func(state *VideoState) { state.IsPlaying = true })
+
+	// Readers run alongside evictions; go test -race catches unguarded map writes
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			hub.Stats()
+			hub.OnlineCounts()
+			hub.GetOnlineGuests("room-1")
+		}
+	}()
+
+	msg := &WSMessage{Type: EventVideoState}
+	for i := 0; i < 100; i++ {
+		slow := &Client{ID: "slow", RoomID: "room-1", Send: make(chan *WSMessage)}
+		hub.mu.Lock()
+		hub.rooms["room-1"] = map[*Client]bool{slow: true}
+		hub.mu.Unlock()
+		hub.broadcastToRoom(&BroadcastMessage{RoomID: "room-1", Message: msg})
+	}
+	<-done
+
+	assert.Equal(t, metrics.HubStats{}, hub.Stats(), "the last eviction drops the room")
+	assert.False(t, hub.IsPlaying("room-1"), "and its playback state")
+}
+
+func TestHubPing(t *testing.T) {
+	hub := NewHub()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, hub.Ping(ctx), context.DeadlineExceeded, "nothing is running the loop")
+
+	go hub.Run()
+	assert.NoError(t, hub.Ping(context.Background()))
+}
+
+func TestHubCloses(t *testing.T) {
+	hub := NewHub()
+	connect := func(roomID, userID string, guest bool) *websocket.Conn {
+		server, client, _ := connPair(t)
+		c := &Client{RoomID: roomID, UserID: userID, IsGuest: guest, Conn: server}
+		if hub.rooms[roomID] == nil {
+			hub.rooms[roomID] = make(map[*Client]bool)
+		}
+		hub.rooms[roomID][c] = true
+		return client
+	}
+	closedWith := func(conn *websocket.Conn) int {
+		_, _, err := conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			return closeErr.Code
+		}
+		return -1
+	}
+
+	aliceInA := connect("room-a", "alice", false)
+	aliceInB := connect("room-b", "alice", false)
+	bobInB := connect("room-b", "bob", false)
+	guestInA := connect("room-a", "alice", true)
+	carolInC := connect("room-c", "carol", false)
+
+	hub.DisconnectUser("alice", "account deleted")
+	assert.Equal(t, websocket.ClosePolicyViolation, closedWith(aliceInA))
+	assert.Equal(t, websocket.ClosePolicyViolation, closedWith(aliceInB))
+
+	hub.CloseRoom("room-b", "room closed")
+	assert.Equal(t, websocket.CloseNormalClosure, closedWith(bobInB))
+
+	// Guests have their own IDs; a user ID that happens to match must not close them
+	hub.CloseRoom("room-a", "room closed")
+	assert.Equal(t, websocket.CloseNormalClosure, closedWith(guestInA))
+
+	carolInC.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, _, err := carolInC.ReadMessage()
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout(), "other rooms stay connected")
+}
+
+// countingConn counts the bytes read off the wire, before any decompression
+type countingConn struct {
+	net.Conn
+	read *atomic.Int64
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+// countingDialer offers compression and counts the bytes it receives into wire
+func countingDialer(wire *atomic.Int64, subprotocols ...string) *websocket.Dialer {
+	return &websocket.Dialer{
+		Subprotocols:      subprotocols,
+		EnableCompression: true,
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return countingConn{Conn: conn, read: wire}, nil
+		},
+	}
+}
+
+// connPair returns both ends of a compressed WebSocket connection, counting the bytes
+// the client receives
+func connPair(t *testing.T) (server, client *websocket.Conn, wire *atomic.Int64) {
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{EnableCompression: true}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	wire = new(atomic.Int64)
+	client, _, err := countingDialer(wire).Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return <-conns, client, wire
+}
+
+func TestWritePump(t *testing.T) {
+	queue := func(c *Client, messages ...string) {
+		for _, message := range messages {
+			c.Send <- NewErrorEvent("TEST", message)
+		}
+		close(c.Send)
+	}
+	// frames reads until the close frame WritePump sends once Send is closed
+	frames := func(conn *websocket.Conn) []string {
+		var got []string
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				require.True(t, websocket.IsCloseError(err, websocket.CloseNoStatusReceived), "got %v", err)
+				return got
+			}
+			got = append(got, string(data))
+		}
+	}
+
+	t.Run("one frame per message", func(t *testing.T) {
+		server, client, _ := connPair(t)
+		c := &Client{Conn: server, Send: make(chan *WSMessage, 4)}
+		queue(c, "a", "b", "c")
+		c.WritePump()
+
+		got := frames(client)
+		require.Len(t, got, 3)
+		assert.Contains(t, got[0], `"message":"a"`)
+	})
+
+	t.Run("batches the backlog", func(t *testing.T) {
+		server, client, _ := connPair(t)
+		c := &Client{Conn: server, Batch: true, Send: make(chan *WSMessage, 4)}
+		queue(c, "a", "b", "c")
+		c.WritePump()
+
+		got := frames(client)
+		require.Len(t, got, 1)
+		var batch []WSMessage
+		require.NoError(t, json.Unmarshal([]byte(got[0]), &batch))
+		assert.Len(t, batch, 3)
+	})
+
+	t.Run("compresses frames above the threshold", func(t *testing.T) {
+		// wireSize sends one message and returns its size decoded and on the wire
+		wireSize := func(message *WSMessage) (int, int64) {
+			server, client, wire := connPair(t)
+			handshake := wire.Load()
+			c := &Client{Conn: server, Compression: DefaultCompression(), Send: make(chan *WSMessage, 1)}
+			require.NoError(t, server.SetCompressionLevel(c.Compression.Level))
+			c.Send <- message
+			close(c.Send)
+			go c.WritePump()
+
+			got := frames(client)
+			require.Len(t, got, 1)
+			return len(got[0]), wire.Load() - handshake
+		}
+		alice := api.User{Id: "3d420673-d96f-43b0-9795-c4e68d2c45d7", Username: "alice"}
+
+		size, onWire := wireSize(NewVideoStateEvent(1234.567, true, 1, alice.Id))
+		assert.Greater(t, onWire, int64(size), "small frames go out as is")
+
+		// Level 1 sends this one stored, 6 bytes larger than plain
+		size, onWire = wireSize(NewChatMessageEvent(alice, "前方高能！！！"))
+		assert.Less(t, onWire, int64(size), "a single chat message is worth deflating")
+
+		size, onWire = wireSize(NewErrorEvent("TEST", strings.Repeat("一起看电影吧！", 100)))
+		assert.Less(t, onWire, int64(size/4))
+	})
+}
+
+func TestHandleVideoChange(t *testing.T) {
+	hub := NewHub()
+	hub.broadcast = make(chan *BroadcastMessage, 1)
+	client := &Client{
+		ID:     "client-1",
+		UserID: "user-1",
+		RoomID: "room-1",
+		IsHost: true,
+		Hub:    hub,
+		Send:   make(chan *WSMessage, 1),
+	}
+
+	client.handleMessage(&ClientMessage{Type: EventVideoChange, Payload: json.RawMessage(`{"videoId":"BV1xx"}`)})
+	payload := (<-hub.broadcast).Message.Payload.(VideoChangedPayload)
+	assert.Equal(t, "BV1xx", payload.Video.Id)
+	assert.Equal(t, "user-1", payload.ChangedBy)
+	assert.Empty(t, client.Send, "no error is sent back")
+}
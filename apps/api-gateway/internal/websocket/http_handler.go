@@ -2,103 +2,162 @@
 package websocket
 
 import (
-	"log"
+	"context"
+	"errors"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+	"github.com/yourusername/cowatch/api-gateway/internal/auth"
+	"github.com/yourusername/cowatch/api-gateway/internal/cors"
+	"github.com/yourusername/cowatch/api-gateway/internal/logging"
 	"github.com/yourusername/cowatch/api-gateway/internal/models"
+	"github.com/yourusername/cowatch/api-gateway/internal/repository"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins in development
-		// TODO: Restrict in production
-		return true
-	},
-}
+// RecentMessageLimit is the number of chat messages sent in room:init
+const RecentMessageLimit = 50
 
 // HTTPHandler handles WebSocket HTTP connections
 type HTTPHandler struct {
-	Hub       *Hub
-	DB        *gorm.DB
-	JWTSecret string
+	Hub     *Hub
+	DB      *gorm.DB
+	Users   repository.UserRepository
+	Rooms   repository.RoomRepository
+	Members repository.MembershipRepository
+	Tokens  *auth.TokenService
+
+	// AllowLegacyTokenAuth accepts JWTs in ?token= in addition to tickets
+	AllowLegacyTokenAuth bool
+
+	// Limits apply to every connection
+	Limits Limits
+
+	// Origins lists the cross-origin pages allowed to connect; nil allows same-origin pages only.
+	// Browsers attach credentials to WebSocket handshakes, so accepting any origin would let
+	// other sites act as the visitor.
+	Origins *cors.Policy
+
+	// Compression is offered to clients that support permessage-deflate
+	Compression Compression
 }
 
 // NewHTTPHandler creates a new WebSocket HTTP handler
-func NewHTTPHandler(hub *Hub, db *gorm.DB, jwtSecret string) *HTTPHandler {
+func NewHTTPHandler(hub *Hub, db *gorm.DB, tokens *auth.TokenService) *HTTPHandler {
+	repos := repository.NewGorm(db)
 	return &HTTPHandler{
-		Hub:       hub,
-		DB:        db,
-		JWTSecret: jwtSecret,
+		Hub:         hub,
+		DB:          db,
+		Users:       repos.Users,
+		Rooms:       repos.Rooms,
+		Members:     repos.Members,
+		Tokens:      tokens,
+		Limits:      DefaultLimits(),
+		Compression: DefaultCompression(),
+	}
+}
+
+// upgrade completes the WebSocket handshake, negotiating the subprotocol and compression
+func (h *HTTPHandler) upgrade(c *gin.Context) (*websocket.Conn, error) {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		CheckOrigin:       h.checkOrigin,
+		Subprotocols:      Subprotocols,
+		EnableCompression: h.Compression.Enabled,
+	}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return nil, err
 	}
+	if h.Compression.Enabled {
+		if err := conn.SetCompressionLevel(h.Compression.Level); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// checkOrigin applies the origin allowlist to a handshake
+func (h *HTTPHandler) checkOrigin(r *http.Request) bool {
+	if h.Origins == nil {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	return h.Origins.CheckOrigin(r)
 }
 
 // HandleWebSocket upgrades HTTP connection to WebSocket
 func (h *HTTPHandler) HandleWebSocket(c *gin.Context) {
 	roomCode := c.Param("roomCode")
 
-	// Authenticate user from query param token (WebSocket can't use Authorization header easily)
-	token := c.Query("token")
-	if token == "" {
-		// Also check Authorization header as fallback
-		authHeader := c.GetHeader("Authorization")
-		if authHeader != "" {
-			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
-				token = parts[1]
-			}
-		}
+	// Reject cross-site pages before they can spend a ticket
+	if !h.checkOrigin(c.Request) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不允许的来源"})
+		return
 	}
 
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+	// Authenticate with a single-use ticket, or a JWT in legacy mode
+	id, ok := h.authenticate(c)
+	if !ok {
 		return
 	}
 
-	// Parse JWT token
-	claims := &Claims{}
-	parsedToken, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(h.JWTSecret), nil
-	})
-	if err != nil || !parsedToken.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的认证令牌"})
+	// Find room by code
+	ctx := c.Request.Context()
+	room, err := h.Rooms.GetByCode(ctx, roomCode, true)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "房间不存在"})
 		return
 	}
 
-	// Load user from database
-	var user models.User
-	if err := h.DB.First(&user, "id = ?", claims.UserID).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+	// Tickets and guest tokens are only valid for the room they were issued for
+	if id.RoomID != "" && id.RoomID != room.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "票据与房间不匹配"})
 		return
 	}
 
-	// Find room by code
-	var room models.Room
-	if err := h.DB.Preload("Owner").First(&room, "code = ? AND is_active = ?", roomCode, true).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "房间不存在"})
+	if id.Guest {
+		h.connectGuest(c, id.UserID, room)
+		return
+	}
+
+	// Load user from database
+	user, err := h.Users.GetByID(ctx, id.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
 		return
 	}
 
 	// Check if user is a member of the room
-	var member models.RoomMember
-	if err := h.DB.First(&member, "room_id = ? AND user_id = ?", room.ID, user.ID).Error; err != nil {
+	member, err := h.Members.Get(ctx, room.ID, user.ID)
+	if errors.Is(err, repository.ErrNotFound) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "你不是该房间的成员"})
 		return
 	}
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to load room membership", "room_id", room.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
 
 	// Upgrade to WebSocket
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := h.upgrade(c)
 	if err != nil {
-		log.Printf("[WebSocket] Failed to upgrade connection: %v", err)
+		logging.FromContext(ctx).Warn("WebSocket upgrade failed", "room_id", room.ID, "error", err)
 		return
 	}
 
@@ -117,30 +176,178 @@ func (h *HTTPHandler) HandleWebSocket(c *gin.Context) {
 		IsHost:               isHost,
 		HasControlPermission: hasControlPermission,
 		Conn:                 conn,
-		Send:                 make(chan *WSMessage, 256),
+		Send:                 make(chan *WSMessage, h.Limits.SendBuffer),
 		Hub:                  h.Hub,
 		DB:                   h.DB,
+		Rooms:                h.Rooms,
+		Limits:               h.Limits,
+		RequestID:            logging.RequestID(ctx),
+		Codec:                CodecFor(conn.Subprotocol()),
+		Batch:                Batches(conn.Subprotocol()),
+		Compression:          h.Compression,
+		handshake:            trace.SpanContextFromContext(ctx),
 	}
 
 	// Register client with hub
 	h.Hub.register <- client
 
-	// Update last visited time
-	h.DB.Model(&member).Update("last_visited_at", time.Now())
+	// Update last visited time; the connection works without it, so failures are only logged
+	now := time.Now()
+	if _, err := h.Members.Visit(ctx, room.ID, user.ID, now); err != nil {
+		client.logger().Warn("Failed to record room visit", "error", err)
+	}
+	if err := h.Rooms.Touch(ctx, room.ID, now); err != nil {
+		client.logger().Warn("Failed to record room activity", "error", err)
+	}
+
+	h.start(client, room)
+}
+
+// connectGuest upgrades the connection for an anonymous room guest.
+// Guests never get control permission and are not recorded as room members.
+func (h *HTTPHandler) connectGuest(c *gin.Context, guestID string, room *models.Room) {
+	guest, err := auth.LoadGuest(h.DB, guestID, room.ID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "访客身份已失效"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	conn, err := h.upgrade(c)
+	if err != nil {
+		logging.FromContext(ctx).Warn("WebSocket upgrade failed", "room_id", room.ID, "error", err)
+		return
+	}
 
+	client := &Client{
+		ID:          uuid.New().String(),
+		RoomID:      room.ID,
+		RoomCode:    room.Code,
+		UserID:      guest.ID,
+		Username:    guest.Nickname,
+		IsGuest:     true,
+		Conn:        conn,
+		Send:        make(chan *WSMessage, h.Limits.SendBuffer),
+		Hub:         h.Hub,
+		DB:          h.DB,
+		Rooms:       h.Rooms,
+		Limits:      h.Limits,
+		RequestID:   logging.RequestID(ctx),
+		Codec:       CodecFor(conn.Subprotocol()),
+		Batch:       Batches(conn.Subprotocol()),
+		Compression: h.Compression,
+		handshake:   trace.SpanContextFromContext(ctx),
+	}
+
+	h.Hub.register <- client
+	if err := h.Rooms.Touch(ctx, room.ID, time.Now()); err != nil {
+		client.logger().Warn("Failed to record room activity", "error", err)
+	}
+	h.start(client, room)
+}
+
+// start sends the room state to a registered client and runs its pumps
+func (h *HTTPHandler) start(client *Client, room *models.Room) {
 	// Send room init event to the client
-	go h.sendRoomInit(client, &room)
+	go h.sendRoomInit(client, room)
 
 	// Start pumps
 	go client.WritePump()
 	go client.ReadPump()
 }
 
+// identity is who is connecting. RoomID is set when the credential is bound to a room.
+type identity struct {
+	UserID string
+	RoomID string
+	Guest  bool
+}
+
+// authenticate resolves the connecting user or guest, writing an error response on failure
+func (h *HTTPHandler) authenticate(c *gin.Context) (identity, bool) {
+	if ticket := c.Query("ticket"); ticket != "" {
+		record, err := consumeTicket(h.DB, ticket)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效或已过期的连接票据"})
+			return identity{}, false
+		}
+		return identity{UserID: record.UserID, RoomID: record.RoomID, Guest: record.IsGuest}, true
+	}
+
+	if !h.AllowLegacyTokenAuth {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "缺少连接票据"})
+		return identity{}, false
+	}
+
+	// Legacy mode: token in query param (WebSocket can't use Authorization header easily)
+	token := c.Query("token")
+	if token == "" {
+		// Also check Authorization header as fallback
+		authHeader := c.GetHeader("Authorization")
+		if authHeader != "" {
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+				token = parts[1]
+			}
+		}
+	}
+
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return identity{}, false
+	}
+
+	// Same validation as the REST middleware: pinned algorithm, issuer, audience and key ID
+	claims, err := h.Tokens.Parse(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的认证令牌"})
+		return identity{}, false
+	}
+
+	// Guest tokens are checked in connectGuest once the room is known
+	if claims.Guest {
+		return identity{UserID: claims.UserID, RoomID: claims.RoomID, Guest: true}, true
+	}
+
+	// Reject tokens whose session was revoked by logout
+	if _, err := auth.LoadActiveSession(h.DB, claims); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已失效，请重新登录"})
+		return identity{}, false
+	}
+
+	return identity{UserID: claims.UserID}, true
+}
+
+// consumeTicket marks a ticket used and returns it, failing if it is unknown, expired or already used
+func consumeTicket(db *gorm.DB, ticket string) (*models.WSTicket, error) {
+	now := time.Now()
+	hash := models.HashOpaqueToken(ticket)
+
+	// The conditional update makes each ticket usable exactly once, even across replicas
+	result := db.Model(&models.WSTicket{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var record models.WSTicket
+	if err := db.First(&record, "token_hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
 // sendRoomInit sends the room initialization event to a newly connected client
 func (h *HTTPHandler) sendRoomInit(client *Client, room *models.Room) {
 	// Get all room members
-	var members []models.RoomMember
-	h.DB.Preload("User").Where("room_id = ?", room.ID).Find(&members)
+	members, err := h.Members.ListByRoom(context.Background(), room.ID)
+	if err != nil {
+		client.logger().Error("Failed to load room members", "error", err)
+	}
 
 	// Get online clients in this room
 	onlineUserIDs := h.Hub.GetOnlineUserIDs(room.ID)
@@ -177,33 +384,46 @@ func (h *HTTPHandler) sendRoomInit(client *Client, room *models.Room) {
 		})
 	}
 
-	// For now, use empty recent messages and default video state
-	// TODO: Implement message history storage
-	recentMessages := []Message{}
+	// Guests have no membership rows, so they're listed only while connected
+	for _, guest := range h.Hub.GetOnlineGuests(room.ID) {
+		participants = append(participants, RoomParticipant{
+			ID:       guest.UserID,
+			Username: guest.Username,
+			IsOnline: true,
+			Role:     "guest",
+		})
+	}
 
-	videoState := VideoState{
-		CurrentTime:  0,
-		IsPlaying:    false,
-		PlaybackRate: 1.0,
-		Volume:       1.0,
+	// Load the most recent chat history, oldest first
+	var history []models.ChatMessage
+	h.DB.Where("room_id = ?", room.ID).
+		Order("created_at DESC").
+		Limit(RecentMessageLimit).
+		Find(&history)
+
+	recentMessages := make([]Message, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		m := history[i]
+		recentMessages = append(recentMessages, Message{
+			ID: m.ID,
+			User: api.User{
+				Id:       m.UserID,
+				Username: m.Username,
+			},
+			Content:   m.Content,
+			Timestamp: m.CreatedAt.UnixMilli(),
+		})
 	}
 
+	videoState := h.Hub.GetVideoState(room.ID)
+
 	// Send room init event
 	initEvent := NewRoomInitEvent(participants, recentMessages, videoState)
-	select {
-	case client.Send <- initEvent:
-	default:
-		log.Printf("[WebSocket] Failed to send room init to client %s", client.ID)
+	if !client.queue(initEvent) {
+		client.logger().Warn("Send queue full or closed, dropped room init")
 	}
 }
 
-// Claims represents JWT claims
-type Claims struct {
-	UserID   string `json:"userId"`
-	Username string `json:"username"`
-	jwt.RegisteredClaims
-}
-
 // getAvatarURL safely dereferences a string pointer
 func getAvatarURL(ptr *string) string {
 	if ptr == nil {
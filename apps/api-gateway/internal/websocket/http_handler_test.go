@@ -0,0 +1,348 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/auth"
+	"github.com/yourusername/cowatch/api-gateway/internal/cors"
+	"github.com/yourusername/cowatch/api-gateway/internal/models"
+)
+
+const testJWTSecret = "test-secret-key"
+
+func newTestTokenService(t testing.TB, audience string) *auth.TokenService {
+	tokens, err := auth.NewTokenService(auth.Config{
+		Secrets:     map[string]string{"test": testJWTSecret},
+		ActiveKeyID: "test",
+		Issuer:      "cowatch-test",
+		Audience:    audience,
+		AccessTTL:   time.Minute,
+	})
+	require.NoError(t, err)
+	return tokens
+}
+
+func setupTestHandler(t testing.TB) (*HTTPHandler, *httptest.Server) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	// Each connection to :memory: is a separate database, so pin the pool to one
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(
+		&models.User{},
+		&models.Room{},
+		&models.RoomMember{},
+		&models.ChatMessage{},
+		&models.AuthSession{},
+		&models.WSTicket{},
+		&models.RoomGuest{},
+	))
+
+	hub := NewHub()
+	go hub.Run()
+
+	handler := NewHTTPHandler(hub, db, newTestTokenService(t, "cowatch"))
+	router := gin.New()
+	router.GET("/ws/rooms/:roomCode", handler.HandleWebSocket)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return handler, server
+}
+
+func createTicket(t testing.TB, db *gorm.DB, userID, roomID string, expiresAt time.Time) string {
+	ticket, hash, err := models.NewOpaqueToken()
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.WSTicket{
+		TokenHash: hash,
+		UserID:    userID,
+		RoomID:    roomID,
+		ExpiresAt: expiresAt,
+	}).Error)
+	return ticket
+}
+
+func dial(t testing.TB, server *httptest.Server, path string) (*websocket.Conn, *http.Response, error) {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + path
+	return websocket.DefaultDialer.Dial(url, nil)
+}
+
+func TestHandleWebSocketTickets(t *testing.T) {
+	handler, server := setupTestHandler(t)
+	db := handler.DB
+
+	user := models.User{Username: "wsuser", PasswordHash: "x"}
+	require.NoError(t, db.Create(&user).Error)
+
+	room := models.Room{Name: "WS Room", OwnerID: user.ID, IsActive: true}
+	other := models.Room{Name: "Other Room", OwnerID: user.ID, IsActive: true}
+	require.NoError(t, db.Create(&room).Error)
+	require.NoError(t, db.Create(&other).Error)
+	require.NoError(t, db.Create(&models.RoomMember{RoomID: room.ID, UserID: user.ID}).Error)
+	require.NoError(t, db.Create(&models.RoomMember{RoomID: other.ID, UserID: user.ID}).Error)
+
+	t.Run("valid ticket connects once", func(t *testing.T) {
+		ticket := createTicket(t, db, user.ID, room.ID, time.Now().Add(30*time.Second))
+
+		conn, _, err := dial(t, server, "/ws/rooms/"+room.Code+"?ticket="+ticket)
+		require.NoError(t, err)
+		conn.Close()
+
+		_, resp, err := dial(t, server, "/ws/rooms/"+room.Code+"?ticket="+ticket)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("expired ticket is rejected", func(t *testing.T) {
+		ticket := createTicket(t, db, user.ID, room.ID, time.Now().Add(-time.Second))
+
+		_, resp, err := dial(t, server, "/ws/rooms/"+room.Code+"?ticket="+ticket)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("ticket is bound to its room", func(t *testing.T) {
+		ticket := createTicket(t, db, user.ID, other.ID, time.Now().Add(30*time.Second))
+
+		_, resp, err := dial(t, server, "/ws/rooms/"+room.Code+"?ticket="+ticket)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("token auth is disabled by default", func(t *testing.T) {
+		_, resp, err := dial(t, server, "/ws/rooms/"+room.Code+"?token=anything")
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestHandleWebSocketLegacyToken(t *testing.T) {
+	handler, server := setupTestHandler(t)
+	handler.AllowLegacyTokenAuth = true
+	db := handler.DB
+
+	user := models.User{Username: "legacyuser", PasswordHash: "x"}
+	require.NoError(t, db.Create(&user).Error)
+	room := models.Room{Name: "Legacy Room", OwnerID: user.ID, IsActive: true}
+	require.NoError(t, db.Create(&room).Error)
+	require.NoError(t, db.Create(&models.RoomMember{RoomID: room.ID, UserID: user.ID}).Error)
+
+	session := models.AuthSession{UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, db.Create(&session).Error)
+
+	t.Run("token from the shared service connects", func(t *testing.T) {
+		token, err := handler.Tokens.Issue(&user, session.ID)
+		require.NoError(t, err)
+
+		conn, _, err := dial(t, server, "/ws/rooms/"+room.Code+"?token="+token)
+		require.NoError(t, err)
+		conn.Close()
+	})
+
+	t.Run("token for another audience is rejected", func(t *testing.T) {
+		token, err := newTestTokenService(t, "someone-else").Issue(&user, session.ID)
+		require.NoError(t, err)
+
+		_, resp, err := dial(t, server, "/ws/rooms/"+room.Code+"?token="+token)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestHandleWebSocketGuest(t *testing.T) {
+	handler, server := setupTestHandler(t)
+	db := handler.DB
+
+	host := models.User{Username: "guesthost", PasswordHash: "x"}
+	require.NoError(t, db.Create(&host).Error)
+	room := models.Room{Name: "Guest Room", OwnerID: host.ID, IsActive: true, AllowGuests: true}
+	require.NoError(t, db.Create(&room).Error)
+	require.NoError(t, db.Create(&models.RoomMember{RoomID: room.ID, UserID: host.ID}).Error)
+
+	guest := models.RoomGuest{RoomID: room.ID, Nickname: "路人甲", ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, db.Create(&guest).Error)
+
+	guestTicket := func(t *testing.T) string {
+		ticket, hash, err := models.NewOpaqueToken()
+		require.NoError(t, err)
+		require.NoError(t, db.Create(&models.WSTicket{
+			TokenHash: hash,
+			UserID:    guest.ID,
+			RoomID:    room.ID,
+			IsGuest:   true,
+			ExpiresAt: time.Now().Add(30 * time.Second),
+		}).Error)
+		return ticket
+	}
+
+	t.Run("guest is listed and can't control playback", func(t *testing.T) {
+		conn, _, err := dial(t, server, "/ws/rooms/"+room.Code+"?ticket="+guestTicket(t))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		var init struct {
+			Type    string          `json:"type"`
+			Payload RoomInitPayload `json:"payload"`
+		}
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		for init.Type != EventRoomInit {
+			require.NoError(t, conn.ReadJSON(&init))
+		}
+
+		var found *RoomParticipant
+		for i, p := range init.Payload.Participants {
+			if p.ID == guest.ID {
+				found = &init.Payload.Participants[i]
+			}
+		}
+		require.NotNil(t, found, "guest should be in the participant list")
+		assert.Equal(t, "guest", found.Role)
+		assert.Equal(t, "路人甲", found.Username)
+		assert.False(t, found.HasControlPermission)
+
+		require.NoError(t, conn.WriteJSON(NewMessage(EventVideoPlay, VideoControlPayload{})))
+		var reply struct {
+			Type    string       `json:"type"`
+			Payload ErrorPayload `json:"payload"`
+		}
+		for reply.Type != EventError {
+			require.NoError(t, conn.ReadJSON(&reply))
+		}
+		assert.Equal(t, "UNAUTHORIZED", reply.Payload.Code)
+	})
+
+	t.Run("guest is rejected once guests are disabled", func(t *testing.T) {
+		require.NoError(t, db.Model(&room).Update("allow_guests", false).Error)
+
+		_, resp, err := dial(t, server, "/ws/rooms/"+room.Code+"?ticket="+guestTicket(t))
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestHandleWebSocketLimits(t *testing.T) {
+	handler, server := setupTestHandler(t)
+	handler.Limits.MaxMessageSize = 512
+	handler.Limits.PongTimeout = time.Minute
+	db := handler.DB
+
+	user := models.User{Username: "limituser", PasswordHash: "x"}
+	require.NoError(t, db.Create(&user).Error)
+	room := models.Room{Name: "Limit Room", OwnerID: user.ID, IsActive: true}
+	require.NoError(t, db.Create(&room).Error)
+	require.NoError(t, db.Create(&models.RoomMember{RoomID: room.ID, UserID: user.ID}).Error)
+
+	ticket := createTicket(t, db, user.ID, room.ID, time.Now().Add(30*time.Second))
+	conn, _, err := dial(t, server, "/ws/rooms/"+room.Code+"?ticket="+ticket)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// An oversized message closes the connection instead of being buffered
+	big := `{"type":"chat:message","payload":{"content":"` + strings.Repeat("x", 1024) + `"}}`
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(big)))
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) {
+		assert.False(t, netErr.Timeout(), "the server should have closed the connection")
+	}
+}
+
+func TestHandleWebSocketOrigins(t *testing.T) {
+	handler, server := setupTestHandler(t)
+	db := handler.DB
+
+	user := models.User{Username: "originuser", PasswordHash: "x"}
+	require.NoError(t, db.Create(&user).Error)
+	room := models.Room{Name: "Origin Room", OwnerID: user.ID, IsActive: true}
+	require.NoError(t, db.Create(&room).Error)
+	require.NoError(t, db.Create(&models.RoomMember{RoomID: room.ID, UserID: user.ID}).Error)
+
+	dialFrom := func(origin string) (*websocket.Conn, *http.Response, string, error) {
+		ticket := createTicket(t, db, user.ID, room.ID, time.Now().Add(30*time.Second))
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/rooms/" + room.Code + "?ticket=" + ticket
+		conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+		return conn, resp, ticket, err
+	}
+
+	t.Run("cross-site pages are refused without spending the ticket", func(t *testing.T) {
+		_, resp, ticket, err := dialFrom("https://attacker.dev")
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		var count int64
+		db.Model(&models.WSTicket{}).Where("token_hash = ? AND used_at IS NULL", models.HashOpaqueToken(ticket)).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("same-origin pages connect", func(t *testing.T) {
+		conn, _, _, err := dialFrom(server.URL)
+		require.NoError(t, err)
+		conn.Close()
+	})
+
+	t.Run("allowlisted origins connect", func(t *testing.T) {
+		policy, err := cors.New(cors.Options{AllowedOrigins: []string{"https://*.example.com"}})
+		require.NoError(t, err)
+		handler.Origins = policy
+		t.Cleanup(func() { handler.Origins = nil })
+
+		conn, _, _, err := dialFrom("https://app.example.com")
+		require.NoError(t, err)
+		conn.Close()
+
+		_, resp, _, err := dialFrom("https://attacker.dev")
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestHubCloseAll(t *testing.T) {
+	handler, server := setupTestHandler(t)
+	db := handler.DB
+
+	user := models.User{Username: "shutdownuser", PasswordHash: "x"}
+	require.NoError(t, db.Create(&user).Error)
+	room := models.Room{Name: "Shutdown Room", OwnerID: user.ID, IsActive: true}
+	require.NoError(t, db.Create(&room).Error)
+	require.NoError(t, db.Create(&models.RoomMember{RoomID: room.ID, UserID: user.ID}).Error)
+
+	ticket := createTicket(t, db, user.ID, room.ID, time.Now().Add(30*time.Second))
+	conn, _, err := dial(t, server, "/ws/rooms/"+room.Code+"?ticket="+ticket)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Eventually(t, func() bool { return handler.Hub.Stats().Connections == 1 }, time.Second, 10*time.Millisecond)
+	handler.Hub.CloseAll("server shutting down")
+
+	// Clients are told to go elsewhere rather than seeing the connection drop
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+	require.Eventually(t, func() bool { return handler.Hub.Stats().Connections == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,165 @@
+// Code generated by cmd/gen-wsevents from api-specs/asyncapi.yaml. DO NOT EDIT.
+
+package websocket
+
+import "github.com/yourusername/cowatch/api-gateway/internal/api"
+
+// Client event types (客户端发送事件)
+const (
+	// EventVideoPlay 播放视频（需要控制权限）
+	EventVideoPlay = "video:play"
+	// EventVideoPause 暂停视频（需要控制权限）
+	EventVideoPause = "video:pause"
+	// EventVideoSeek 跳转进度并暂停播放（需要控制权限）
+	EventVideoSeek = "video:seek"
+	// EventVideoSync 定期上报本地播放状态，转发给房间内其他人；只有拥有播放控制权的用户上报的状态会记为房间状态
+	EventVideoSync = "video:sync"
+	// EventChatMessage 发送聊天消息
+	EventChatMessage = "chat:message"
+	// EventVideoChange 切换视频源（需要控制权限）
+	EventVideoChange = "video:change"
+)
+
+// Server event types (服务端推送事件)
+const (
+	// EventRoomInit 连接建立后立即推送，包含房间的完整初始状态
+	EventRoomInit = "room:init"
+	// EventUserJoined 用户加入
+	EventUserJoined = "user:joined"
+	// EventUserLeft 用户离开
+	EventUserLeft = "user:left"
+	// EventVideoState 房主/授权用户操作后广播的视频状态
+	EventVideoState = "video:state"
+	// EventChatBroadcast 聊天消息广播
+	EventChatBroadcast = "chat:message"
+	// EventVideoChanged 视频源已变更
+	EventVideoChanged = "video:changed"
+	// EventError 只发给出错的客户端，错误码见 websocket.md
+	EventError = "error"
+)
+
+// ClientEventPayloads maps each client event to the payload type it decodes into
+var ClientEventPayloads = map[string]any{
+	EventVideoPlay:   VideoControlPayload{},
+	EventVideoPause:  VideoControlPayload{},
+	EventVideoSeek:   VideoSeekPayload{},
+	EventVideoSync:   VideoSyncPayload{},
+	EventChatMessage: ChatMessagePayload{},
+	EventVideoChange: VideoChangePayload{},
+}
+
+// ServerEventPayloads maps each server event to the payload type it carries
+var ServerEventPayloads = map[string]any{
+	EventRoomInit:      RoomInitPayload{},
+	EventUserJoined:    UserJoinedPayload{},
+	EventUserLeft:      UserLeftPayload{},
+	EventVideoState:    VideoStatePayload{},
+	EventChatBroadcast: ChatMessageBroadcastPayload{},
+	EventVideoChanged:  VideoChangedPayload{},
+	EventError:         ErrorPayload{},
+}
+
+// ChatMessageBroadcastPayload defines model for ChatMessageBroadcastPayload.
+type ChatMessageBroadcastPayload struct {
+	User    api.User `json:"user"`
+	Message string   `json:"message"`
+	// Timestamp 发送时间（毫秒）
+	Timestamp int64 `json:"timestamp"`
+}
+
+// ChatMessagePayload defines model for ChatMessagePayload.
+type ChatMessagePayload struct {
+	// Message 去掉首尾空白后为1-1000个字符
+	Message string `json:"message"`
+}
+
+// ErrorPayload defines model for ErrorPayload.
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Message 房间历史中的一条聊天消息
+type Message struct {
+	ID      string   `json:"id"`
+	User    api.User `json:"user"`
+	Content string   `json:"content"`
+	// Timestamp 发送时间（毫秒）
+	Timestamp int64 `json:"timestamp"`
+}
+
+// RoomInitPayload defines model for RoomInitPayload.
+type RoomInitPayload struct {
+	Participants   []RoomParticipant `json:"participants"`
+	RecentMessages []Message         `json:"recentMessages"`
+	VideoState     VideoState        `json:"videoState"`
+}
+
+// RoomParticipant defines model for RoomParticipant.
+type RoomParticipant struct {
+	ID        string  `json:"id"`
+	Username  string  `json:"username"`
+	AvatarURL *string `json:"avatarUrl,omitempty"`
+	IsOnline  bool    `json:"isOnline"`
+	// Role host、member 或 guest；访客只在在线时出现
+	Role                 string `json:"role"`
+	HasControlPermission bool   `json:"hasControlPermission"`
+}
+
+// UserJoinedPayload defines model for UserJoinedPayload.
+type UserJoinedPayload struct {
+	User      api.User `json:"user"`
+	UserCount int      `json:"userCount"`
+}
+
+// UserLeftPayload defines model for UserLeftPayload.
+type UserLeftPayload struct {
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	UserCount int    `json:"userCount"`
+}
+
+// VideoChangePayload defines model for VideoChangePayload.
+type VideoChangePayload struct {
+	VideoID string `json:"videoId"`
+}
+
+// VideoChangedPayload defines model for VideoChangedPayload.
+type VideoChangedPayload struct {
+	Video api.VideoSource `json:"video"`
+	// ChangedBy 切换视频的用户 ID
+	ChangedBy string `json:"changedBy"`
+}
+
+// VideoControlPayload 播放/暂停事件没有内容
+type VideoControlPayload struct{}
+
+// VideoSeekPayload defines model for VideoSeekPayload.
+type VideoSeekPayload struct {
+	// CurrentTime 播放进度（秒）
+	CurrentTime float64 `json:"currentTime"`
+}
+
+// VideoState 房间当前的播放状态
+type VideoState struct {
+	CurrentTime  float64 `json:"currentTime"`
+	IsPlaying    bool    `json:"isPlaying"`
+	PlaybackRate float64 `json:"playbackRate"`
+	Volume       float64 `json:"volume"`
+}
+
+// VideoStatePayload defines model for VideoStatePayload.
+type VideoStatePayload struct {
+	CurrentTime  float64 `json:"currentTime"`
+	IsPlaying    bool    `json:"isPlaying"`
+	PlaybackRate float64 `json:"playbackRate"`
+	// TriggeredBy 触发变更的用户 ID
+	TriggeredBy string `json:"triggeredBy"`
+}
+
+// VideoSyncPayload defines model for VideoSyncPayload.
+type VideoSyncPayload struct {
+	CurrentTime  float64 `json:"currentTime"`
+	IsPlaying    bool    `json:"isPlaying"`
+	PlaybackRate float64 `json:"playbackRate"`
+}
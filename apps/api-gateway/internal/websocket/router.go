@@ -0,0 +1,200 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Permission is what a client needs to send an event
+type Permission int
+
+const (
+	// PermissionNone lets anyone in the room send the event
+	PermissionNone Permission = iota
+	// PermissionControl needs the host or a member granted playback control; guests never have it
+	PermissionControl
+)
+
+// allows reports whether c holds the permission
+func (p Permission) allows(c *Client) bool {
+	switch p {
+	case PermissionNone:
+		return true
+	case PermissionControl:
+		return !c.IsGuest && (c.IsHost || c.HasControlPermission)
+	}
+	return false
+}
+
+// ClientError is reported to the client as an error event. Err is an optional cause that
+// is logged and traced but never sent.
+type ClientError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *ClientError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Code, e.Err)
+	}
+	return e.Code
+}
+
+func (e *ClientError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	errUnknownEvent   = &ClientError{Code: "UNKNOWN_EVENT", Message: "未知的事件类型"}
+	errUnauthorized   = &ClientError{Code: "UNAUTHORIZED", Message: "你没有权限执行此操作"}
+	errInvalidPayload = &ClientError{Code: "INVALID_PAYLOAD", Message: "无效的消息内容"}
+	errRateLimited    = &ClientError{Code: "RATE_LIMITED", Message: "操作过于频繁，请稍后再试"}
+)
+
+// invalidPayload is an INVALID_PAYLOAD error with a specific message for the client
+func invalidPayload(message string) *ClientError {
+	return &ClientError{Code: "INVALID_PAYLOAD", Message: message}
+}
+
+// Event is a client message on its way through the router
+type Event struct {
+	Type    string
+	Client  *Client
+	Payload []byte
+
+	route *route
+}
+
+// Label names the event for metrics and spans. Clients choose the type, so anything
+// unregistered shares one label.
+func (e *Event) Label() string {
+	if e.route == nil {
+		return "unknown"
+	}
+	return e.Type
+}
+
+// Handler handles an event. A *ClientError is sent to the client; any other error is
+// logged and reported as INTERNAL_ERROR.
+type Handler func(ctx context.Context, ev *Event) error
+
+// Middleware wraps every dispatched event, including unknown and unauthorized ones
+type Middleware func(next Handler) Handler
+
+// EventDef describes a client event whose payload decodes into P
+type EventDef[P any] struct {
+	Type       string
+	Permission Permission
+
+	// Validate checks the decoded payload and may normalize it in place; nil accepts
+	// anything that decodes
+	Validate func(payload *P) error
+
+	Handle func(ctx context.Context, c *Client, payload *P) error
+}
+
+type route struct {
+	permission Permission
+	payload    reflect.Type
+	serve      Handler
+}
+
+// Router dispatches client events to their registered handlers through a middleware chain
+type Router struct {
+	routes     map[string]*route
+	middleware []Middleware
+	chain      Handler
+}
+
+// NewRouter returns a Router with no events or middleware
+func NewRouter() *Router {
+	r := &Router{routes: make(map[string]*route)}
+	r.chain = r.serve
+	return r
+}
+
+// Use appends middleware; the first added runs outermost
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+	r.chain = r.serve
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		r.chain = r.middleware[i](r.chain)
+	}
+}
+
+// Register adds an event to r. Registering the same type twice panics.
+func Register[P any](r *Router, def EventDef[P]) {
+	if _, ok := r.routes[def.Type]; ok {
+		panic("websocket: event " + def.Type + " registered twice")
+	}
+	r.routes[def.Type] = &route{
+		permission: def.Permission,
+		payload:    reflect.TypeFor[P](),
+		serve: func(ctx context.Context, ev *Event) error {
+			var payload P
+			if len(ev.Payload) > 0 {
+				if err := ev.Client.codec().DecodePayload(ev.Payload, &payload); err != nil {
+					return errInvalidPayload
+				}
+			}
+			if def.Validate != nil {
+				if err := def.Validate(&payload); err != nil {
+					return err
+				}
+			}
+			return def.Handle(ctx, ev.Client, &payload)
+		},
+	}
+}
+
+// Types lists the registered event types in order
+func (r *Router) Types() []string {
+	types := make([]string, 0, len(r.routes))
+	for eventType := range r.routes {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Dispatch runs msg through the middleware and its handler, reporting any error to the client
+func (r *Router) Dispatch(ctx context.Context, c *Client, msg *ClientMessage) {
+	ev := &Event{
+		Type:    msg.Type,
+		Client:  c,
+		Payload: msg.Payload,
+		route:   r.routes[msg.Type],
+	}
+	if err := r.chain(ctx, ev); err != nil {
+		c.reportError(ev, err)
+	}
+}
+
+// serve checks the event's permission and hands it to its route
+func (r *Router) serve(ctx context.Context, ev *Event) error {
+	if ev.route == nil {
+		return errUnknownEvent
+	}
+	if !ev.route.permission.allows(ev.Client) {
+		return errUnauthorized
+	}
+	return ev.route.serve(ctx, ev)
+}
+
+// reportError sends err to the client as an error event
+func (c *Client) reportError(ev *Event, err error) {
+	var clientErr *ClientError
+	if !errors.As(err, &clientErr) {
+		c.logger().Error("Failed to handle event", "type", ev.Label(), "error", err)
+		c.sendError("INTERNAL_ERROR", "服务器内部错误")
+		return
+	}
+	if clientErr.Err != nil {
+		c.logger().Warn("Event failed", "type", ev.Label(), "code", clientErr.Code, "error", clientErr.Err)
+	}
+	c.sendError(clientErr.Code, clientErr.Message)
+}
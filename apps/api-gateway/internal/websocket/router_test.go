@@ -0,0 +1,238 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/cowatch/api-gateway/internal/api"
+)
+
+// lastError returns the code of the error event queued for c, or "" if there is none
+func lastError(c *Client) string {
+	select {
+	case msg := <-c.Send:
+		if payload, ok := msg.Payload.(ErrorPayload); ok {
+			return payload.Code
+		}
+		return "not an error: " + msg.Type
+	default:
+		return ""
+	}
+}
+
+func TestRouter(t *testing.T) {
+	type echoPayload struct {
+		Text string `json:"text"`
+	}
+
+	var handled []string
+	r := NewRouter()
+	Register(r, EventDef[echoPayload]{
+		Type: "test:echo",
+		Validate: func(p *echoPayload) error {
+			if p.Text == "" {
+				return invalidPayload("empty")
+			}
+			p.Text = "[" + p.Text + "]"
+			return nil
+		},
+		Handle: func(_ context.Context, _ *Client, p *echoPayload) error {
+			handled = append(handled, p.Text)
+			return nil
+		},
+	})
+	Register(r, EventDef[VideoControlPayload]{
+		Type:       "test:control",
+		Permission: PermissionControl,
+		Handle: func(context.Context, *Client, *VideoControlPayload) error {
+			handled = append(handled, "control")
+			return nil
+		},
+	})
+	Register(r, EventDef[VideoControlPayload]{
+		Type: "test:fail",
+		Handle: func(context.Context, *Client, *VideoControlPayload) error {
+			return errors.New("database is down")
+		},
+	})
+
+	member := &Client{ID: "member", Send: make(chan *WSMessage, 1)}
+	host := &Client{ID: "host", IsHost: true, Send: make(chan *WSMessage, 1)}
+	guest := &Client{ID: "guest", IsGuest: true, HasControlPermission: true, Send: make(chan *WSMessage, 1)}
+	send := func(c *Client, eventType, payload string) string {
+		r.Dispatch(context.Background(), c, &ClientMessage{Type: eventType, Payload: json.RawMessage(payload)})
+		return lastError(c)
+	}
+
+	assert.Empty(t, send(member, "test:echo", `{"text":"hi"}`))
+	assert.Equal(t, "INVALID_PAYLOAD", send(member, "test:echo", `{"text":""}`), "validator")
+	assert.Equal(t, "INVALID_PAYLOAD", send(member, "test:echo", `{"text":42}`), "wrong type")
+	assert.Equal(t, "INVALID_PAYLOAD", send(member, "test:echo", ``), "missing payload still validates")
+	assert.Equal(t, "UNKNOWN_EVENT", send(member, "test:nope", `{}`))
+
+	assert.Equal(t, "UNAUTHORIZED", send(member, "test:control", `{}`))
+	assert.Equal(t, "UNAUTHORIZED", send(guest, "test:control", `{}`), "guests never control playback")
+	assert.Empty(t, send(host, "test:control", ``))
+
+	assert.Equal(t, "INTERNAL_ERROR", send(member, "test:fail", `{}`), "unexpected errors aren't leaked")
+
+	assert.Equal(t, []string{"[hi]", "control"}, handled)
+	assert.Equal(t, []string{"test:control", "test:echo", "test:fail"}, r.Types())
+	assert.Panics(t, func() {
+		Register(r, EventDef[echoPayload]{Type: "test:echo"})
+	})
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, ev *Event) error {
+				order = append(order, name+">"+ev.Label())
+				err := next(ctx, ev)
+				order = append(order, name+"<"+errorCode(err))
+				return err
+			}
+		}
+	}
+
+	r := NewRouter()
+	r.Use(trace("outer"), trace("inner"))
+	Register(r, EventDef[VideoControlPayload]{
+		Type:   "test:ok",
+		Handle: func(context.Context, *Client, *VideoControlPayload) error { return nil },
+	})
+
+	c := &Client{Send: make(chan *WSMessage, 1)}
+	r.Dispatch(context.Background(), c, &ClientMessage{Type: "test:ok"})
+	r.Dispatch(context.Background(), c, &ClientMessage{Type: "made-up"})
+
+	assert.Equal(t, []string{
+		"outer>test:ok", "inner>test:ok", "inner<ok", "outer<ok",
+		"outer>unknown", "inner>unknown", "inner<UNKNOWN_EVENT", "outer<UNKNOWN_EVENT",
+	}, order, "middleware sees unknown events too, under one label")
+}
+
+func TestLimitEvents(t *testing.T) {
+	c := &Client{
+		Limits: Limits{EventRate: 0.001, EventBurst: 2},
+		Send:   make(chan *WSMessage, 1),
+	}
+	handler := limitEvents(func(context.Context, *Event) error { return nil })
+	ev := &Event{Type: EventVideoSync, Client: c}
+
+	assert.NoError(t, handler(context.Background(), ev))
+	assert.NoError(t, handler(context.Background(), ev))
+	assert.ErrorIs(t, handler(context.Background(), ev), errRateLimited, "burst used up")
+
+	unlimited := &Client{Send: make(chan *WSMessage, 1)}
+	for range 100 {
+		require.NoError(t, handler(context.Background(), &Event{Client: unlimited}))
+	}
+}
+
+func TestEventValidation(t *testing.T) {
+	hub := NewHub()
+	hub.broadcast = make(chan *BroadcastMessage, 1)
+	c := &Client{ID: "host", UserID: "user-1", RoomID: "room-1", IsHost: true, Hub: hub, Send: make(chan *WSMessage, 1)}
+
+	tests := []struct {
+		eventType, payload string
+		want               string
+	}{
+		{EventVideoSeek, `{"currentTime":-1}`, "INVALID_PAYLOAD"},
+		{EventVideoSync, `{"currentTime":10,"isPlaying":true,"playbackRate":0}`, "INVALID_PAYLOAD"},
+		{EventVideoSync, `{"currentTime":10,"isPlaying":true,"playbackRate":100}`, "INVALID_PAYLOAD"},
+		{EventVideoChange, `{"videoId":"   "}`, "INVALID_PAYLOAD"},
+		{EventVideoSync, `{"currentTime":10,"isPlaying":true,"playbackRate":1.5}`, ""},
+	}
+	for _, tt := range tests {
+		c.handleMessage(&ClientMessage{Type: tt.eventType, Payload: json.RawMessage(tt.payload)})
+		assert.Equal(t, tt.want, lastError(c), "%s %s", tt.eventType, tt.payload)
+	}
+	assert.Equal(t, 1.5, (<-hub.broadcast).Message.Payload.(VideoStatePayload).PlaybackRate)
+}
+
+func TestVideoStateEvents(t *testing.T) {
+	hub := NewHub()
+	hub.broadcast = make(chan *BroadcastMessage, 1)
+	c := &Client{ID: "host", UserID: "user-1", RoomID: "room-1", IsHost: true, Hub: hub, Send: make(chan *WSMessage, 1)}
+
+	send := func(eventType, payload string) VideoStatePayload {
+		c.handleMessage(&ClientMessage{Type: eventType, Payload: json.RawMessage(payload)})
+		require.Empty(t, lastError(c))
+		return (<-hub.broadcast).Message.Payload.(VideoStatePayload)
+	}
+
+	assert.True(t, send(EventVideoPlay, `{}`).IsPlaying)
+
+	seek := send(EventVideoSeek, `{"currentTime":42}`)
+	assert.Equal(t, 42.0, seek.CurrentTime)
+	assert.False(t, seek.IsPlaying, "seeking pauses playback")
+	assert.False(t, hub.IsPlaying("room-1"))
+}
+
+func TestVideoSyncStoresControllerState(t *testing.T) {
+	hub := NewHub()
+	hub.broadcast = make(chan *BroadcastMessage, 1)
+	host := &Client{ID: "host", UserID: "user-1", RoomID: "room-1", IsHost: true, Hub: hub, Send: make(chan *WSMessage, 1)}
+	guest := &Client{ID: "guest", UserID: "guest-1", RoomID: "room-1", IsGuest: true, Hub: hub, Send: make(chan *WSMessage, 1)}
+
+	sync := func(c *Client, payload string) {
+		c.handleMessage(&ClientMessage{Type: EventVideoSync, Payload: json.RawMessage(payload)})
+		require.Empty(t, lastError(c))
+		<-hub.broadcast
+	}
+
+	sync(host, `{"currentTime":30,"isPlaying":true,"playbackRate":1}`)
+	assert.Equal(t, 30.0, hub.GetVideoState("room-1").CurrentTime)
+
+	// Other reports are still relayed, but don't overwrite the room's state
+	sync(guest, `{"currentTime":500,"isPlaying":false,"playbackRate":2}`)
+	state := hub.GetVideoState("room-1")
+	assert.Equal(t, 30.0, state.CurrentTime)
+	assert.True(t, state.IsPlaying)
+	assert.Equal(t, 1.0, state.PlaybackRate)
+}
+
+// The registry must handle exactly the client events in api-specs/asyncapi.yaml, each
+// with the payload type the spec gives it
+func TestEventsMatchSpec(t *testing.T) {
+	spec := make(map[string]reflect.Type, len(ClientEventPayloads))
+	for eventType, payload := range ClientEventPayloads {
+		spec[eventType] = reflect.TypeOf(payload)
+	}
+	registered := make(map[string]reflect.Type)
+	for _, eventType := range events.Types() {
+		registered[eventType] = events.routes[eventType].payload
+	}
+	assert.Equal(t, spec, registered)
+}
+
+// Every server event in the spec has a constructor sending its payload type
+func TestServerEventsMatchSpec(t *testing.T) {
+	sent := []*WSMessage{
+		NewRoomInitEvent(nil, nil, VideoState{}),
+		NewUserJoinedEvent(api.User{}, 1),
+		NewUserLeftEvent("user-1", "alice", 0),
+		NewVideoStateEvent(0, true, 1, "user-1"),
+		NewChatMessageEvent(api.User{}, "hi"),
+		NewVideoChangedEvent(api.VideoSource{}, "user-1"),
+		NewErrorEvent("INTERNAL_ERROR", "服务器内部错误"),
+	}
+	spec := make(map[string]reflect.Type, len(ServerEventPayloads))
+	for eventType, payload := range ServerEventPayloads {
+		spec[eventType] = reflect.TypeOf(payload)
+	}
+	constructed := make(map[string]reflect.Type, len(sent))
+	for _, msg := range sent {
+		constructed[msg.Type] = reflect.TypeOf(msg.Payload)
+	}
+	assert.Equal(t, spec, constructed)
+}
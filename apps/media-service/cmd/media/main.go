@@ -1,5 +1,51 @@
 package main
 
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+
+	"github.com/yourusername/cowatch/media-service/internal/middleware"
+	"github.com/yourusername/cowatch/pkg/authtoken"
+)
+
 func main() {
-	// TODO: Initialize Media Service
+	// .env is optional; real deployments set the environment directly
+	_ = godotenv.Load()
+
+	verifier, err := authtoken.NewVerifier(authtoken.VerifierConfig{
+		JWKSURL:  getenv("GATEWAY_JWKS_URL", "http://localhost:8080/.well-known/jwks.json"),
+		Issuer:   getenv("JWT_ISSUER", "cowatch-api"),
+		Audience: getenv("JWT_AUDIENCE", "cowatch"),
+	})
+	if err != nil {
+		log.Fatalf("auth: %v", err)
+	}
+
+	r := gin.Default()
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// Everything else needs a gateway access token or a URL signed by the gateway.
+	// The parser and proxy handlers are registered on this group.
+	api := r.Group("/api/v1")
+	api.Use(middleware.AuthMiddleware(verifier))
+
+	addr := ":" + getenv("PORT", "8081")
+	log.Printf("media service listening on %s", addr)
+	if err := r.Run(addr); err != nil {
+		log.Fatalf("server: %v", err)
+	}
+}
+
+// getenv returns the environment variable key, or fallback when it is unset
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
 }
@@ -0,0 +1,53 @@
+// Package middleware provides HTTP middleware for the media service
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourusername/cowatch/pkg/authtoken"
+)
+
+// ClaimsContextKey is where the verified gateway token claims are stored
+const ClaimsContextKey = "claims"
+
+// AuthMiddleware verifies gateway access tokens against the gateway's JWKS.
+// Video elements can't set headers, so proxy URLs are instead signed by the gateway for one
+// path (?sig=); access tokens are never accepted in the query string.
+func AuthMiddleware(verifier *authtoken.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var claims *authtoken.Claims
+		var err error
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无效的认证令牌"})
+				return
+			}
+			claims, err = verifier.Verify(c.Request.Context(), parts[1])
+		} else if c.Query(authtoken.MediaURLParam) != "" {
+			claims, err = verifier.VerifyMediaURL(c.Request.Context(), c.Request.URL)
+		} else {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无效的认证令牌"})
+			return
+		}
+
+		c.Set(ClaimsContextKey, claims)
+		c.Next()
+	}
+}
+
+// GetClaims retrieves the verified token claims from context
+func GetClaims(c *gin.Context) (*authtoken.Claims, bool) {
+	claims, exists := c.Get(ClaimsContextKey)
+	if !exists {
+		return nil, false
+	}
+	return claims.(*authtoken.Claims), true
+}
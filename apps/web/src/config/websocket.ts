@@ -1,3 +1,6 @@
+// json.batch lets the server coalesce queued messages into one (compressible) frame
+export const WS_SUBPROTOCOLS = ['json.batch', 'json'];
+
 export const getWebSocketUrl = (roomCode: string): string => {
   const baseUrl = process.env.NEXT_PUBLIC_WS_URL || 'ws://localhost:8080';
   return `${baseUrl}/ws/rooms/${roomCode}`;
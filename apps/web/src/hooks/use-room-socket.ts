@@ -4,6 +4,7 @@ import { useState, useCallback, useMemo, useEffect } from "react";
 import { useWebSocket } from "./use-websocket";
 import { getWebSocketUrl } from "@/config/websocket";
 import { useAuth } from "@/stores/auth-store";
+import { client } from "@/client/client.gen";
 
 export interface RoomParticipant {
   id: string;
@@ -240,9 +241,21 @@ export function useRoomSocket(options: UseRoomSocketOptions): UseRoomSocketRetur
   ]);
 
   // WebSocket connection
+  // Exchange the access token for a single-use WebSocket ticket
+  const getTicket = useCallback(async () => {
+    if (!token) return null;
+
+    const { data } = await client.post<{ 201: { ticket: string; expiresAt: string } }>({
+      security: [{ scheme: 'bearer', type: 'http' }],
+      url: '/rooms/{roomCode}/ws-ticket',
+      path: { roomCode },
+    });
+    return data?.ticket ?? null;
+  }, [token, roomCode]);
+
   const { isConnected, isConnecting, error, send, disconnect } = useWebSocket({
     url: wsUrl,
-    token: token ?? undefined,
+    getTicket,
     onMessage: handleMessage,
     reconnect: true,
   });
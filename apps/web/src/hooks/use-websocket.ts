@@ -1,6 +1,7 @@
 "use client";
 
 import { useEffect, useRef, useState, useCallback } from "react";
+import { WS_SUBPROTOCOLS } from "@/config/websocket";
 
 export interface WSMessage<T = any> {
   type: string;
@@ -10,7 +11,8 @@ export interface WSMessage<T = any> {
 
 export interface UseWebSocketOptions {
   url: string;
-  token?: string;
+  // Fetches a single-use connect ticket; called before every (re)connect
+  getTicket?: () => Promise<string | null>;
   onOpen?: () => void;
   onClose?: (event: CloseEvent) => void;
   onError?: (event: Event) => void;
@@ -35,7 +37,7 @@ const MAX_RECONNECT_DELAY = 30000; // 30 seconds
 export function useWebSocket(options: UseWebSocketOptions): UseWebSocketReturn {
   const {
     url,
-    token,
+    getTicket,
     onOpen,
     onClose,
     onError,
@@ -54,9 +56,6 @@ export function useWebSocket(options: UseWebSocketOptions): UseWebSocketReturn {
   const reconnectTimeoutRef = useRef<NodeJS.Timeout | null>(null);
   const intentionalDisconnectRef = useRef(false);
 
-  // Build WebSocket URL with token
-  const wsUrl = token ? `${url}?token=${encodeURIComponent(token)}` : url;
-
   // Send message
   const send = useCallback((type: string, payload: any) => {
     if (!wsRef.current || wsRef.current.readyState !== WebSocket.OPEN) {
@@ -97,9 +96,9 @@ export function useWebSocket(options: UseWebSocketOptions): UseWebSocketReturn {
   }, []);
 
   // Connect or reconnect
-  const connect = useCallback(() => {
+  const connect = useCallback(async () => {
     // Don't connect if URL is empty
-    if (!url || !wsUrl) {
+    if (!url) {
       return;
     }
 
@@ -112,7 +111,20 @@ export function useWebSocket(options: UseWebSocketOptions): UseWebSocketReturn {
     intentionalDisconnectRef.current = false;
 
     try {
-      const ws = new WebSocket(wsUrl);
+      // Tickets expire within seconds, so fetch a fresh one for every attempt
+      let wsUrl = url;
+      if (getTicket) {
+        const ticket = await getTicket();
+        if (!ticket) {
+          throw new Error("Failed to obtain WebSocket ticket");
+        }
+        if (intentionalDisconnectRef.current) {
+          return;
+        }
+        wsUrl = `${url}?ticket=${encodeURIComponent(ticket)}`;
+      }
+
+      const ws = new WebSocket(wsUrl, WS_SUBPROTOCOLS);
 
       ws.onopen = () => {
         setIsConnected(true);
@@ -159,7 +171,9 @@ export function useWebSocket(options: UseWebSocketOptions): UseWebSocketReturn {
       ws.onmessage = (event) => {
         try {
           const data = JSON.parse(event.data);
-          onMessage?.(data);
+          // Batched frames are an array of messages
+          const messages = ws.protocol === "json.batch" && Array.isArray(data) ? data : [data];
+          messages.forEach((message) => onMessage?.(message));
         } catch (err) {
           console.error("Failed to parse WebSocket message:", err);
         }
@@ -171,9 +185,9 @@ export function useWebSocket(options: UseWebSocketOptions): UseWebSocketReturn {
       setError(err instanceof Error ? err : new Error("Failed to create WebSocket"));
       setIsConnecting(false);
     }
-  }, [url, wsUrl, onOpen, onClose, onError, onMessage, reconnect, reconnectInterval, maxReconnectAttempts]);
+  }, [url, getTicket, onOpen, onClose, onError, onMessage, reconnect, reconnectInterval, maxReconnectAttempts]);
 
-  // Auto-connect on mount and token change
+  // Auto-connect on mount and URL change
   useEffect(() => {
     connect();
 
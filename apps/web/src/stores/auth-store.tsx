@@ -22,6 +22,13 @@ import {
 import { client } from "@/client/client.gen";
 
 const TOKEN_KEY = "auth_token";
+const REFRESH_TOKEN_KEY = "auth_refresh_token";
+
+// Login and refresh responses carry a refresh token the generated types don't know yet
+type TokenResponse = AuthResponse & { refreshToken: string; expiresIn: number };
+
+// Requests whose 401 means bad credentials rather than an expired access token
+const NO_REFRESH_PATHS = ["/auth/login", "/auth/register", "/auth/refresh"];
 
 interface AuthState {
   user: User | null;
@@ -50,6 +57,50 @@ const AuthContext = createContext<AuthContextType | null>(null);
 // Global logout handler for 401 interceptor
 let globalLogoutHandler: (() => void) | null = null;
 
+// Global handler storing tokens obtained by a background refresh
+let globalTokenHandler: ((auth: TokenResponse) => void) | null = null;
+
+// Unsent copies of requests, so one rejected with an expired token can be replayed
+const pendingRequests = new WeakMap<Request, Request>();
+
+// The refresh in flight; requests failing together share it, as each refresh token works once
+let refreshInFlight: Promise<string | null> | null = null;
+
+// refreshAccessToken trades the stored refresh token for new tokens and returns the access token
+function refreshAccessToken(): Promise<string | null> {
+  refreshInFlight ??= (async () => {
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+    if (!refreshToken) return null;
+
+    const { data } = await client.post<{ 200: TokenResponse }>({
+      url: "/auth/refresh",
+      body: { refreshToken },
+      headers: { "Content-Type": "application/json" },
+    });
+    if (!data) return null;
+
+    storeTokens(data);
+    globalTokenHandler?.(data);
+    return data.token;
+  })().finally(() => {
+    refreshInFlight = null;
+  });
+  return refreshInFlight;
+}
+
+function storeTokens(auth: TokenResponse) {
+  localStorage.setItem(TOKEN_KEY, auth.token);
+  localStorage.setItem(REFRESH_TOKEN_KEY, auth.refreshToken);
+  client.setConfig({
+    auth: () => auth.token,
+  });
+}
+
+function clearTokens() {
+  localStorage.removeItem(TOKEN_KEY);
+  localStorage.removeItem(REFRESH_TOKEN_KEY);
+}
+
 export function AuthProvider({ children }: { children: ReactNode }) {
   const router = useRouter();
   const [user, setUser] = useState<User | null>(null);
@@ -71,11 +122,37 @@ export function AuthProvider({ children }: { children: ReactNode }) {
     }
   }, [token]);
 
-  // Register 401 error interceptor - redirects to login on auth failure
+  // Register 401 interceptors - access tokens are short-lived, so a 401 first tries a
+  // refresh and replays the request; only a failed refresh logs out
   useEffect(() => {
     if (interceptorRegistered.current) return;
     interceptorRegistered.current = true;
 
+    client.interceptors.request.use((request) => {
+      pendingRequests.set(request, request.clone());
+      return request;
+    });
+
+    client.interceptors.response.use(async (response, request) => {
+      const unsent = pendingRequests.get(request);
+      const path = new URL(request.url).pathname;
+      if (
+        response.status !== 401 ||
+        !unsent ||
+        !request.headers.has("Authorization") ||
+        NO_REFRESH_PATHS.some((p) => path.endsWith(p))
+      ) {
+        return response;
+      }
+
+      const token = await refreshAccessToken();
+      if (!token) return response;
+
+      const retry = new Request(unsent);
+      retry.headers.set("Authorization", `Bearer ${token}`);
+      return fetch(retry);
+    });
+
     client.interceptors.error.use((error, response, request, options) => {
       // If 401 Unauthorized, clear auth and redirect to login
       if (response && response.status === 401) {
@@ -92,11 +169,16 @@ export function AuthProvider({ children }: { children: ReactNode }) {
     globalLogoutHandler = () => {
       setUser(null);
       setToken(null);
-      localStorage.removeItem(TOKEN_KEY);
+      clearTokens();
       router.push("/login");
     };
+    globalTokenHandler = (auth) => {
+      setUser(auth.user);
+      setToken(auth.token);
+    };
     return () => {
       globalLogoutHandler = null;
+      globalTokenHandler = null;
     };
   }, [router]);
 
@@ -115,8 +197,8 @@ export function AuthProvider({ children }: { children: ReactNode }) {
         if (data && !error) {
           setUser(data);
         } else {
-          // Token is invalid, clear it
-          localStorage.removeItem(TOKEN_KEY);
+          // Token is invalid and couldn't be refreshed, clear it
+          clearTokens();
           setToken(null);
         }
       }
@@ -141,10 +223,10 @@ export function AuthProvider({ children }: { children: ReactNode }) {
       }
 
       if (data) {
-        const authResponse = data as AuthResponse;
+        const authResponse = data as TokenResponse;
         setUser(authResponse.user);
         setToken(authResponse.token);
-        localStorage.setItem(TOKEN_KEY, authResponse.token);
+        storeTokens(authResponse);
         return { success: true };
       }
 
@@ -168,10 +250,10 @@ export function AuthProvider({ children }: { children: ReactNode }) {
       }
 
       if (data) {
-        const authResponse = data as AuthResponse;
+        const authResponse = data as TokenResponse;
         setUser(authResponse.user);
         setToken(authResponse.token);
-        localStorage.setItem(TOKEN_KEY, authResponse.token);
+        storeTokens(authResponse);
         return { success: true };
       }
 
@@ -188,7 +270,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
     }
     setUser(null);
     setToken(null);
-    localStorage.removeItem(TOKEN_KEY);
+    clearTokens();
     client.setConfig({
       auth: undefined,
     });
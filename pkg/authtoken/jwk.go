@@ -0,0 +1,134 @@
+// Package authtoken lets other services verify access tokens issued by the API gateway
+// using the gateway's published JSON Web Key Set, without sharing a signing secret.
+package authtoken
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms accepted for asymmetrically signed tokens
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Claims are the claims carried by a gateway access token
+type Claims struct {
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	SessionID string `json:"sid"`
+
+	// Guest tokens identify an anonymous participant (UserID is the guest ID) and are only valid for RoomID
+	Guest  bool   `json:"guest,omitempty"`
+	RoomID string `json:"roomId,omitempty"`
+
+	// Path is set on signed media URLs, which only authorize a request for that path
+	Path string `json:"path,omitempty"`
+
+	jwt.RegisteredClaims
+}
+
+// MediaURLParam is the query parameter that carries a signed media URL's token
+const MediaURLParam = "sig"
+
+// MediaAudience is the audience of signed media URLs. It differs from the access token
+// audience so a media URL that leaks into logs or a Referer can't be used as a bearer token.
+func MediaAudience(audience string) string {
+	return audience + "/media"
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWK encodes an RSA or Ed25519 public key as a JWK
+func PublicJWK(kid string, key crypto.PublicKey) (JWK, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: AlgRS256,
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: kid,
+			Use: "sig",
+			Alg: AlgEdDSA,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("authtoken: unsupported public key type %T", key)
+	}
+}
+
+// PublicKey decodes the JWK into an *rsa.PublicKey or ed25519.PublicKey
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.Kty == "RSA" && k.Alg == AlgRS256:
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("authtoken: invalid RSA modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("authtoken: invalid RSA exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 {
+			return nil, errors.New("authtoken: invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case k.Kty == "OKP" && k.Crv == "Ed25519" && k.Alg == AlgEdDSA:
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("authtoken: invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("authtoken: unsupported key %s/%s", k.Kty, k.Alg)
+	}
+}
+
+// SigningMethodFor returns the JWT signing method that matches a public key type.
+// Verifiers must compare it to the token's method so an attacker can't pick the algorithm.
+func SigningMethodFor(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("authtoken: unsupported public key type %T", key)
+	}
+}
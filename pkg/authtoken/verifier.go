@@ -0,0 +1,212 @@
+package authtoken
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrUnknownKey is returned when a token's kid is not in the key set, even after a refresh
+	ErrUnknownKey = errors.New("authtoken: unknown signing key")
+
+	// ErrGuestToken is returned for room-scoped guest tokens, which only the gateway accepts
+	ErrGuestToken = errors.New("authtoken: guest tokens are not accepted")
+
+	// ErrPathMismatch is returned when a signed media URL is used for a path it wasn't signed for
+	ErrPathMismatch = errors.New("authtoken: media URL signed for another path")
+)
+
+// VerifierConfig configures a Verifier
+type VerifierConfig struct {
+	// JWKSURL is the gateway's key set, e.g. http://api-gateway:8080/.well-known/jwks.json
+	JWKSURL  string
+	Issuer   string
+	Audience string
+
+	// HTTPClient defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+
+	// MinRefreshInterval limits how often an unknown kid triggers a refetch (default 1 minute)
+	MinRefreshInterval time.Duration
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+}
+
+// Verifier validates gateway access tokens against a remote JWKS.
+// Keys are fetched lazily and refetched when a token names a kid we haven't seen,
+// which picks up key rotation without a restart.
+type Verifier struct {
+	cfg         VerifierConfig
+	parser      *jwt.Parser
+	mediaParser *jwt.Parser
+
+	mu        sync.RWMutex
+	keys      map[string]verificationKey
+	fetchedAt time.Time
+}
+
+// NewVerifier creates a Verifier; no network calls are made until the first Verify
+func NewVerifier(cfg VerifierConfig) (*Verifier, error) {
+	if cfg.JWKSURL == "" {
+		return nil, errors.New("authtoken: JWKS URL is required")
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("authtoken: issuer and audience are required")
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.MinRefreshInterval <= 0 {
+		cfg.MinRefreshInterval = time.Minute
+	}
+
+	return &Verifier{
+		cfg: cfg,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+		),
+		mediaParser: jwt.NewParser(
+			jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(MediaAudience(cfg.Audience)),
+			jwt.WithExpirationRequired(),
+		),
+		keys: make(map[string]verificationKey),
+	}, nil
+}
+
+// Verify checks a token's signature, algorithm, issuer, audience and expiry and returns its claims.
+// Guest tokens share the access token audience but are rejected: they only identify a guest
+// within one room, and services outside the gateway can't check that the guest is still admitted.
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := v.parse(ctx, v.parser, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Guest {
+		return nil, ErrGuestToken
+	}
+	return claims, nil
+}
+
+// VerifyMediaURL checks the signature carried in u's MediaURLParam and that it was signed for u's path
+func (v *Verifier) VerifyMediaURL(ctx context.Context, u *url.URL) (*Claims, error) {
+	claims, err := v.parse(ctx, v.mediaParser, u.Query().Get(MediaURLParam))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Path == "" || claims.Path != u.Path {
+		return nil, ErrPathMismatch
+	}
+	return claims, nil
+}
+
+// parse verifies tokenString with parser, taking the key from the token's kid header
+func (v *Verifier) parse(ctx context.Context, parser *jwt.Parser, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := v.lookup(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("authtoken: key %q does not sign with %s", kid, token.Method.Alg())
+		}
+		return key.key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// lookup returns the key for kid, refetching the key set at most once per MinRefreshInterval
+func (v *Verifier) lookup(ctx context.Context, kid string) (verificationKey, error) {
+	if kid == "" {
+		return verificationKey{}, ErrUnknownKey
+	}
+
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	stale := time.Since(v.fetchedAt) >= v.cfg.MinRefreshInterval
+	v.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return verificationKey{}, ErrUnknownKey
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		return verificationKey{}, err
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	return verificationKey{}, ErrUnknownKey
+}
+
+// refresh replaces the cached keys with the current key set
+func (v *Verifier) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("authtoken: fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("authtoken: fetching JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("authtoken: decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]verificationKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			continue // skip key types we don't understand rather than failing the whole set
+		}
+		method, err := SigningMethodFor(pub)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = verificationKey{method: method, key: pub}
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,205 @@
+package authtoken
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testKeyServer struct {
+	server  *httptest.Server
+	keys    atomic.Value // JWKS
+	fetches atomic.Int32
+}
+
+func newTestKeyServer(t *testing.T) *testKeyServer {
+	ks := &testKeyServer{}
+	ks.keys.Store(JWKS{Keys: []JWK{}})
+	ks.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ks.fetches.Add(1)
+		json.NewEncoder(w).Encode(ks.keys.Load())
+	}))
+	t.Cleanup(ks.server.Close)
+	return ks
+}
+
+func (ks *testKeyServer) publish(t *testing.T, keys map[string]crypto.PublicKey) {
+	set := JWKS{}
+	for kid, key := range keys {
+		jwk, err := PublicJWK(kid, key)
+		require.NoError(t, err)
+		set.Keys = append(set.Keys, jwk)
+	}
+	ks.keys.Store(set)
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string) string {
+	return signClaims(t, method, key, kid, "cowatch", "")
+}
+
+func signClaims(t *testing.T, method jwt.SigningMethod, key interface{}, kid, audience, path string) string {
+	now := time.Now()
+	token := jwt.NewWithClaims(method, &Claims{
+		UserID: "user-1",
+		Path:   path,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "cowatch-api",
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ks := newTestKeyServer(t)
+	ks.publish(t, map[string]crypto.PublicKey{"rsa-1": &rsaKey.PublicKey, "ed-1": edPub})
+
+	verifier, err := NewVerifier(VerifierConfig{
+		JWKSURL:            ks.server.URL,
+		Issuer:             "cowatch-api",
+		Audience:           "cowatch",
+		MinRefreshInterval: time.Hour,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("RS256 token", func(t *testing.T) {
+		claims, err := verifier.Verify(ctx, sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1"))
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", claims.UserID)
+	})
+
+	t.Run("EdDSA token", func(t *testing.T) {
+		_, err := verifier.Verify(ctx, sign(t, jwt.SigningMethodEdDSA, edKey, "ed-1"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("algorithm must match the key", func(t *testing.T) {
+		// An HMAC token "signed" with the published RSA key must not verify
+		modulus := rsaKey.PublicKey.N.Bytes()
+		_, err := verifier.Verify(ctx, sign(t, jwt.SigningMethodHS256, modulus, "rsa-1"))
+		assert.Error(t, err)
+
+		_, err = verifier.Verify(ctx, sign(t, jwt.SigningMethodEdDSA, edKey, "rsa-1"))
+		assert.Error(t, err)
+	})
+
+	t.Run("guest token", func(t *testing.T) {
+		now := time.Now()
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &Claims{
+			UserID: "guest-1",
+			Guest:  true,
+			RoomID: "room-1",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "cowatch-api",
+				Audience:  jwt.ClaimStrings{"cowatch"},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+		})
+		token.Header["kid"] = "ed-1"
+		signed, err := token.SignedString(edKey)
+		require.NoError(t, err)
+
+		_, err = verifier.Verify(ctx, signed)
+		assert.ErrorIs(t, err, ErrGuestToken)
+	})
+
+	t.Run("unknown kid is rate limited", func(t *testing.T) {
+		before := ks.fetches.Load()
+		_, err := verifier.Verify(ctx, sign(t, jwt.SigningMethodRS256, rsaKey, "missing"))
+		assert.ErrorIs(t, err, ErrUnknownKey)
+		assert.Equal(t, before, ks.fetches.Load(), "should not refetch within MinRefreshInterval")
+	})
+}
+
+func TestVerifierPicksUpRotatedKeys(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ks := newTestKeyServer(t)
+	ks.publish(t, map[string]crypto.PublicKey{"old": &oldKey.PublicKey})
+
+	verifier, err := NewVerifier(VerifierConfig{
+		JWKSURL:            ks.server.URL,
+		Issuer:             "cowatch-api",
+		Audience:           "cowatch",
+		MinRefreshInterval: time.Nanosecond,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = verifier.Verify(ctx, sign(t, jwt.SigningMethodRS256, oldKey, "old"))
+	require.NoError(t, err)
+
+	ks.publish(t, map[string]crypto.PublicKey{"old": &oldKey.PublicKey, "new": newKey.Public()})
+	_, err = verifier.Verify(ctx, sign(t, jwt.SigningMethodEdDSA, newKey, "new"))
+	assert.NoError(t, err)
+}
+
+func TestVerifyMediaURL(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ks := newTestKeyServer(t)
+	ks.publish(t, map[string]crypto.PublicKey{"ed-1": key.Public()})
+
+	verifier, err := NewVerifier(VerifierConfig{
+		JWKSURL:  ks.server.URL,
+		Issuer:   "cowatch-api",
+		Audience: "cowatch",
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	mediaURL := func(path, sig string) *url.URL {
+		return &url.URL{Path: path, RawQuery: url.Values{MediaURLParam: {sig}}.Encode()}
+	}
+	sig := signClaims(t, jwt.SigningMethodEdDSA, key, "ed-1", MediaAudience("cowatch"), "/api/v1/streams/abc")
+
+	t.Run("signed path", func(t *testing.T) {
+		claims, err := verifier.VerifyMediaURL(ctx, mediaURL("/api/v1/streams/abc", sig))
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", claims.UserID)
+	})
+
+	t.Run("other path", func(t *testing.T) {
+		_, err := verifier.VerifyMediaURL(ctx, mediaURL("/api/v1/streams/xyz", sig))
+		assert.ErrorIs(t, err, ErrPathMismatch)
+	})
+
+	t.Run("not a bearer token", func(t *testing.T) {
+		_, err := verifier.Verify(ctx, sig)
+		assert.Error(t, err)
+	})
+
+	t.Run("access token is not a media URL", func(t *testing.T) {
+		access := signClaims(t, jwt.SigningMethodEdDSA, key, "ed-1", "cowatch", "/api/v1/streams/abc")
+		_, err := verifier.VerifyMediaURL(ctx, mediaURL("/api/v1/streams/abc", access))
+		assert.Error(t, err)
+	})
+}